@@ -0,0 +1,120 @@
+package gorequest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AttemptRecord is the outcome of a single attempt to send a request.
+type AttemptRecord struct {
+	// Start and End of the attempt, including the send and unmarshal hooks
+	Start time.Time
+	End   time.Time
+	// StatusCode of the response, 0 if no response was received
+	StatusCode int
+	// Err is the error the attempt failed with
+	Err error
+	// Delay is the time waited after this attempt before the next one started
+	Delay time.Duration
+	// Header of the response
+	Header http.Header
+	// Timing is the connection timing of the attempt, when recorded
+	Timing AttemptTiming
+}
+
+// Duration returns how long the attempt took
+func (a AttemptRecord) Duration() time.Duration {
+	return a.End.Sub(a.Start)
+}
+
+func (a AttemptRecord) String() string {
+	var b strings.Builder
+	if a.StatusCode != 0 {
+		fmt.Fprintf(&b, "status=%d ", a.StatusCode)
+	}
+	if a.Err != nil {
+		fmt.Fprintf(&b, "error=%q ", a.Err.Error())
+	}
+	fmt.Fprintf(&b, "took=%s", a.Duration().Round(time.Microsecond))
+	if a.Delay > 0 {
+		fmt.Fprintf(&b, " wait=%s", a.Delay.Round(time.Microsecond))
+	}
+	return b.String()
+}
+
+// recordAttempt appends the outcome of the attempt that started at start.
+func (r *Request) recordAttempt(start time.Time) {
+	record := AttemptRecord{
+		Start:  start,
+		End:    time.Now(),
+		Err:    r.Error,
+		Timing: r.Timing(),
+	}
+	if r.Response != nil {
+		record.StatusCode = r.Response.StatusCode
+		record.Header = r.Response.Header.Clone()
+	}
+	r.Attempts = append(r.Attempts, record)
+}
+
+// Summary returns a single line description of the attempts made to send the
+// request, suitable for logs and error messages.
+func (r *Request) Summary() string {
+	name := r.Operation.Name
+	if name == "" && r.Request != nil {
+		name = r.Request.Method + " " + r.Request.URL.Path
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d attempt(s)", name, len(r.Attempts))
+	if n := len(r.Attempts); n > 0 {
+		fmt.Fprintf(&b, " in %s", r.Attempts[n-1].End.Sub(r.Attempts[0].Start).Round(time.Microsecond))
+	}
+	for i, attempt := range r.Attempts {
+		fmt.Fprintf(&b, "; #%d %s", i+1, attempt)
+	}
+	return b.String()
+}
+
+// AttemptsError is returned by Request.Send when a request was attempted more
+// than once and all the attempts failed. It wraps the error of every attempt.
+type AttemptsError struct {
+	Summary string
+	Errs    []error
+}
+
+func (e *AttemptsError) Error() string {
+	return "request failed after retries, " + e.Summary
+}
+
+// Unwrap returns the errors of all the attempts
+func (e *AttemptsError) Unwrap() []error {
+	return e.Errs
+}
+
+// attemptsError returns the error Send returns once a retried request ends,
+// because retries ran out or a hook stopped them. A request attempted once
+// returns its error unchanged.
+func (r *Request) attemptsError() error {
+	if len(r.Attempts) < 2 {
+		return r.Error
+	}
+
+	// the current error may have been annotated after the last attempt, unless
+	// the request was stopped with another error
+	attempts := r.Attempts[:len(r.Attempts)-1]
+	if r.stopErr != nil {
+		attempts = r.Attempts
+	}
+	errs := make([]error, 0, len(r.Attempts)+1)
+	for _, attempt := range attempts {
+		if attempt.Err != nil {
+			errs = append(errs, attempt.Err)
+		}
+	}
+	errs = append(errs, r.Error)
+
+	return &AttemptsError{Summary: r.Summary(), Errs: errs}
+}
@@ -0,0 +1,153 @@
+package gorequest
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest_Attempts(t *testing.T) {
+
+	t.Run("test that a successful request records a single attempt", func(t *testing.T) {
+		hooks := Hooks{}
+		hooks.Send.PushBack(func(r *Request) {
+			r.Response = &http.Response{StatusCode: http.StatusOK, Header: http.Header{"X-Id": []string{"1"}}}
+		})
+
+		req := New(Config{}, Operation{Name: "Foo"}, hooks, nil, nil, nil)
+		assert.NoError(t, req.Send())
+
+		if assert.Len(t, req.Attempts, 1) {
+			attempt := req.Attempts[0]
+			assert.Equal(t, http.StatusOK, attempt.StatusCode)
+			assert.Equal(t, "1", attempt.Header.Get("X-Id"))
+			assert.Nil(t, attempt.Err)
+			assert.Equal(t, time.Duration(0), attempt.Delay)
+			assert.False(t, attempt.End.Before(attempt.Start))
+		}
+	})
+
+	t.Run("test that every retried attempt is recorded", func(t *testing.T) {
+		hooks := Hooks{}
+
+		errs := []error{
+			FakeTemporaryError{error: errors.New("error 1"), temporary: true},
+			FakeTemporaryError{error: errors.New("error 2"), temporary: true},
+			FakeTemporaryError{error: errors.New("error 3"), temporary: true},
+		}
+		hooks.Send.PushBack(func(r *Request) {
+			r.Response = &http.Response{StatusCode: http.StatusServiceUnavailable}
+			r.Error = errs[len(r.Attempts)]
+		})
+		hooks.Retry.PushBack(func(r *Request) {
+			r.RetryConfig.RetryCount++
+			time.Sleep(5 * time.Millisecond)
+		})
+
+		req := New(Config{}, Operation{Name: "Foo"}, hooks, retryer{}, nil, nil)
+		req.WithRetryConfig(RetryConfig{MaxRetries: 2, InitialDelay: time.Millisecond})
+
+		err := req.Send()
+		assert.Error(t, err)
+
+		if !assert.Len(t, req.Attempts, 3) {
+			return
+		}
+		for i, attempt := range req.Attempts {
+			assert.Equal(t, http.StatusServiceUnavailable, attempt.StatusCode)
+			assert.ErrorIs(t, attempt.Err, errs[i])
+		}
+		assert.GreaterOrEqual(t, req.Attempts[0].Delay, 5*time.Millisecond)
+		assert.GreaterOrEqual(t, req.Attempts[1].Delay, 5*time.Millisecond)
+		assert.Equal(t, time.Duration(0), req.Attempts[2].Delay)
+
+		// the returned error joins the errors of all attempts
+		var attemptsErr *AttemptsError
+		assert.True(t, errors.As(err, &attemptsErr))
+		for _, e := range errs {
+			assert.True(t, errors.Is(err, e))
+		}
+		assert.Equal(t, err, req.Error)
+	})
+
+	t.Run("test that a request stopped by a hook joins the errors of all attempts", func(t *testing.T) {
+		errs := []error{
+			FakeTemporaryError{error: errors.New("error 1"), temporary: true},
+			FakeTemporaryError{error: errors.New("error 2"), temporary: true},
+		}
+		stopErr := errors.New("context canceled while waiting")
+
+		tcs := map[string]func(hooks *Hooks){
+			"retry hook": func(hooks *Hooks) {
+				hooks.Retry.PushBack(func(r *Request) {
+					r.RetryConfig.RetryCount++
+					if len(r.Attempts) == 2 {
+						r.StopRetry(stopErr)
+					}
+				})
+			},
+			"reauth hook": func(hooks *Hooks) {
+				hooks.Send.PushBack(func(r *Request) {
+					r.Reauthenticate()
+				})
+				hooks.Reauth.PushBack(func(r *Request) {
+					if len(r.Attempts) == 2 {
+						r.StopRetry(stopErr)
+					}
+				})
+			},
+		}
+
+		for name, register := range tcs {
+			t.Run(name, func(t *testing.T) {
+				hooks := Hooks{}
+				hooks.Send.PushBack(func(r *Request) {
+					r.Error = errs[len(r.Attempts)]
+				})
+				register(&hooks)
+
+				req := New(Config{}, Operation{Name: "Foo"}, hooks, retryer{}, nil, nil)
+				req.WithRetryConfig(RetryConfig{MaxRetries: 2, InitialDelay: time.Millisecond})
+
+				err := req.Send()
+				var attemptsErr *AttemptsError
+				assert.True(t, errors.As(err, &attemptsErr))
+				assert.ErrorIs(t, err, errs[0])
+				assert.ErrorIs(t, err, errs[1])
+				assert.ErrorIs(t, err, stopErr)
+			})
+		}
+	})
+
+	t.Run("test that a request attempted once returns the error of the attempt", func(t *testing.T) {
+		hooks := Hooks{}
+		sendErr := errors.New("fake error")
+		hooks.Send.PushBack(func(r *Request) {
+			r.Error = sendErr
+		})
+
+		req := New(Config{}, Operation{}, hooks, nil, nil, nil)
+		err := req.Send()
+		assert.ErrorIs(t, err, sendErr)
+		assert.Equal(t, req.Attempts[0].Err, err)
+	})
+}
+
+func TestRequest_Summary(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	req := New(Config{}, Operation{Name: "GetPost"}, Hooks{}, nil, nil, nil)
+	req.Attempts = []AttemptRecord{
+		{Start: start, End: start.Add(10 * time.Millisecond), StatusCode: 503, Err: errors.New("unavailable"), Delay: time.Second},
+		{Start: start.Add(1010 * time.Millisecond), End: start.Add(1020 * time.Millisecond), StatusCode: 200},
+	}
+
+	summary := req.Summary()
+	assert.True(t, strings.HasPrefix(summary, "GetPost: 2 attempt(s) in 1.02s"), summary)
+	assert.Contains(t, summary, `#1 status=503 error="unavailable" took=10ms wait=1s`)
+	assert.Contains(t, summary, "#2 status=200 took=10ms")
+}
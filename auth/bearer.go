@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/SirWaithaka/gorequest"
+)
+
+// ErrUnauthorized is the error of a request whose response has the status
+// 401 Unauthorized, when no other hook set an error.
+var ErrUnauthorized = errors.New("auth: unauthorized")
+
+// Invalidator is implemented by token sources that cache tokens, such as
+// CachedSource, so that a token rejected by the server is not reused.
+type Invalidator interface {
+	Invalidate(token *Token)
+}
+
+// NewBearer returns a Bearer authorizing requests with tokens from source.
+func NewBearer(source TokenSource) Bearer {
+	return Bearer{source: source}
+}
+
+// Bearer provides the hooks that authorize requests with an access token in
+// the Authorization header. When a response is 401 Unauthorized, the token is
+// renewed and the request is sent once more with the new token, without using
+// up a retry.
+type Bearer struct {
+	source TokenSource
+}
+
+type stateKey struct{}
+
+// state is the per request bookkeeping shared by the hooks
+type state struct {
+	token *Token
+	// refresh is set when the token of the request is to be renewed
+	refresh bool
+	// refreshed is set once the token of the request was renewed
+	refreshed bool
+}
+
+func stateFromRequest(r *gorequest.Request) *state {
+	s, _ := r.Context().Value(stateKey{}).(*state)
+	return s
+}
+
+// Apply registers the bearer hooks on hooks.
+func (b Bearer) Apply(hooks *gorequest.Hooks) {
+	hooks.Build.PushBackHook(b.Authorize())
+	hooks.Unmarshal.PushBackHook(b.Unauthorized())
+	hooks.Reauth.PushBackHook(b.Refresh())
+}
+
+// Authorize returns a build hook that sets the Authorization header to a
+// token from the source.
+func (b Bearer) Authorize() gorequest.Hook {
+	return gorequest.Hook{Name: "auth.Bearer", Fn: func(r *gorequest.Request) {
+		token, err := b.source.Token(r.Context())
+		if err != nil {
+			r.Error = fmt.Errorf("auth: getting token: %w", err)
+			return
+		}
+
+		r.WithContext(context.WithValue(r.Context(), stateKey{}, &state{token: token}))
+		r.Request.Header.Set("Authorization", token.Type()+" "+token.AccessToken)
+	}}
+}
+
+// Unauthorized returns an unmarshal hook that sets ErrUnauthorized as the
+// error of 401 responses. The first 401 response of a request authorized by
+// the bearer marks the request for re-authentication.
+func (b Bearer) Unauthorized() gorequest.Hook {
+	return gorequest.Hook{Name: "auth.Unauthorized", Fn: func(r *gorequest.Request) {
+		if r.Error != nil || r.Response.StatusCode != http.StatusUnauthorized {
+			return
+		}
+		r.Error = ErrUnauthorized
+
+		if s := stateFromRequest(r); s != nil && !s.refreshed {
+			s.refresh = true
+			r.Reauthenticate()
+		}
+	}}
+}
+
+// Refresh returns a reauth hook that renews the token of a request marked
+// for re-authentication by a 401 response. The rejected token is invalidated
+// first if the source is an Invalidator.
+func (b Bearer) Refresh() gorequest.Hook {
+	return gorequest.Hook{Name: "auth.Refresh", Fn: func(r *gorequest.Request) {
+		s := stateFromRequest(r)
+		if s == nil || !s.refresh {
+			return
+		}
+		s.refresh, s.refreshed = false, true
+
+		if invalidator, ok := b.source.(Invalidator); ok {
+			invalidator.Invalidate(s.token)
+		}
+		token, err := b.source.Token(r.Context())
+		if err != nil {
+			r.StopRetry(fmt.Errorf("auth: refreshing token: %w", err))
+			return
+		}
+
+		s.token = token
+		r.Request.Header.Set("Authorization", token.Type()+" "+token.AccessToken)
+	}}
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/SirWaithaka/gorequest/internal/refresh"
+)
+
+// NewCachedSource returns a CachedSource reusing the tokens of source until
+// earlyRefresh before they expire.
+func NewCachedSource(source TokenSource, earlyRefresh time.Duration) *CachedSource {
+	return &CachedSource{cache: refresh.New(refresh.Config[*Token]{
+		Fetch: source.Token,
+		Valid: (*Token).Valid,
+		Fresh: func(token *Token, _ time.Time) bool {
+			return !token.expiresWithin(earlyRefresh)
+		},
+	})}
+}
+
+// CachedSource is a TokenSource that caches the token of another source.
+// Concurrent calls needing a new token share a single call to the source. A
+// token is renewed earlyRefresh before it expires, so that requests do not
+// race its expiry; if renewing fails while the cached token is still valid,
+// the cached token is returned.
+type CachedSource struct {
+	cache *refresh.Cache[*Token]
+}
+
+func (c *CachedSource) Token(ctx context.Context) (*Token, error) {
+	return c.cache.Get(ctx)
+}
+
+// Invalidate drops token from the cache, so that the next call gets a new
+// token. It does nothing if token is no longer the cached token, so that
+// concurrent requests rejecting the same token cause a single renewal.
+func (c *CachedSource) Invalidate(token *Token) {
+	c.cache.Invalidate(func(cached *Token) bool {
+		return cached != nil && token != nil && cached.AccessToken == token.AccessToken
+	})
+}
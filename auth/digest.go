@@ -0,0 +1,467 @@
+package auth
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/credentials"
+)
+
+// DigestConfig configures digest access authentication (RFC 7616).
+type DigestConfig struct {
+	// Credentials provides the username and password as the ID and Secret of
+	// its credentials.
+	Credentials credentials.Provider
+	// Integrity prefers the quality of protection auth-int, which also
+	// protects the body, when a challenge offers both auth and auth-int.
+	Integrity bool
+	// CNonce returns the client nonce of an attempt. Defaults to 16 random
+	// bytes, hex encoded.
+	CNonce func() (string, error)
+}
+
+// NewDigest returns a Digest authenticating requests with the credentials of
+// cfg.
+func NewDigest(cfg DigestConfig) *Digest {
+	if cfg.CNonce == nil {
+		cfg.CNonce = randomCNonce
+	}
+	return &Digest{cfg: cfg, challenges: make(map[string]*digestChallenge)}
+}
+
+// Digest provides the hooks of digest access authentication. A request to a
+// host without a known challenge is sent without digest credentials, and is
+// sent once more with credentials when the response is a 401 Unauthorized with
+// a Digest challenge, without using up a retry. The challenge is cached per
+// host, so later requests to the host are authenticated from their first
+// attempt.
+//
+// The Authorization header is set by a send hook, since every attempt has its
+// own nonce count and client nonce. It is set after the build hooks ran, so a
+// signature of the signer package does not cover it.
+type Digest struct {
+	cfg DigestConfig
+
+	mu sync.Mutex
+	// challenges are the last digest challenges by host
+	challenges map[string]*digestChallenge
+}
+
+// digestChallenge is a challenge of a WWW-Authenticate header
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       []string
+	stale     bool
+	userhash  bool
+	// nc counts the requests sent with the nonce
+	nc uint32
+}
+
+type digestStateKey struct{}
+
+// digestState is the per request bookkeeping shared by the hooks
+type digestState struct {
+	// challenges counts the digest challenges received by the request
+	challenges int
+	// authorization is the Authorization header the digest set on the last
+	// attempt, if any
+	authorization string
+}
+
+func digestStateFromRequest(r *gorequest.Request) *digestState {
+	s, _ := r.Context().Value(digestStateKey{}).(*digestState)
+	return s
+}
+
+// Apply registers the digest hooks on hooks.
+func (d *Digest) Apply(hooks *gorequest.Hooks) {
+	hooks.Send.PushFrontHook(d.Authorize())
+	hooks.Unmarshal.PushFrontHook(d.Challenge())
+}
+
+// Authorize returns a send hook that sets the Authorization header of every
+// attempt from the challenge cached for the host of the request. Without a
+// challenge for the host, an Authorization header the hook set on a previous
+// attempt is removed, and one set by other hooks is left as it is. It runs
+// after the build hooks, so signatures made by the signer package do not
+// cover the header.
+func (d *Digest) Authorize() gorequest.Hook {
+	return gorequest.Hook{Name: "auth.Digest", Fn: func(r *gorequest.Request) {
+		if r.Error != nil {
+			return
+		}
+		s := digestStateFromRequest(r)
+		if s == nil {
+			s = &digestState{}
+			r.WithContext(context.WithValue(r.Context(), digestStateKey{}, s))
+		}
+
+		c, nc := d.next(r.Request.URL.Host)
+		if c == nil {
+			if s.authorization != "" && r.Request.Header.Get("Authorization") == s.authorization {
+				r.Request.Header.Del("Authorization")
+			}
+			s.authorization = ""
+			return
+		}
+
+		authorization, err := d.authorization(r.Request, c, nc)
+		if err != nil {
+			r.Error = fmt.Errorf("auth: digest: %w", err)
+			return
+		}
+		r.Request.Header.Set("Authorization", authorization)
+		s.authorization = authorization
+	}}
+}
+
+// Challenge returns an unmarshal hook that caches the digest challenge of a
+// 401 response for its host, sets ErrUnauthorized as the error of the response
+// and marks the request for re-authentication, so that the challenge is
+// answered by the next attempt. The next nonce of a successful response is
+// cached as well.
+func (d *Digest) Challenge() gorequest.Hook {
+	return gorequest.Hook{Name: "auth.DigestChallenge", Fn: func(r *gorequest.Request) {
+		if r.Error != nil {
+			return
+		}
+		host := r.Request.URL.Host
+
+		if r.Response.StatusCode != http.StatusUnauthorized {
+			info := parseParams(r.Response.Header.Get("Authentication-Info"))
+			if next := info["nextnonce"]; next != "" {
+				d.mu.Lock()
+				if c := d.challenges[host]; c != nil {
+					c.nonce, c.nc = next, 0
+				}
+				d.mu.Unlock()
+			}
+			return
+		}
+
+		r.Error = ErrUnauthorized
+		c := selectChallenge(r.Response.Header.Values("WWW-Authenticate"))
+		if c == nil {
+			return
+		}
+		d.mu.Lock()
+		d.challenges[host] = c
+		d.mu.Unlock()
+
+		if s := digestStateFromRequest(r); s != nil {
+			s.challenges++
+			// a stale nonce is answered once more, since the credentials
+			// were accepted
+			if s.challenges == 1 || (s.challenges == 2 && c.stale) {
+				r.Reauthenticate()
+			}
+		}
+	}}
+}
+
+// next returns a copy of the challenge cached for host and the nonce count of
+// the next request sent with it
+func (d *Digest) next(host string) (*digestChallenge, uint32) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c := d.challenges[host]
+	if c == nil {
+		return nil, 0
+	}
+	c.nc++
+	cp := *c
+	return &cp, c.nc
+}
+
+// authorization returns the Authorization header answering challenge c
+// (RFC 7616 section 3.4)
+func (d *Digest) authorization(req *http.Request, c *digestChallenge, nc uint32) (string, error) {
+	creds, err := d.cfg.Credentials.Retrieve(req.Context())
+	if err != nil {
+		return "", err
+	}
+	cnonce, err := d.cfg.CNonce()
+	if err != nil {
+		return "", err
+	}
+
+	h := digestHash(c.algorithm)
+	uri := req.URL.RequestURI()
+	ncValue := fmt.Sprintf("%08x", nc)
+
+	ha1 := h(creds.ID + ":" + c.realm + ":" + creds.Secret)
+	if strings.HasSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+
+	qop := d.qop(c)
+	a2 := req.Method + ":" + uri
+	if qop == "auth-int" {
+		body, err := digestBody(req)
+		if err != nil {
+			return "", err
+		}
+		a2 += ":" + h(string(body))
+	}
+	ha2 := h(a2)
+
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	} else {
+		response = h(strings.Join([]string{ha1, c.nonce, ncValue, cnonce, qop, ha2}, ":"))
+	}
+
+	username := creds.ID
+	if c.userhash {
+		username = h(creds.ID + ":" + c.realm)
+	}
+
+	params := []string{
+		"username=" + quote(username),
+		"realm=" + quote(c.realm),
+		"uri=" + quote(uri),
+		"algorithm=" + c.algorithm,
+		"nonce=" + quote(c.nonce),
+	}
+	if qop != "" {
+		params = append(params, "nc="+ncValue, "cnonce="+quote(cnonce), "qop="+qop)
+	}
+	params = append(params, "response="+quote(response))
+	if c.opaque != "" {
+		params = append(params, "opaque="+quote(c.opaque))
+	}
+	if c.userhash {
+		params = append(params, "userhash=true")
+	}
+	return "Digest " + strings.Join(params, ", "), nil
+}
+
+// qop returns the quality of protection answering challenge c, or an empty
+// string for challenges without qop (RFC 2069 compatibility)
+func (d *Digest) qop(c *digestChallenge) string {
+	auth, authInt := slices.Contains(c.qop, "auth"), slices.Contains(c.qop, "auth-int")
+	switch {
+	case authInt && (d.cfg.Integrity || !auth):
+		return "auth-int"
+	case auth:
+		return "auth"
+	}
+	return ""
+}
+
+// digestBody returns the body of the request for auth-int, which must be
+// replayable as it is read before the request is sent
+func digestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("auth-int requires a replayable body")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// digestAlgorithms are the supported algorithms, in order of preference
+var digestAlgorithms = []string{"SHA-512-256", "SHA-256", "MD5"}
+
+// selectChallenge returns the digest challenge of the WWW-Authenticate header
+// values with the preferred algorithm, or nil if there is none
+func selectChallenge(values []string) *digestChallenge {
+	var selected *digestChallenge
+	rank := len(digestAlgorithms)
+	for _, c := range parseChallenges(values) {
+		if !strings.EqualFold(c.scheme, "Digest") || c.params["nonce"] == "" {
+			continue
+		}
+		algorithm := c.params["algorithm"]
+		if algorithm == "" {
+			algorithm = "MD5"
+		}
+		i := slices.Index(digestAlgorithms, strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS"))
+		if i < 0 || i >= rank {
+			continue
+		}
+
+		rank = i
+		selected = &digestChallenge{
+			realm:     c.params["realm"],
+			nonce:     c.params["nonce"],
+			opaque:    c.params["opaque"],
+			algorithm: algorithm,
+			stale:     strings.EqualFold(c.params["stale"], "true"),
+			userhash:  strings.EqualFold(c.params["userhash"], "true"),
+		}
+		for _, qop := range strings.Split(c.params["qop"], ",") {
+			if qop = strings.TrimSpace(qop); qop != "" {
+				selected.qop = append(selected.qop, qop)
+			}
+		}
+	}
+	return selected
+}
+
+// digestHash returns the hex encoded hash function of algorithm
+func digestHash(algorithm string) func(string) string {
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "SHA-512-256":
+		newHash = sha512.New512_256
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		newHash = md5.New
+	}
+	return func(s string) string {
+		h := newHash()
+		h.Write([]byte(s))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+}
+
+func randomCNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// quote returns s as a quoted-string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// challenge is an authentication challenge (RFC 9110 section 11.6.1)
+type challenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenges parses the challenges of WWW-Authenticate header values.
+// The parameter names are lowercased.
+func parseChallenges(values []string) []challenge {
+	var challenges []challenge
+	for _, v := range values {
+		p := authParser{s: v}
+		for {
+			p.skip(", ")
+			scheme := p.token()
+			if scheme == "" {
+				break
+			}
+			challenges = append(challenges, challenge{scheme: scheme, params: p.params()})
+		}
+	}
+	return challenges
+}
+
+// parseParams parses a list of auth parameters, as in the
+// Authentication-Info header
+func parseParams(v string) map[string]string {
+	p := authParser{s: v}
+	return p.params()
+}
+
+type authParser struct {
+	s   string
+	pos int
+}
+
+func (p *authParser) skip(chars string) {
+	for p.pos < len(p.s) && strings.IndexByte(chars, p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *authParser) token() string {
+	start := p.pos
+	for p.pos < len(p.s) && isTokenChar(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *authParser) quoted() string {
+	// skip the opening quote
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == '"':
+			return b.String()
+		case c == '\\' && p.pos < len(p.s):
+			b.WriteByte(p.s[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// params parses auth parameters until the end of the value or the scheme of
+// the next challenge
+func (p *authParser) params() map[string]string {
+	params := make(map[string]string)
+	for {
+		p.skip(", ")
+		start := p.pos
+		name := p.token()
+		p.skip(" ")
+		if name == "" || p.pos >= len(p.s) || p.s[p.pos] != '=' {
+			// the scheme of the next challenge, or a token68
+			p.pos = start
+			if name == "" && p.pos < len(p.s) {
+				// skip what can not be parsed up to the next parameter
+				for p.pos < len(p.s) && p.s[p.pos] != ',' {
+					p.pos++
+				}
+				if p.pos < len(p.s) {
+					continue
+				}
+			}
+			return params
+		}
+		// skip the equals sign
+		p.pos++
+		p.skip(" ")
+
+		var value string
+		if p.pos < len(p.s) && p.s[p.pos] == '"' {
+			value = p.quoted()
+		} else {
+			value = p.token()
+		}
+		params[strings.ToLower(name)] = value
+	}
+}
+
+func isTokenChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/corehooks"
+	"github.com/SirWaithaka/gorequest/credentials"
+)
+
+// ClientCredentialsConfig configures the OAuth2 client credentials flow
+// (RFC 6749 section 4.4).
+type ClientCredentialsConfig struct {
+	// TokenURL is the url of the token endpoint.
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	// Credentials provides the client id and secret instead of ClientID and
+	// ClientSecret, so that a rotated secret is used by the next token
+	// request.
+	Credentials credentials.Provider
+	Scopes      []string
+	// EndpointParams are additional parameters of the token request, such as
+	// an audience.
+	EndpointParams url.Values
+	// Config configures the token requests, for example their http client and
+	// logging. Its Endpoint is replaced with TokenURL, and its endpoint
+	// resolver, region and variant are not used.
+	Config gorequest.Config
+}
+
+// ClientCredentials returns a TokenSource requesting a new token with the
+// client credentials on every call. Wrap it with NewCachedSource to reuse
+// tokens until they expire.
+func ClientCredentials(cfg ClientCredentialsConfig) TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		form := url.Values{"grant_type": {"client_credentials"}}
+		if len(cfg.Scopes) > 0 {
+			form.Set("scope", strings.Join(cfg.Scopes, " "))
+		}
+		for k, v := range cfg.EndpointParams {
+			form[k] = v
+		}
+
+		return retrieveToken(ctx, tokenRequest{
+			config:    cfg.Config,
+			tokenURL:  cfg.TokenURL,
+			operation: "ClientCredentialsToken",
+			client:    clientCredentials(cfg.ClientID, cfg.ClientSecret, cfg.Credentials),
+			form:      form,
+		})
+	})
+}
+
+// RefreshTokenConfig configures the OAuth2 refresh token flow (RFC 6749
+// section 6).
+type RefreshTokenConfig struct {
+	// TokenURL is the url of the token endpoint.
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	// Credentials provides the client id and secret instead of ClientID and
+	// ClientSecret, so that a rotated secret is used by the next token
+	// request.
+	Credentials credentials.Provider
+	// Scopes optionally narrow the scope of the new access tokens.
+	Scopes []string
+	// Config configures the token requests, for example their http client and
+	// logging. Its Endpoint is replaced with TokenURL, and its endpoint
+	// resolver, region and variant are not used.
+	Config gorequest.Config
+}
+
+// RefreshToken returns a TokenSource requesting a new access token with a
+// refresh token on every call. When the token endpoint rotates the refresh
+// token, the new refresh token is used for the following calls. Wrap it with
+// NewCachedSource to reuse tokens until they expire.
+func RefreshToken(cfg RefreshTokenConfig, refreshToken string) TokenSource {
+	return &refreshTokenSource{cfg: cfg, refreshToken: refreshToken}
+}
+
+type refreshTokenSource struct {
+	cfg RefreshTokenConfig
+
+	// mu serializes refreshes, since a rotated refresh token can only be
+	// used once
+	mu           sync.Mutex
+	refreshToken string
+}
+
+func (s *refreshTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {s.refreshToken},
+	}
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+
+	token, err := retrieveToken(ctx, tokenRequest{
+		config:    s.cfg.Config,
+		tokenURL:  s.cfg.TokenURL,
+		operation: "RefreshToken",
+		client:    clientCredentials(s.cfg.ClientID, s.cfg.ClientSecret, s.cfg.Credentials),
+		form:      form,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if token.RefreshToken == "" {
+		token.RefreshToken = s.refreshToken
+	}
+	s.refreshToken = token.RefreshToken
+	return token, nil
+}
+
+type tokenRequest struct {
+	config    gorequest.Config
+	tokenURL  string
+	operation string
+	client    credentials.Provider
+	form      url.Values
+}
+
+// clientCredentials returns provider, or a provider of the static client id
+// and secret when it is nil
+func clientCredentials(clientID, clientSecret string, provider credentials.Provider) credentials.Provider {
+	if provider != nil {
+		return provider
+	}
+	return credentials.Static(credentials.Credentials{ID: clientID, Secret: clientSecret})
+}
+
+// tokenResponse is the successful response of a token endpoint (RFC 6749
+// section 5.1)
+type tokenResponse struct {
+	AccessToken  string      `json:"access_token"`
+	TokenType    string      `json:"token_type"`
+	RefreshToken string      `json:"refresh_token"`
+	ExpiresIn    json.Number `json:"expires_in"`
+}
+
+// retrieveToken sends a token request through gorequest, so that token
+// requests get the same logging and http client as other requests.
+func retrieveToken(ctx context.Context, tr tokenRequest) (*Token, error) {
+	cfg := tr.config
+	cfg.Endpoint = tr.tokenURL
+	// the token url is not resolved, so that a service config with an
+	// endpoint resolver does not send the client credentials to the service
+	cfg.EndpointResolver = nil
+	cfg.Region = ""
+	cfg.EndpointVariant = 0
+
+	hooks := corehooks.Default()
+	hooks.Build.PushBackHook(gorequest.Hook{Name: "auth.TokenRequest", Fn: func(r *gorequest.Request) {
+		body := []byte(tr.form.Encode())
+		r.Request.ContentLength = int64(len(body))
+		r.Request.Body = io.NopCloser(bytes.NewReader(body))
+		r.Request.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		r.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Request.Header.Set("Accept", "application/json")
+
+		client, err := tr.client.Retrieve(r.Context())
+		if err != nil {
+			r.Error = fmt.Errorf("auth: client credentials: %w", err)
+			return
+		}
+		// client credentials are form encoded before they are used as basic
+		// auth credentials (RFC 6749 section 2.3.1)
+		r.Request.SetBasicAuth(url.QueryEscape(client.ID), url.QueryEscape(client.Secret))
+	}})
+	hooks.Unmarshal.PushBackHook(decodeToken)
+
+	out := &tokenResponse{}
+	op := gorequest.Operation{Name: tr.operation, Method: http.MethodPost}
+	req := gorequest.New(cfg, op, hooks, nil, nil, out)
+	req.WithContext(ctx)
+	if err := req.Send(); err != nil {
+		return nil, err
+	}
+
+	token := &Token{
+		AccessToken:  out.AccessToken,
+		TokenType:    out.TokenType,
+		RefreshToken: out.RefreshToken,
+	}
+	if expiresIn, err := out.ExpiresIn.Int64(); err == nil && expiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// decodeToken is an unmarshal hook decoding the response of a token endpoint
+var decodeToken = gorequest.Hook{Name: "auth.DecodeToken", Fn: func(r *gorequest.Request) {
+	defer r.Response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(r.Response.Body, 1<<20))
+	if err != nil {
+		r.Error = err
+		return
+	}
+
+	if r.Response.StatusCode < 200 || r.Response.StatusCode >= 300 {
+		retrieveErr := &RetrieveError{StatusCode: r.Response.StatusCode, Body: body}
+		var errResp struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &errResp) == nil {
+			retrieveErr.ErrorCode = errResp.Error
+			retrieveErr.ErrorDescription = errResp.ErrorDescription
+		}
+		r.Error = retrieveErr
+		return
+	}
+
+	out := r.Data.(*tokenResponse)
+	if err = json.Unmarshal(body, out); err != nil {
+		r.Error = err
+		return
+	}
+	if out.AccessToken == "" {
+		r.Error = errors.New("auth: token response has no access_token")
+	}
+}}
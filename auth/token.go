@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Token is an access token and the information needed to renew it.
+type Token struct {
+	AccessToken string
+	// TokenType is the type of the access token. Defaults to Bearer.
+	TokenType    string
+	RefreshToken string
+	// Expiry is when the access token expires. A zero Expiry means the token
+	// does not expire.
+	Expiry time.Time
+}
+
+// Type returns the token type, as used in the Authorization header.
+func (t *Token) Type() string {
+	if t.TokenType == "" || strings.EqualFold(t.TokenType, "bearer") {
+		return "Bearer"
+	}
+	return t.TokenType
+}
+
+// Valid reports whether the token has an access token that has not expired.
+func (t *Token) Valid() bool {
+	return t != nil && t.AccessToken != "" && !t.expiresWithin(0)
+}
+
+// expiresWithin reports whether the token expires within d
+func (t *Token) expiresWithin(d time.Duration) bool {
+	return !t.Expiry.IsZero() && time.Until(t.Expiry) <= d
+}
+
+// TokenSource returns tokens. Implementations must be safe for concurrent
+// use.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenSourceFunc is an adapter to use a function as a TokenSource.
+type TokenSourceFunc func(ctx context.Context) (*Token, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+// StaticTokenSource returns a TokenSource that always returns token.
+func StaticTokenSource(token *Token) TokenSource {
+	return TokenSourceFunc(func(context.Context) (*Token, error) {
+		return token, nil
+	})
+}
+
+// RetrieveError is the error response of a token endpoint (RFC 6749 section
+// 5.2).
+type RetrieveError struct {
+	StatusCode       int
+	ErrorCode        string
+	ErrorDescription string
+	// Body is the response body, for endpoints that do not respond with the
+	// standard error format.
+	Body []byte
+}
+
+func (e *RetrieveError) Error() string {
+	if e.ErrorCode == "" {
+		return fmt.Sprintf("auth: token request failed with status %d: %s", e.StatusCode, e.Body)
+	}
+	if e.ErrorDescription == "" {
+		return fmt.Sprintf("auth: token request failed with status %d: %s", e.StatusCode, e.ErrorCode)
+	}
+	return fmt.Sprintf("auth: token request failed with status %d: %s: %s", e.StatusCode, e.ErrorCode, e.ErrorDescription)
+}
@@ -0,0 +1,186 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/internal/slots"
+)
+
+var (
+	// ErrFull is the cause of a rejection when the compartment and its queue
+	// are full.
+	ErrFull = errors.New("bulkhead full")
+	// ErrQueueTimeout is the cause of a rejection when the request waited in
+	// the queue for longer than the queue timeout.
+	ErrQueueTimeout = errors.New("bulkhead queue timeout")
+)
+
+// RejectedError is the error of a request rejected by a bulkhead compartment.
+// It is classified as gorequest.ErrorKindRejected and is never retried.
+type RejectedError struct {
+	// Key of the compartment that rejected the request
+	Key string
+	// Err is ErrFull or ErrQueueTimeout
+	Err error
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("bulkhead %q rejected request: %v", e.Key, e.Err)
+}
+
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKind classifies the error as a rejection
+func (e *RejectedError) ErrorKind() gorequest.ErrorKind {
+	return gorequest.ErrorKindRejected
+}
+
+// Limits bound a single compartment.
+type Limits struct {
+	// MaxConcurrent is the number of requests allowed in flight. Defaults to 10.
+	MaxConcurrent int
+	// MaxQueue is the number of requests allowed to wait for a slot. Zero
+	// rejects requests as soon as the compartment is full.
+	MaxQueue int
+	// QueueTimeout is the longest a request waits for a slot. Zero waits until
+	// the request context is done.
+	QueueTimeout time.Duration
+}
+
+// Config configures a Bulkhead.
+type Config struct {
+	// Limits applied to compartments without an override
+	Limits Limits
+	// Overrides are the limits of specific compartment keys
+	Overrides map[string]Limits
+	// Key returns the compartment of a request. Defaults to the operation name.
+	Key func(*gorequest.Request) string
+}
+
+// OperationKey returns the operation name of the request as compartment key.
+func OperationKey(r *gorequest.Request) string {
+	return r.Operation.Name
+}
+
+// New returns a Bulkhead creating its compartments on first use.
+func New(cfg Config) *Bulkhead {
+	if cfg.Key == nil {
+		cfg.Key = OperationKey
+	}
+	return &Bulkhead{cfg: cfg, compartments: make(map[string]*compartment)}
+}
+
+// Bulkhead holds a compartment for every key.
+type Bulkhead struct {
+	cfg Config
+
+	mu           sync.Mutex
+	compartments map[string]*compartment
+}
+
+// InFlight returns the number of requests holding a slot of the compartment.
+func (b *Bulkhead) InFlight(key string) int {
+	return b.compartment(key).slots.InFlight()
+}
+
+// Queued returns the number of requests waiting for a slot of the compartment.
+func (b *Bulkhead) Queued(key string) int {
+	return b.compartment(key).slots.Queued()
+}
+
+func (b *Bulkhead) compartment(key string) *compartment {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.compartments[key]
+	if !ok {
+		limits, ok := b.cfg.Overrides[key]
+		if !ok {
+			limits = b.cfg.Limits
+		}
+		if limits.MaxConcurrent <= 0 {
+			limits.MaxConcurrent = 10
+		}
+		maxConcurrent := limits.MaxConcurrent
+		c = &compartment{
+			key:   key,
+			slots: slots.New(func() int { return maxConcurrent }, limits.MaxQueue, limits.QueueTimeout),
+		}
+		b.compartments[key] = c
+	}
+	return c
+}
+
+// Apply registers the bulkhead hooks. The acquire hook runs before all other
+// send hooks, and the release hook before all other retry and complete hooks,
+// so that the slot is not held while waiting to retry.
+func (b *Bulkhead) Apply(hooks *gorequest.Hooks) {
+	hooks.Send.PushFrontHook(b.Acquire())
+	hooks.Retry.PushFrontHook(b.Release())
+	hooks.Complete.PushFrontHook(b.Release())
+}
+
+type slotKey struct{}
+
+// slot is held by a request while an attempt is sent
+type slot struct {
+	compartment *compartment
+}
+
+// Acquire returns a send hook that takes a slot of the request's compartment
+// before an attempt, unless the request holds one.
+func (b *Bulkhead) Acquire() gorequest.Hook {
+	return gorequest.Hook{Name: "bulkhead.Acquire", Fn: func(r *gorequest.Request) {
+		s, ok := r.Context().Value(slotKey{}).(*slot)
+		if ok && s.compartment != nil {
+			return
+		}
+
+		c := b.compartment(b.cfg.Key(r))
+		if err := c.acquire(r.Context()); err != nil {
+			r.Error = err
+			return
+		}
+		if !ok {
+			s = &slot{}
+			r.WithContext(context.WithValue(r.Context(), slotKey{}, s))
+		}
+		s.compartment = c
+	}}
+}
+
+// Release returns a retry or complete hook that releases the slot of the
+// request.
+func (b *Bulkhead) Release() gorequest.Hook {
+	return gorequest.Hook{Name: "bulkhead.Release", Fn: func(r *gorequest.Request) {
+		s, ok := r.Context().Value(slotKey{}).(*slot)
+		if !ok || s.compartment == nil {
+			return
+		}
+		s.compartment.slots.Release()
+		s.compartment = nil
+	}}
+}
+
+type compartment struct {
+	key   string
+	slots *slots.Queue
+}
+
+func (c *compartment) acquire(ctx context.Context) error {
+	_, err := c.slots.Acquire(ctx)
+	switch {
+	case errors.Is(err, slots.ErrFull):
+		return &RejectedError{Key: c.key, Err: ErrFull}
+	case errors.Is(err, slots.ErrTimeout):
+		return &RejectedError{Key: c.key, Err: ErrQueueTimeout}
+	}
+	return err
+}
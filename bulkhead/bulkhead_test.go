@@ -0,0 +1,174 @@
+package bulkhead_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/bulkhead"
+	"github.com/SirWaithaka/gorequest/corehooks"
+)
+
+func TestBulkhead(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			<-release
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	slow := gorequest.Operation{Name: "Slow", Method: http.MethodGet, Path: "/slow"}
+	fast := gorequest.Operation{Name: "Fast", Method: http.MethodGet, Path: "/fast"}
+
+	newHooks := func(b *bulkhead.Bulkhead) gorequest.Hooks {
+		hooks := corehooks.Default()
+		b.Apply(&hooks)
+		return hooks
+	}
+
+	// fill starts n slow requests and waits for them to hold or wait for slots
+	fill := func(b *bulkhead.Bulkhead, hooks gorequest.Hooks, n int, wg *sync.WaitGroup) {
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := gorequest.New(gorequest.Config{Endpoint: server.URL}, slow, hooks, nil, nil, nil)
+				_ = req.Send()
+			}()
+		}
+		assert.Eventually(t, func() bool {
+			return b.InFlight("Slow")+b.Queued("Slow") == n
+		}, time.Second, time.Millisecond)
+	}
+
+	t.Run("test that a full compartment rejects requests and does not affect others", func(t *testing.T) {
+		b := bulkhead.New(bulkhead.Config{Limits: bulkhead.Limits{MaxConcurrent: 2}})
+		hooks := newHooks(b)
+		release = make(chan struct{})
+
+		var wg sync.WaitGroup
+		fill(b, hooks, 2, &wg)
+
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, slow, hooks, gorequest.DefaultRetryer, nil, nil)
+		req.WithRetryConfig(gorequest.RetryConfig{MaxRetries: 3, InitialDelay: time.Millisecond})
+		err := req.Send()
+
+		var rejected *bulkhead.RejectedError
+		assert.True(t, errors.As(err, &rejected))
+		assert.ErrorIs(t, err, bulkhead.ErrFull)
+		assert.Equal(t, "Slow", rejected.Key)
+		assert.Equal(t, gorequest.ErrorKindRejected, gorequest.Classify(req))
+		// rejected requests are not retried
+		assert.Len(t, req.Attempts, 1)
+
+		// another operation has its own compartment
+		req = gorequest.New(gorequest.Config{Endpoint: server.URL}, fast, hooks, nil, nil, nil)
+		assert.NoError(t, req.Send())
+
+		close(release)
+		wg.Wait()
+		assert.Equal(t, 0, b.InFlight("Slow"))
+	})
+
+	t.Run("test that queued requests wait for a slot", func(t *testing.T) {
+		b := bulkhead.New(bulkhead.Config{Limits: bulkhead.Limits{MaxConcurrent: 1, MaxQueue: 1}})
+		hooks := newHooks(b)
+		release = make(chan struct{})
+
+		var wg sync.WaitGroup
+		fill(b, hooks, 2, &wg)
+		assert.Equal(t, 1, b.Queued("Slow"))
+
+		// the queue is full
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, slow, hooks, nil, nil, nil)
+		assert.ErrorIs(t, req.Send(), bulkhead.ErrFull)
+
+		close(release)
+		wg.Wait()
+		assert.Equal(t, 0, b.InFlight("Slow"))
+		assert.Equal(t, 0, b.Queued("Slow"))
+	})
+
+	t.Run("test that queued requests time out", func(t *testing.T) {
+		b := bulkhead.New(bulkhead.Config{
+			Overrides: map[string]bulkhead.Limits{
+				"Slow": {MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: 10 * time.Millisecond},
+			},
+		})
+		hooks := newHooks(b)
+		release = make(chan struct{})
+
+		var wg sync.WaitGroup
+		fill(b, hooks, 1, &wg)
+
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, slow, hooks, nil, nil, nil)
+		assert.ErrorIs(t, req.Send(), bulkhead.ErrQueueTimeout)
+
+		close(release)
+		wg.Wait()
+	})
+
+	t.Run("test that queued requests honour the request context", func(t *testing.T) {
+		b := bulkhead.New(bulkhead.Config{Limits: bulkhead.Limits{MaxConcurrent: 1, MaxQueue: 1}})
+		hooks := newHooks(b)
+		release = make(chan struct{})
+
+		var wg sync.WaitGroup
+		fill(b, hooks, 1, &wg)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, slow, hooks, nil, nil, nil)
+		req.WithContext(ctx)
+		assert.ErrorIs(t, req.Send(), context.DeadlineExceeded)
+		assert.Equal(t, 0, b.Queued("Slow"))
+
+		close(release)
+		wg.Wait()
+	})
+
+	t.Run("test that the slot is released while waiting to retry", func(t *testing.T) {
+		b := bulkhead.New(bulkhead.Config{Limits: bulkhead.Limits{MaxConcurrent: 1}})
+		hooks := newHooks(b)
+		hooks.Unmarshal.PushBackHook(corehooks.ResponseStatusCode)
+		// record the slots in flight while the request waits to retry
+		var inFlight []int
+		hooks.Retry.PushBack(func(r *gorequest.Request) {
+			inFlight = append(inFlight, b.InFlight("Unavailable"))
+		})
+		retryHook := corehooks.NewRetryer()
+		hooks.Retry.PushBackHook(retryHook.Retry())
+
+		unavailable := gorequest.Operation{Name: "Unavailable", Method: http.MethodGet, Path: "/unavailable"}
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, unavailable, hooks, gorequest.DefaultRetryer, nil, nil)
+		req.WithRetryConfig(gorequest.RetryConfig{MaxRetries: 2, InitialDelay: time.Millisecond, Multiplier: 1, MaxDelay: time.Millisecond})
+		assert.Error(t, req.Send())
+
+		assert.Len(t, req.Attempts, 3)
+		assert.Equal(t, []int{0, 0}, inFlight)
+		assert.Equal(t, 0, b.InFlight("Unavailable"))
+	})
+
+	t.Run("test that a custom key selects the compartment", func(t *testing.T) {
+		b := bulkhead.New(bulkhead.Config{
+			Limits: bulkhead.Limits{MaxConcurrent: 1},
+			Key:    func(r *gorequest.Request) string { return r.Config.ServiceName },
+		})
+		hooks := newHooks(b)
+
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL, ServiceName: "orders"}, fast, hooks, nil, nil, nil)
+		assert.NoError(t, req.Send())
+		assert.Equal(t, 0, b.InFlight("orders"))
+	})
+}
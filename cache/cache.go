@@ -0,0 +1,544 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/corehooks"
+)
+
+// Status describes how the cache produced a response.
+type Status string
+
+const (
+	// StatusHit is a fresh response served from the cache without contacting
+	// the origin.
+	StatusHit Status = "HIT"
+	// StatusMiss is a response from the origin.
+	StatusMiss Status = "MISS"
+	// StatusRevalidated is a stored response the origin confirmed is still
+	// valid with a 304 Not Modified.
+	StatusRevalidated Status = "REVALIDATED"
+	// StatusStale is a stored response served after its freshness lifetime,
+	// either while it is refreshed in the background or because the origin
+	// failed. The Age header tells how old it is.
+	StatusStale Status = "STALE"
+)
+
+// StatusOf returns the cache status of the response of r, or an empty Status
+// if the response did not go through a cache.
+func StatusOf(r *gorequest.Request) Status {
+	return Status(r.CacheStatus())
+}
+
+// Config configures a Cache.
+type Config struct {
+	// Storage stores the cached responses. Defaults to an in-memory storage
+	// holding up to 1000 entries.
+	Storage Storage
+	// Key returns the key a request's response is stored under. Defaults to
+	// the request url. Requests to the same url with a different method use
+	// the same key, so that unsafe requests invalidate the stored response.
+	Key func(*gorequest.Request) string
+	// StaleWhileRevalidate is how long after becoming stale a response may
+	// still be served while it is refreshed in the background, when the
+	// response has no stale-while-revalidate directive (RFC 5861).
+	StaleWhileRevalidate time.Duration
+	// StaleIfError is how long after becoming stale a response may still be
+	// served when the origin fails, when the response has no stale-if-error
+	// directive (RFC 5861).
+	StaleIfError time.Duration
+	// MaxBodySize is the largest response body that is stored, in bytes.
+	// Larger responses are passed through without being stored. Defaults to
+	// DefaultMaxBodySize.
+	MaxBodySize int64
+}
+
+// DefaultMaxBodySize is the default Config.MaxBodySize
+const DefaultMaxBodySize = 10 << 20
+
+// New returns a Cache.
+func New(cfg Config) *Cache {
+	if cfg.Storage == nil {
+		cfg.Storage = NewMemoryStorage(1000, 0)
+	}
+	if cfg.MaxBodySize == 0 {
+		cfg.MaxBodySize = DefaultMaxBodySize
+	}
+	if cfg.Key == nil {
+		cfg.Key = URLKey
+	}
+	return &Cache{cfg: cfg, refreshing: make(map[string]bool)}
+}
+
+// URLKey returns the url of the request.
+func URLKey(r *gorequest.Request) string {
+	return r.Request.URL.String()
+}
+
+// Cache is a private http cache as described by RFC 9111. It serves fresh
+// responses to GET requests from its storage, revalidates stale responses
+// with the origin and stores cacheable responses.
+type Cache struct {
+	cfg Config
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+}
+
+// Apply replaces the core send hook with a caching one and adds the
+// StaleIfError fallback hook.
+func (c *Cache) Apply(hooks *gorequest.Hooks) {
+	hooks.Send.Swap(corehooks.SendHook.Name, c.Wrap(corehooks.SendHook))
+	hooks.Fallback.PushBackHook(c.StaleIfError())
+}
+
+// Wrap returns a send hook that answers requests from the cache and uses
+// send to reach the origin.
+func (c *Cache) Wrap(send gorequest.Hook) gorequest.Hook {
+	return gorequest.Hook{Name: "cache.Send", Fn: func(r *gorequest.Request) {
+		if r.Error != nil {
+			send.Fn(r)
+			return
+		}
+
+		if r.Request.Method != http.MethodGet || conditional(r.Request) {
+			send.Fn(r)
+			c.invalidate(r)
+			return
+		}
+
+		key := c.cfg.Key(r)
+		reqCC := parseCacheControl(r.Request.Header)
+
+		var entry *Entry
+		if !reqCC.has("no-store") {
+			entry = c.lookup(r, key)
+		}
+
+		if entry != nil {
+			age := currentAge(entry, time.Now())
+			if fresh(entry, reqCC, age) {
+				c.serve(r, entry, age, StatusHit)
+				return
+			}
+			stale := age >= freshnessLifetime(entry)
+			if stale && c.usableStale(entry, reqCC, age, "stale-while-revalidate", c.cfg.StaleWhileRevalidate) {
+				c.refreshInBackground(r, send, key, entry)
+				c.serve(r, entry, age, StatusStale)
+				return
+			}
+		}
+
+		if reqCC.has("only-if-cached") {
+			r.Response = gatewayTimeout(r.Request)
+			c.mark(r, StatusMiss)
+			return
+		}
+
+		c.fetch(r, send, key, entry)
+	}}
+}
+
+// StaleIfError returns a fallback hook that recovers a GET request whose
+// origin failed with a stale stored response, once the retries are exhausted.
+// The origin failed when it could not be reached or answered with a 500, 502,
+// 503 or 504 status.
+func (c *Cache) StaleIfError() gorequest.Hook {
+	return gorequest.Hook{Name: "cache.StaleIfError", Fn: func(r *gorequest.Request) {
+		if r.Error == nil || r.Request == nil || r.Request.Method != http.MethodGet || !originFailed(r) {
+			return
+		}
+
+		reqCC := parseCacheControl(r.Request.Header)
+		if reqCC.has("no-store") {
+			return
+		}
+		key := c.cfg.Key(r)
+		entry := c.lookup(r, key)
+		if entry == nil {
+			return
+		}
+		age := currentAge(entry, time.Now())
+		if !c.usableStale(entry, reqCC, age, "stale-if-error", c.cfg.StaleIfError) {
+			return
+		}
+
+		// the stale response is no better than the failure if the unmarshal
+		// hooks reject it
+		stale := entry.response(r.Request)
+		stale.Header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+		if r.Recover(stale) {
+			c.mark(r, StatusStale)
+		}
+	}}
+}
+
+// fetch gets the response from the origin, revalidating entry if there is
+// one, and stores it.
+func (c *Cache) fetch(r *gorequest.Request, send gorequest.Hook, key string, entry *Entry) {
+	requestTime := time.Now()
+	if entry != nil && validators(entry) {
+		c.revalidate(r, send, entry)
+	} else {
+		send.Fn(r)
+	}
+	if r.Error != nil || r.Response == nil {
+		return
+	}
+	responseTime := time.Now()
+
+	if entry != nil && r.Response.StatusCode == http.StatusNotModified {
+		c.refresh(r, key, entry, requestTime, responseTime)
+		return
+	}
+
+	c.store(r, key, requestTime, responseTime)
+	c.mark(r, StatusMiss)
+}
+
+// refreshInBackground fetches a new response for key without holding up the
+// request. Only one refresh per key runs at a time.
+func (c *Cache) refreshInBackground(r *gorequest.Request, send gorequest.Hook, key string, entry *Entry) {
+	c.mu.Lock()
+	if c.refreshing[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.mu.Unlock()
+
+	// the refresh must outlive the request, so it is sent apart from it,
+	// keeping only the values of its context
+	bg := r.Detach()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+
+		c.fetch(bg, send, key, entry)
+		if bg.Error != nil {
+			c.logError(bg, "refresh", bg.Error)
+		}
+		if bg.Response != nil && bg.Response.Body != nil {
+			_ = bg.Response.Body.Close()
+		}
+	}()
+}
+
+// serve answers the request with a stored entry of the given age
+func (c *Cache) serve(r *gorequest.Request, entry *Entry, age time.Duration, status Status) {
+	r.Response = entry.response(r.Request)
+	r.Response.Header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	c.mark(r, status)
+}
+
+// usableStale reports whether the stale entry, at the given age, may be served
+// within the window set by the named response directive, or the configured
+// window when the response has none.
+func (c *Cache) usableStale(e *Entry, reqCC directives, age time.Duration, directive string, window time.Duration) bool {
+	respCC := parseCacheControl(e.Header)
+	if respCC.has("no-cache") || respCC.has("must-revalidate") || reqCC.has("no-cache") {
+		return false
+	}
+	if w, ok := respCC.seconds(directive); ok {
+		window = w
+	}
+	if maxAge, ok := reqCC.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+	return age < freshnessLifetime(e)+window
+}
+
+// failureStatus reports whether the status code tells the origin failed
+func failureStatus(code int) bool {
+	switch code {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// staleAllowed reports whether the entry may ever be served stale
+func (c *Cache) staleAllowed(e *Entry) bool {
+	respCC := parseCacheControl(e.Header)
+	swr, ok := respCC.seconds("stale-while-revalidate")
+	if !ok {
+		swr = c.cfg.StaleWhileRevalidate
+	}
+	sie, ok := respCC.seconds("stale-if-error")
+	if !ok {
+		sie = c.cfg.StaleIfError
+	}
+	return swr > 0 || sie > 0
+}
+
+// originFailed reports whether the request failed because the origin could
+// not be reached or had a server error (RFC 5861 section 4).
+func originFailed(r *gorequest.Request) bool {
+	if r.Response != nil && failureStatus(r.Response.StatusCode) {
+		return true
+	}
+	switch gorequest.Classify(r) {
+	case gorequest.ErrorKindDNS, gorequest.ErrorKindConnectionRefused, gorequest.ErrorKindConnectionReset,
+		gorequest.ErrorKindTLS, gorequest.ErrorKindTimeout:
+		return true
+	}
+	return false
+}
+
+// lookup returns the stored entry for the request, or nil if there is none
+// whose Vary headers match.
+func (c *Cache) lookup(r *gorequest.Request, key string) *Entry {
+	entry, ok, err := c.cfg.Storage.Get(key)
+	if err != nil {
+		c.logError(r, "lookup", err)
+		return nil
+	}
+	if !ok || !varyMatches(entry, r.Request) {
+		return nil
+	}
+	return entry
+}
+
+// revalidate sends a conditional request built from the validators of entry.
+// The request is restored afterwards so that retries and hooks see the
+// original request.
+func (c *Cache) revalidate(r *gorequest.Request, send gorequest.Hook, entry *Entry) {
+	original := r.Request
+	r.Request = original.Clone(r.Context())
+	if etag := entry.Header.Get("ETag"); etag != "" {
+		r.Request.Header.Set("If-None-Match", etag)
+	}
+	if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+		r.Request.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	send.Fn(r)
+	r.Request = original
+	if r.Response != nil {
+		r.Response.Request = original
+	}
+}
+
+// refresh updates entry with the headers of a 304 response and answers the
+// request with the updated entry (RFC 9111 section 4.3.4).
+func (c *Cache) refresh(r *gorequest.Request, key string, entry *Entry, requestTime, responseTime time.Time) {
+	if r.Response.Body != nil {
+		_, _ = io.Copy(io.Discard, r.Response.Body)
+		_ = r.Response.Body.Close()
+	}
+
+	updated := *entry
+	updated.Header = entry.Header.Clone()
+	for name, values := range r.Response.Header {
+		if excludedOnUpdate[name] {
+			continue
+		}
+		updated.Header[name] = values
+	}
+	updated.RequestTime = requestTime
+	updated.ResponseTime = responseTime
+
+	if err := c.cfg.Storage.Set(key, &updated); err != nil {
+		c.logError(r, "store", err)
+	}
+
+	r.Response = updated.response(r.Request)
+	c.mark(r, StatusRevalidated)
+}
+
+// excludedOnUpdate are the 304 response headers that must not replace the
+// stored headers
+var excludedOnUpdate = map[string]bool{
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+// store saves the response if it is cacheable. The response body is read so
+// it is replaced with an in-memory copy, unless it is larger than the
+// configured size and is not stored.
+func (c *Cache) store(r *gorequest.Request, key string, requestTime, responseTime time.Time) {
+	// an origin failure must not replace a response that can be served stale
+	if !storable(r.Request, r.Response) || failureStatus(r.Response.StatusCode) {
+		return
+	}
+	if r.Response.ContentLength > c.cfg.MaxBodySize {
+		return
+	}
+
+	var body []byte
+	if r.Response.Body != nil {
+		orig := r.Response.Body
+		var err error
+		body, err = io.ReadAll(io.LimitReader(orig, c.cfg.MaxBodySize+1))
+		if err == nil && int64(len(body)) > c.cfg.MaxBodySize {
+			// the body is passed on with the bytes already read
+			r.Response.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), orig), orig}
+			return
+		}
+		_ = orig.Close()
+		r.Response.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			r.Error = err
+			return
+		}
+	}
+
+	entry := &Entry{
+		StatusCode:    r.Response.StatusCode,
+		Header:        r.Response.Header.Clone(),
+		Body:          body,
+		RequestHeader: varyHeader(r.Response.Header, r.Request.Header),
+		RequestTime:   requestTime,
+		ResponseTime:  responseTime,
+	}
+	// a response that is never fresh, can not be revalidated and is never
+	// served stale is useless
+	if freshnessLifetime(entry) == 0 && !validators(entry) && !c.staleAllowed(entry) {
+		return
+	}
+	if err := c.cfg.Storage.Set(key, entry); err != nil {
+		c.logError(r, "store", err)
+	}
+}
+
+// invalidate removes the stored response for the url of a successful unsafe
+// request (RFC 9111 section 4.4).
+func (c *Cache) invalidate(r *gorequest.Request) {
+	switch r.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return
+	}
+	if r.Error != nil || r.Response == nil || r.Response.StatusCode >= 400 {
+		return
+	}
+	if err := c.cfg.Storage.Delete(c.cfg.Key(r)); err != nil {
+		c.logError(r, "invalidate", err)
+	}
+}
+
+// mark sets the cache status of the response
+func (c *Cache) mark(r *gorequest.Request, status Status) {
+	r.SetCacheStatus(string(status))
+	if r.Config.LogLevel.AtLeast(gorequest.LogDebug) && r.Config.Logger != nil {
+		r.Config.Logger.Log(fmt.Sprintf("DEBUG: %s cache %s, %s",
+			r.Operation.Name, status, r.Request.URL))
+	}
+}
+
+func (c *Cache) logError(r *gorequest.Request, action string, err error) {
+	if r.Config.LogLevel.AtLeast(gorequest.LogError) && r.Config.Logger != nil {
+		r.Config.Logger.Log(fmt.Sprintf("DEBUG: %s cache %s failed, error %v",
+			r.Operation.Name, action, err))
+	}
+}
+
+// fresh reports whether the entry, at the given age, may be served without
+// revalidation under the request cache directives.
+func fresh(e *Entry, reqCC directives, age time.Duration) bool {
+	respCC := parseCacheControl(e.Header)
+	if respCC.has("no-cache") || reqCC.has("no-cache") {
+		return false
+	}
+
+	remaining := freshnessLifetime(e) - age
+	if maxAge, ok := reqCC.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+	if minFresh, ok := reqCC.seconds("min-fresh"); ok && remaining < minFresh {
+		return false
+	}
+	return remaining > 0
+}
+
+// validators reports whether the entry can be revalidated
+func validators(e *Entry) bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+// conditional reports whether the caller made the request conditional or
+// partial itself, in which case the cache stays out of the way.
+func conditional(req *http.Request) bool {
+	for _, name := range []string{"If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "If-Range", "Range"} {
+		if req.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// varyNames returns the header names listed by the Vary response header
+func varyNames(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// varyHeader returns the request values of the headers named by Vary
+func varyHeader(respHeader, reqHeader http.Header) http.Header {
+	names := varyNames(respHeader)
+	if len(names) == 0 {
+		return nil
+	}
+	h := make(http.Header, len(names))
+	for _, name := range names {
+		if values := reqHeader.Values(name); len(values) > 0 {
+			h[name] = append([]string(nil), values...)
+		}
+	}
+	return h
+}
+
+// varyMatches reports whether the request has the same values for the Vary
+// headers as the request that got the stored response (RFC 9111 section 4.1).
+func varyMatches(e *Entry, req *http.Request) bool {
+	for _, name := range varyNames(e.Header) {
+		if name == "*" {
+			return false
+		}
+		if normalize(req.Header.Values(name)) != normalize(e.RequestHeader.Values(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalize(values []string) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// gatewayTimeout is the response to an only-if-cached request that can not be
+// answered from the cache
+func gatewayTimeout(req *http.Request) *http.Response {
+	e := &Entry{StatusCode: http.StatusGatewayTimeout, Header: http.Header{}}
+	return e.response(req)
+}
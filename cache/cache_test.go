@@ -0,0 +1,496 @@
+package cache_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/cache"
+	"github.com/SirWaithaka/gorequest/corehooks"
+)
+
+type post struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+var decodeJSON = gorequest.Hook{Name: "test.Decode", Fn: func(r *gorequest.Request) {
+	defer r.Response.Body.Close()
+	if err := json.NewDecoder(r.Response.Body).Decode(r.Data); err != nil {
+		r.Error = err
+	}
+}}
+
+func newHooks(c *cache.Cache) gorequest.Hooks {
+	hooks := corehooks.Default()
+	c.Apply(&hooks)
+	hooks.Unmarshal.PushBackHook(decodeJSON)
+	return hooks
+}
+
+func send(t *testing.T, hooks gorequest.Hooks, endpoint string, method string, header http.Header) (*gorequest.Request, *post) {
+	t.Helper()
+	out := &post{}
+	op := gorequest.Operation{Name: "GetPost", Method: method, Path: "/posts/1"}
+	req := gorequest.New(gorequest.Config{Endpoint: endpoint}, op, hooks, nil, nil, out)
+	for name, values := range header {
+		req.Request.Header[name] = values
+	}
+	assert.NoError(t, req.Send())
+	return req, out
+}
+
+func TestCache(t *testing.T) {
+
+	t.Run("test that fresh responses are served from the cache", func(t *testing.T) {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := hits.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = fmt.Fprintf(w, `{"id": 1, "title": "hit %d"}`, n)
+		}))
+		defer server.Close()
+
+		hooks := newHooks(cache.New(cache.Config{}))
+
+		req, out := send(t, hooks, server.URL, http.MethodGet, nil)
+		assert.Equal(t, cache.StatusMiss, cache.StatusOf(req))
+		assert.Equal(t, "hit 1", out.Title)
+
+		req, out = send(t, hooks, server.URL, http.MethodGet, nil)
+		assert.Equal(t, cache.StatusHit, cache.StatusOf(req))
+		assert.Equal(t, "hit 1", out.Title)
+		assert.Equal(t, "0", req.Response.Header.Get("Age"))
+		// the status is not added to the response headers
+		assert.Len(t, req.Response.Header.Values("X-Cache-Status"), 0)
+		assert.Equal(t, int32(1), hits.Load())
+	})
+
+	t.Run("test that stale responses are revalidated", func(t *testing.T) {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Cache-Control", "max-age=0")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.Header().Set("X-Revalidated", "true")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			_, _ = fmt.Fprint(w, `{"id": 1, "title": "original"}`)
+		}))
+		defer server.Close()
+
+		hooks := newHooks(cache.New(cache.Config{}))
+
+		send(t, hooks, server.URL, http.MethodGet, nil)
+		req, out := send(t, hooks, server.URL, http.MethodGet, nil)
+
+		assert.Equal(t, int32(2), hits.Load())
+		assert.Equal(t, cache.StatusRevalidated, cache.StatusOf(req))
+		assert.Equal(t, http.StatusOK, req.Response.StatusCode)
+		assert.Equal(t, "original", out.Title)
+		assert.Equal(t, "true", req.Response.Header.Get("X-Revalidated"))
+		// the caller's request is left without the conditional header
+		assert.Empty(t, req.Request.Header.Get("If-None-Match"))
+	})
+
+	t.Run("test that responses past Expires are revalidated with Last-Modified", func(t *testing.T) {
+		lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+		var conditional atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Expires", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+			w.Header().Set("Last-Modified", lastModified)
+			if r.Header.Get("If-Modified-Since") == lastModified {
+				conditional.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			_, _ = fmt.Fprint(w, `{"id": 1, "title": "original"}`)
+		}))
+		defer server.Close()
+
+		hooks := newHooks(cache.New(cache.Config{}))
+
+		send(t, hooks, server.URL, http.MethodGet, nil)
+		req, out := send(t, hooks, server.URL, http.MethodGet, nil)
+
+		assert.Equal(t, int32(1), conditional.Load())
+		assert.Equal(t, cache.StatusRevalidated, cache.StatusOf(req))
+		assert.Equal(t, "original", out.Title)
+	})
+
+	t.Run("test that responses are stored according to cache control", func(t *testing.T) {
+		tests := map[string]struct {
+			responseHeader http.Header
+			requestHeader  http.Header
+			stored         bool
+		}{
+			"max-age":                 {responseHeader: http.Header{"Cache-Control": {"max-age=60"}}, stored: true},
+			"expires":                 {responseHeader: http.Header{"Expires": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}, stored: true},
+			"heuristic last-modified": {responseHeader: http.Header{"Last-Modified": {time.Now().Add(-24 * time.Hour).UTC().Format(http.TimeFormat)}}, stored: true},
+			"response no-store":       {responseHeader: http.Header{"Cache-Control": {"no-store, max-age=60"}}},
+			"request no-store":        {responseHeader: http.Header{"Cache-Control": {"max-age=60"}}, requestHeader: http.Header{"Cache-Control": {"no-store"}}},
+			"vary star":               {responseHeader: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}}},
+			"no freshness":            {responseHeader: http.Header{}},
+		}
+
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					for k, v := range tc.responseHeader {
+						w.Header()[k] = v
+					}
+					_, _ = fmt.Fprint(w, `{"id": 1}`)
+				}))
+				defer server.Close()
+
+				storage := cache.NewMemoryStorage(0, 0)
+				send(t, newHooks(cache.New(cache.Config{Storage: storage})), server.URL, http.MethodGet, tc.requestHeader)
+
+				_, ok, err := storage.Get(server.URL + "/posts/1")
+				assert.NoError(t, err)
+				assert.Equal(t, tc.stored, ok)
+			})
+		}
+	})
+
+	t.Run("test that request cache directives are respected", func(t *testing.T) {
+		tests := map[string]struct {
+			requestHeader http.Header
+			status        cache.Status
+		}{
+			"no directives": {status: cache.StatusHit},
+			"no-cache":      {requestHeader: http.Header{"Cache-Control": {"no-cache"}}, status: cache.StatusMiss},
+			"max-age":       {requestHeader: http.Header{"Cache-Control": {"max-age=5"}}, status: cache.StatusMiss},
+			"min-fresh":     {requestHeader: http.Header{"Cache-Control": {"min-fresh=3600"}}, status: cache.StatusMiss},
+		}
+
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					// the response is already 10 seconds old
+					w.Header().Set("Cache-Control", "max-age=60")
+					w.Header().Set("Age", "10")
+					_, _ = fmt.Fprint(w, `{"id": 1}`)
+				}))
+				defer server.Close()
+
+				hooks := newHooks(cache.New(cache.Config{}))
+				send(t, hooks, server.URL, http.MethodGet, nil)
+				req, _ := send(t, hooks, server.URL, http.MethodGet, tc.requestHeader)
+
+				assert.Equal(t, tc.status, cache.StatusOf(req))
+			})
+		}
+	})
+
+	t.Run("test that only-if-cached without a stored response is a gateway timeout", func(t *testing.T) {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+		}))
+		defer server.Close()
+
+		hooks := corehooks.Default()
+		cache.New(cache.Config{}).Apply(&hooks)
+
+		op := gorequest.Operation{Name: "GetPost", Method: http.MethodGet}
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, nil, nil, nil)
+		req.Request.Header.Set("Cache-Control", "only-if-cached")
+		assert.NoError(t, req.Send())
+
+		assert.Equal(t, http.StatusGatewayTimeout, req.Response.StatusCode)
+		assert.Equal(t, int32(0), hits.Load())
+	})
+
+	t.Run("test that responses only match requests with the same vary headers", func(t *testing.T) {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+			_, _ = fmt.Fprintf(w, `{"id": 1, "title": %q}`, r.Header.Get("Accept-Language"))
+		}))
+		defer server.Close()
+
+		hooks := newHooks(cache.New(cache.Config{}))
+
+		send(t, hooks, server.URL, http.MethodGet, http.Header{"Accept-Language": {"en"}})
+		req, out := send(t, hooks, server.URL, http.MethodGet, http.Header{"Accept-Language": {"en"}})
+		assert.Equal(t, cache.StatusHit, cache.StatusOf(req))
+		assert.Equal(t, "en", out.Title)
+
+		req, out = send(t, hooks, server.URL, http.MethodGet, http.Header{"Accept-Language": {"fr"}})
+		assert.Equal(t, cache.StatusMiss, cache.StatusOf(req))
+		assert.Equal(t, "fr", out.Title)
+		assert.Equal(t, int32(2), hits.Load())
+	})
+
+	t.Run("test that responses larger than the limit are passed through without being stored", func(t *testing.T) {
+		var hits atomic.Int32
+		title := strings.Repeat("a", 64)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			if r.Header.Get("X-Chunked") != "" {
+				// without a content length the body is read up to the limit
+				w.(http.Flusher).Flush()
+			}
+			_, _ = fmt.Fprintf(w, `{"id": 1, "title": %q}`, title)
+		}))
+		defer server.Close()
+
+		hooks := newHooks(cache.New(cache.Config{MaxBodySize: 32}))
+
+		for _, header := range []http.Header{nil, {"X-Chunked": {"1"}}} {
+			hits.Store(0)
+			for i := 0; i < 2; i++ {
+				req, out := send(t, hooks, server.URL, http.MethodGet, header)
+				assert.Equal(t, cache.StatusMiss, cache.StatusOf(req))
+				assert.Equal(t, title, out.Title)
+			}
+			assert.Equal(t, int32(2), hits.Load())
+		}
+	})
+
+	t.Run("test that unsafe requests invalidate the stored response", func(t *testing.T) {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = fmt.Fprint(w, `{"id": 1}`)
+		}))
+		defer server.Close()
+
+		hooks := newHooks(cache.New(cache.Config{}))
+
+		send(t, hooks, server.URL, http.MethodGet, nil)
+		req, _ := send(t, hooks, server.URL, http.MethodPut, nil)
+		assert.Empty(t, cache.StatusOf(req))
+
+		req, _ = send(t, hooks, server.URL, http.MethodGet, nil)
+		assert.Equal(t, cache.StatusMiss, cache.StatusOf(req))
+		assert.Equal(t, int32(3), hits.Load())
+	})
+}
+
+func TestCache_Stale(t *testing.T) {
+
+	t.Run("test that stale responses are served while they are refreshed", func(t *testing.T) {
+		var hits atomic.Int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := hits.Add(1)
+			if n > 1 {
+				<-release
+			}
+			w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+			_, _ = fmt.Fprintf(w, `{"id": 1, "title": "v%d"}`, n)
+		}))
+		defer server.Close()
+
+		storage := cache.NewMemoryStorage(0, 0)
+		hooks := newHooks(cache.New(cache.Config{Storage: storage}))
+
+		send(t, hooks, server.URL, http.MethodGet, nil)
+
+		// the origin is held up, the stale response is returned anyway
+		req, out := send(t, hooks, server.URL, http.MethodGet, nil)
+		assert.Equal(t, cache.StatusStale, cache.StatusOf(req))
+		assert.Equal(t, "v1", out.Title)
+
+		// only one refresh runs at a time
+		send(t, hooks, server.URL, http.MethodGet, nil)
+		close(release)
+
+		assert.Eventually(t, func() bool {
+			entry, ok, _ := storage.Get(server.URL + "/posts/1")
+			return ok && string(entry.Body) == `{"id": 1, "title": "v2"}`
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, int32(2), hits.Load())
+	})
+
+	t.Run("test that stale-while-revalidate windows are respected", func(t *testing.T) {
+		tests := map[string]struct {
+			cacheControl string
+			window       time.Duration
+			status       cache.Status
+		}{
+			"response directive":          {cacheControl: "max-age=0, stale-while-revalidate=60", status: cache.StatusStale},
+			"configured window":           {cacheControl: "max-age=0", window: time.Minute, status: cache.StatusStale},
+			"directive overrides config":  {cacheControl: "max-age=0, stale-while-revalidate=0", window: time.Minute, status: cache.StatusMiss},
+			"past the window":             {cacheControl: "max-age=0", window: time.Nanosecond, status: cache.StatusMiss},
+			"must-revalidate":             {cacheControl: "max-age=0, must-revalidate, stale-while-revalidate=60", status: cache.StatusMiss},
+			"no stale serving by default": {cacheControl: "max-age=0", status: cache.StatusMiss},
+		}
+
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Cache-Control", tc.cacheControl)
+					_, _ = fmt.Fprint(w, `{"id": 1}`)
+				}))
+				defer server.Close()
+
+				hooks := newHooks(cache.New(cache.Config{StaleWhileRevalidate: tc.window}))
+				send(t, hooks, server.URL, http.MethodGet, nil)
+				time.Sleep(time.Millisecond)
+				req, _ := send(t, hooks, server.URL, http.MethodGet, nil)
+
+				assert.Equal(t, tc.status, cache.StatusOf(req))
+			})
+		}
+	})
+
+	t.Run("test that stale responses are served once retries of a failed origin are exhausted", func(t *testing.T) {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=0, stale-if-error=60")
+			if hits.Add(1) > 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = fmt.Fprint(w, `{"id": 1, "title": "original"}`)
+		}))
+		defer server.Close()
+
+		hooks := newHooks(cache.New(cache.Config{}))
+		hooks.Unmarshal.PushFrontHook(corehooks.ResponseStatusCode)
+		retryHook := corehooks.NewRetryer()
+		hooks.Retry.PushBackHook(retryHook.Retry())
+		op := gorequest.Operation{Name: "GetPost", Method: http.MethodGet, Path: "/posts/1"}
+
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, gorequest.DefaultRetryer, nil, &post{})
+		assert.NoError(t, req.Send())
+
+		out := &post{}
+		req = gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, gorequest.DefaultRetryer, nil, out)
+		req.WithRetryConfig(gorequest.RetryConfig{MaxRetries: 2, InitialDelay: time.Millisecond, Multiplier: 1, MaxDelay: time.Millisecond})
+		assert.NoError(t, req.Send())
+
+		assert.Len(t, req.Attempts, 3)
+		assert.Equal(t, cache.StatusStale, cache.StatusOf(req))
+		assert.Equal(t, http.StatusOK, req.Response.StatusCode)
+		assert.Equal(t, "original", out.Title)
+	})
+
+	t.Run("test that stale responses are served when the origin can not be reached", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=0")
+			_, _ = fmt.Fprint(w, `{"id": 1, "title": "original"}`)
+		}))
+
+		hooks := newHooks(cache.New(cache.Config{StaleIfError: time.Minute}))
+		send(t, hooks, server.URL, http.MethodGet, nil)
+		server.Close()
+
+		req, out := send(t, hooks, server.URL, http.MethodGet, nil)
+		assert.Equal(t, cache.StatusStale, cache.StatusOf(req))
+		assert.Equal(t, "original", out.Title)
+	})
+
+	t.Run("test that stale responses are not served for other failures", func(t *testing.T) {
+		tests := map[string]struct {
+			cacheControl string
+			status       int
+		}{
+			"client error":    {cacheControl: "max-age=0, stale-if-error=60", status: http.StatusNotFound},
+			"outside window":  {cacheControl: "max-age=0", status: http.StatusInternalServerError},
+			"must-revalidate": {cacheControl: "max-age=0, must-revalidate, stale-if-error=60", status: http.StatusInternalServerError},
+			"not implemented": {cacheControl: "max-age=0, stale-if-error=60", status: http.StatusNotImplemented},
+		}
+
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				var hits atomic.Int32
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Cache-Control", tc.cacheControl)
+					if hits.Add(1) > 1 {
+						w.WriteHeader(tc.status)
+						return
+					}
+					_, _ = fmt.Fprint(w, `{"id": 1}`)
+				}))
+				defer server.Close()
+
+				hooks := newHooks(cache.New(cache.Config{}))
+				hooks.Unmarshal.PushFrontHook(corehooks.ResponseStatusCode)
+				send(t, hooks, server.URL, http.MethodGet, nil)
+
+				op := gorequest.Operation{Name: "GetPost", Method: http.MethodGet, Path: "/posts/1"}
+				req := gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, nil, nil, &post{})
+				assert.Error(t, req.Send())
+				assert.Equal(t, tc.status, req.Response.StatusCode)
+			})
+		}
+	})
+}
+
+func TestMemoryStorage(t *testing.T) {
+
+	t.Run("test that the least recently used entries are evicted", func(t *testing.T) {
+		storage := cache.NewMemoryStorage(2, 0)
+
+		assert.NoError(t, storage.Set("a", &cache.Entry{}))
+		assert.NoError(t, storage.Set("b", &cache.Entry{}))
+		_, _, _ = storage.Get("a")
+		assert.NoError(t, storage.Set("c", &cache.Entry{}))
+
+		_, ok, _ := storage.Get("b")
+		assert.False(t, ok)
+		_, ok, _ = storage.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, 2, storage.Len())
+	})
+
+	t.Run("test that entries are evicted to stay under the byte limit", func(t *testing.T) {
+		storage := cache.NewMemoryStorage(0, 10)
+
+		assert.NoError(t, storage.Set("a", &cache.Entry{Body: []byte("12345")}))
+		assert.NoError(t, storage.Set("b", &cache.Entry{Body: []byte("12345")}))
+		assert.NoError(t, storage.Set("c", &cache.Entry{Body: []byte("12345")}))
+		assert.Equal(t, 2, storage.Len())
+
+		// an entry larger than the limit is never stored
+		assert.NoError(t, storage.Set("d", &cache.Entry{Body: []byte("12345678901")}))
+		_, ok, _ := storage.Get("d")
+		assert.False(t, ok)
+	})
+}
+
+func TestDiskStorage(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := cache.NewDiskStorage(dir)
+	assert.NoError(t, err)
+
+	entry := &cache.Entry{
+		StatusCode:   http.StatusOK,
+		Header:       http.Header{"Etag": {`"v1"`}},
+		Body:         []byte(`{"id": 1}`),
+		ResponseTime: time.Now().UTC().Truncate(time.Second),
+	}
+	assert.NoError(t, storage.Set("https://example.com/posts/1", entry))
+
+	// entries survive a new storage on the same directory
+	reopened, err := cache.NewDiskStorage(dir)
+	assert.NoError(t, err)
+	got, ok, err := reopened.Get("https://example.com/posts/1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, entry, got)
+
+	assert.NoError(t, reopened.Delete("https://example.com/posts/1"))
+	_, ok, err = reopened.Get("https://example.com/posts/1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, reopened.Delete("https://example.com/posts/1"))
+}
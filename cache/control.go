@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// directives are parsed Cache-Control directives. Directive names are lower
+// case and directives without a value map to an empty string.
+type directives map[string]string
+
+func parseCacheControl(header http.Header) directives {
+	d := directives{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, arg, _ := strings.Cut(part, "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if _, ok := d[name]; ok {
+				// the first occurrence of a directive wins
+				continue
+			}
+			d[name] = strings.Trim(strings.TrimSpace(arg), `"`)
+		}
+	}
+	return d
+}
+
+func (d directives) has(name string) bool {
+	_, ok := d[name]
+	return ok
+}
+
+// seconds returns the delta-seconds value of a directive
+func (d directives) seconds(name string) (time.Duration, bool) {
+	value, ok := d[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// heuristicStatusCodes are the status codes cacheable without explicit
+// freshness information (RFC 9110 section 15.1)
+var heuristicStatusCodes = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// maxHeuristicLifetime caps the heuristic freshness lifetime
+const maxHeuristicLifetime = 24 * time.Hour
+
+// storable reports whether a response to the request may be stored by a
+// private cache (RFC 9111 section 3).
+func storable(req *http.Request, resp *http.Response) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	respCC := parseCacheControl(resp.Header)
+	if reqCC.has("no-store") || respCC.has("no-store") {
+		return false
+	}
+
+	// a Vary of * never matches a later request
+	if strings.TrimSpace(resp.Header.Get("Vary")) == "*" {
+		return false
+	}
+
+	if heuristicStatusCodes[resp.StatusCode] {
+		return true
+	}
+	// other final status codes need explicit freshness
+	_, hasExpires := parseHTTPTime(resp.Header.Get("Expires"))
+	return resp.StatusCode >= 200 && resp.StatusCode != http.StatusPartialContent &&
+		(respCC.has("max-age") || respCC.has("public") || hasExpires)
+}
+
+// freshnessLifetime returns how long the entry is fresh for (RFC 9111
+// section 4.2.1). Being a private cache, s-maxage is ignored.
+func freshnessLifetime(e *Entry) time.Duration {
+	cc := parseCacheControl(e.Header)
+	if maxAge, ok := cc.seconds("max-age"); ok {
+		return maxAge
+	}
+
+	date, hasDate := parseHTTPTime(e.Header.Get("Date"))
+	if !hasDate {
+		date = e.ResponseTime
+	}
+
+	if expires := e.Header.Get("Expires"); expires != "" {
+		t, ok := parseHTTPTime(expires)
+		if !ok {
+			// an invalid Expires, such as 0, means already expired
+			return 0
+		}
+		return max(t.Sub(date), 0)
+	}
+
+	// heuristic freshness from the time since the last modification
+	if heuristicStatusCodes[e.StatusCode] {
+		if lastModified, ok := parseHTTPTime(e.Header.Get("Last-Modified")); ok && lastModified.Before(date) {
+			return min(date.Sub(lastModified)/10, maxHeuristicLifetime)
+		}
+	}
+	return 0
+}
+
+// currentAge returns the age of the entry at now (RFC 9111 section 4.2.3).
+func currentAge(e *Entry, now time.Time) time.Duration {
+	var ageValue time.Duration
+	if age, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && age > 0 {
+		ageValue = time.Duration(age) * time.Second
+	}
+
+	var apparentAge time.Duration
+	if date, ok := parseHTTPTime(e.Header.Get("Date")); ok {
+		apparentAge = max(e.ResponseTime.Sub(date), 0)
+	}
+
+	responseDelay := e.ResponseTime.Sub(e.RequestTime)
+	correctedAge := ageValue + responseDelay
+	initialAge := max(apparentAge, correctedAge)
+	residentTime := now.Sub(e.ResponseTime)
+
+	return initialAge + residentTime
+}
+
+func parseHTTPTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,231 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Entry is a stored response.
+type Entry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// RequestHeader holds the request values of the headers named by the
+	// response Vary header.
+	RequestHeader http.Header
+	// RequestTime is the time the request that got the response was sent.
+	RequestTime time.Time
+	// ResponseTime is the time the response was received.
+	ResponseTime time.Time
+}
+
+// size is an estimate of the memory held by the entry
+func (e *Entry) size() int64 {
+	n := int64(len(e.Body))
+	for _, h := range []http.Header{e.Header, e.RequestHeader} {
+		for k, values := range h {
+			n += int64(len(k))
+			for _, v := range values {
+				n += int64(len(v))
+			}
+		}
+	}
+	return n
+}
+
+// response returns a new http response for the entry
+func (e *Entry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// Storage stores cache entries by key. Implementations must be safe for
+// concurrent use.
+type Storage interface {
+	// Get returns the entry stored under key. ok is false if there is none.
+	Get(key string) (entry *Entry, ok bool, err error)
+	// Set stores entry under key, replacing any existing entry.
+	Set(key string, entry *Entry) error
+	// Delete removes the entry stored under key, if any.
+	Delete(key string) error
+}
+
+// NewMemoryStorage returns a Storage that keeps entries in memory and evicts
+// the least recently used entries once it holds more than maxEntries entries
+// or maxBytes of response data. A limit of zero means no limit.
+func NewMemoryStorage(maxEntries int, maxBytes int64) *MemoryStorage {
+	return &MemoryStorage{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// MemoryStorage is an in-memory least recently used Storage.
+type MemoryStorage struct {
+	maxEntries int
+	maxBytes   int64
+
+	mu    sync.Mutex
+	size  int64
+	lru   *list.List
+	items map[string]*list.Element
+}
+
+type memoryItem struct {
+	key   string
+	entry *Entry
+	size  int64
+}
+
+func (s *MemoryStorage) Get(key string) (*Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	s.lru.MoveToFront(el)
+	return el.Value.(*memoryItem).entry, true, nil
+}
+
+func (s *MemoryStorage) Set(key string, entry *Entry) error {
+	item := &memoryItem{key: key, entry: entry, size: entry.size()}
+	if s.maxBytes > 0 && item.size > s.maxBytes {
+		// never fits, drop any older version instead
+		return s.Delete(key)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	s.items[key] = s.lru.PushFront(item)
+	s.size += item.size
+
+	for (s.maxEntries > 0 && s.lru.Len() > s.maxEntries) || (s.maxBytes > 0 && s.size > s.maxBytes) {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+func (s *MemoryStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+// Len returns the number of stored entries.
+func (s *MemoryStorage) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// remove deletes el from the storage. s.mu must be held.
+func (s *MemoryStorage) remove(el *list.Element) {
+	item := s.lru.Remove(el).(*memoryItem)
+	delete(s.items, item.key)
+	s.size -= item.size
+}
+
+// NewDiskStorage returns a Storage that keeps each entry in a file under dir.
+// The directory is created if it does not exist.
+func NewDiskStorage(dir string) (*DiskStorage, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("cache: creating storage directory: %w", err)
+	}
+	return &DiskStorage{dir: dir}, nil
+}
+
+// DiskStorage is a Storage that persists entries as files in a directory, so
+// that they survive restarts. Entries are written atomically.
+type DiskStorage struct {
+	dir string
+}
+
+func (s *DiskStorage) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
+
+func (s *DiskStorage) Get(key string) (*Entry, bool, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	var stored diskEntry
+	if err = gob.NewDecoder(f).Decode(&stored); err != nil {
+		return nil, false, fmt.Errorf("cache: decoding %s: %w", f.Name(), err)
+	}
+	// guard against hash collisions
+	if stored.Key != key {
+		return nil, false, nil
+	}
+	return &stored.Entry, true, nil
+}
+
+func (s *DiskStorage) Set(key string, entry *Entry) error {
+	f, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err = gob.NewEncoder(f).Encode(diskEntry{Key: key, Entry: *entry}); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("cache: encoding entry: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path(key))
+}
+
+func (s *DiskStorage) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// diskEntry is the file format of a DiskStorage entry
+type diskEntry struct {
+	Key   string
+	Entry Entry
+}
@@ -0,0 +1,215 @@
+package coalesce
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/corehooks"
+)
+
+// Config configures a Coalescer.
+type Config struct {
+	// Key returns the key of requests sharing an in-flight call. Defaults to
+	// the method, url, the credentials of the request and the values of
+	// Headers.
+	Key func(*gorequest.Request) string
+	// Headers whose values are part of the default key, such as Accept, in
+	// addition to the credential headers Authorization and Cookie, so that
+	// different users never share a response.
+	Headers []string
+	// Methods that may be coalesced. Defaults to GET and HEAD. Only safe
+	// methods should be coalesced.
+	Methods []string
+	// MaxBodySize is the largest response body the shared call buffers for
+	// its callers, in bytes. Calls with larger bodies fail with
+	// ErrBodyTooLarge. Defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+}
+
+// DefaultMaxBodySize is the default Config.MaxBodySize
+const DefaultMaxBodySize = 10 << 20
+
+// ErrBodyTooLarge is the error of a shared call whose response body is larger
+// than Config.MaxBodySize.
+var ErrBodyTooLarge = errors.New("coalesce: response body too large")
+
+// New returns a Coalescer.
+func New(cfg Config) *Coalescer {
+	if cfg.Methods == nil {
+		cfg.Methods = []string{http.MethodGet, http.MethodHead}
+	}
+	if cfg.MaxBodySize == 0 {
+		cfg.MaxBodySize = DefaultMaxBodySize
+	}
+	headers := []string{"Authorization", "Cookie"}
+	for _, name := range cfg.Headers {
+		if name = http.CanonicalHeaderKey(name); !slices.Contains(headers, name) {
+			headers = append(headers, name)
+		}
+	}
+	cfg.Headers = headers
+	c := &Coalescer{cfg: cfg, calls: make(map[string]*call)}
+	if c.cfg.Key == nil {
+		c.cfg.Key = c.defaultKey
+	}
+	return c
+}
+
+// Coalescer shares a single in-flight http call between identical requests.
+// Every request gets its own copy of the response, with a body it can read
+// and unmarshal independently.
+type Coalescer struct {
+	cfg Config
+
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// call is an in-flight http call and its result
+type call struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+
+	response *http.Response
+	body     []byte
+	err      error
+}
+
+// Apply replaces the core send hook with a coalescing one.
+func (c *Coalescer) Apply(hooks *gorequest.Hooks) {
+	hooks.Send.Swap(corehooks.SendHook.Name, c.Wrap(corehooks.SendHook))
+}
+
+// Wrap returns a send hook that coalesces requests and uses send to make the
+// shared call. Requests that can not be coalesced are sent with send directly.
+func (c *Coalescer) Wrap(send gorequest.Hook) gorequest.Hook {
+	return gorequest.Hook{Name: "coalesce.Send", Fn: func(r *gorequest.Request) {
+		if r.Error != nil || !slices.Contains(c.cfg.Methods, r.Request.Method) || !bodyless(r.Request) {
+			send.Fn(r)
+			return
+		}
+
+		key := c.cfg.Key(r)
+
+		c.mu.Lock()
+		cl, ok := c.calls[key]
+		if !ok {
+			cl = c.start(key, r, send)
+		}
+		cl.waiters++
+		c.mu.Unlock()
+
+		ctx := r.Context()
+		select {
+		case <-cl.done:
+			r.Response, r.Error = cl.result()
+		case <-ctx.Done():
+			c.leave(cl)
+			r.Response, r.Error = nil, context.Cause(ctx)
+		}
+	}}
+}
+
+// start makes the shared call for key. c.mu must be held.
+func (c *Coalescer) start(key string, r *gorequest.Request, send gorequest.Hook) *call {
+	// the shared call must outlive any single caller, so it is sent apart
+	// from the first caller, keeping only the values of its context
+	leader := r.Detach()
+	ctx, cancel := context.WithCancel(leader.Context())
+	leader.WithContext(ctx)
+	cl := &call{done: make(chan struct{}), cancel: cancel}
+	c.calls[key] = cl
+
+	go func() {
+		defer cancel()
+
+		send.Fn(leader)
+		cl.err = leader.Error
+		if leader.Response != nil {
+			cl.response = leader.Response
+			if leader.Response.Body != nil {
+				var err error
+				cl.body, err = c.readBody(leader.Response.Body)
+				_ = leader.Response.Body.Close()
+				if cl.err == nil {
+					cl.err = err
+				}
+			}
+		}
+
+		c.mu.Lock()
+		if c.calls[key] == cl {
+			delete(c.calls, key)
+		}
+		c.mu.Unlock()
+		close(cl.done)
+	}()
+
+	return cl
+}
+
+// readBody reads body up to the configured size
+func (c *Coalescer) readBody(body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, c.cfg.MaxBodySize+1))
+	if err == nil && int64(len(data)) > c.cfg.MaxBodySize {
+		return nil, ErrBodyTooLarge
+	}
+	return data, err
+}
+
+// leave removes a waiter that gave up. The shared call is canceled once no
+// waiters are left.
+func (c *Coalescer) leave(cl *call) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cl.waiters--
+	if cl.waiters == 0 {
+		cl.cancel()
+		for key, inFlight := range c.calls {
+			if inFlight == cl {
+				delete(c.calls, key)
+			}
+		}
+	}
+}
+
+// result returns a copy of the shared response with its own body reader
+func (cl *call) result() (*http.Response, error) {
+	if cl.response == nil {
+		return nil, cl.err
+	}
+
+	resp := new(http.Response)
+	*resp = *cl.response
+	resp.Header = cl.response.Header.Clone()
+	resp.Trailer = cl.response.Trailer.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(cl.body))
+	return resp, cl.err
+}
+
+func (c *Coalescer) defaultKey(r *gorequest.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Request.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.Request.URL.String())
+	for _, name := range c.cfg.Headers {
+		b.WriteByte('\n')
+		b.WriteString(http.CanonicalHeaderKey(name))
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Request.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+func bodyless(r *http.Request) bool {
+	return r.Body == nil || r.Body == http.NoBody
+}
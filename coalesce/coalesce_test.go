@@ -0,0 +1,233 @@
+package coalesce_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/coalesce"
+	"github.com/SirWaithaka/gorequest/corehooks"
+)
+
+type post struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+var decodeJSON = gorequest.Hook{Name: "test.Decode", Fn: func(r *gorequest.Request) {
+	defer r.Response.Body.Close()
+	if err := json.NewDecoder(r.Response.Body).Decode(r.Data); err != nil {
+		r.Error = err
+	}
+}}
+
+func newServer(hits *atomic.Int32, release <-chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if release != nil {
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"id": 1, "title": %q}`, r.Header.Get("Accept-Language")+r.Method)
+	}))
+}
+
+func TestCoalescer(t *testing.T) {
+
+	t.Run("test that identical requests share one call", func(t *testing.T) {
+		var hits atomic.Int32
+		release := make(chan struct{})
+		server := newServer(&hits, release)
+		defer server.Close()
+
+		c := coalesce.New(coalesce.Config{})
+		hooks := corehooks.Default()
+		c.Apply(&hooks)
+		hooks.Unmarshal.PushBackHook(decodeJSON)
+
+		op := gorequest.Operation{Name: "GetPost", Method: http.MethodGet, Path: "/posts/1"}
+
+		const n = 10
+		results := make([]*post, n)
+		errs := make([]error, n)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = &post{}
+				req := gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, nil, nil, results[i])
+				errs[i] = req.Send()
+			}(i)
+		}
+
+		// let all requests join the call before the server responds
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), hits.Load())
+		for i := 0; i < n; i++ {
+			assert.NoError(t, errs[i])
+			assert.Equal(t, &post{ID: 1, Title: http.MethodGet}, results[i])
+		}
+	})
+
+	t.Run("test that a canceled caller does not cancel the call for others", func(t *testing.T) {
+		var hits atomic.Int32
+		release := make(chan struct{})
+		server := newServer(&hits, release)
+		defer server.Close()
+
+		c := coalesce.New(coalesce.Config{})
+		hooks := corehooks.Default()
+		c.Apply(&hooks)
+		hooks.Unmarshal.PushBackHook(decodeJSON)
+
+		op := gorequest.Operation{Method: http.MethodGet, Path: "/posts/1"}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		canceled := make(chan error)
+		go func() {
+			req := gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, nil, nil, &post{})
+			req.WithContext(ctx)
+			canceled <- req.Send()
+		}()
+		assert.Eventually(t, func() bool { return hits.Load() == 1 }, time.Second, time.Millisecond)
+
+		result := &post{}
+		done := make(chan error)
+		go func() {
+			req := gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, nil, nil, result)
+			done <- req.Send()
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		cancel()
+		assert.ErrorIs(t, <-canceled, context.Canceled)
+
+		close(release)
+		assert.NoError(t, <-done)
+		assert.Equal(t, 1, result.ID)
+		assert.Equal(t, int32(1), hits.Load())
+	})
+
+	t.Run("test that unsafe methods and different keys are not coalesced", func(t *testing.T) {
+		var hits atomic.Int32
+		server := newServer(&hits, nil)
+		defer server.Close()
+
+		c := coalesce.New(coalesce.Config{Headers: []string{"Accept-Language"}})
+		hooks := corehooks.Default()
+		c.Apply(&hooks)
+		hooks.Unmarshal.PushBackHook(decodeJSON)
+
+		send := func(method, lang string) *post {
+			result := &post{}
+			op := gorequest.Operation{Method: method, Path: "/posts/1"}
+			req := gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, nil, nil, result)
+			req.Request.Header.Set("Accept-Language", lang)
+			assert.NoError(t, req.Send())
+			return result
+		}
+
+		assert.Equal(t, "en"+http.MethodPost, send(http.MethodPost, "en").Title)
+		assert.Equal(t, "en"+http.MethodGet, send(http.MethodGet, "en").Title)
+		assert.Equal(t, "fr"+http.MethodGet, send(http.MethodGet, "fr").Title)
+		assert.Equal(t, int32(3), hits.Load())
+	})
+
+	t.Run("test that requests of different users are not coalesced", func(t *testing.T) {
+		var hits atomic.Int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			<-release
+			_, _ = fmt.Fprintf(w, `{"id": 1, "title": %q}`, r.Header.Get("Authorization")+r.Header.Get("Cookie"))
+		}))
+		defer server.Close()
+
+		c := coalesce.New(coalesce.Config{})
+		hooks := corehooks.Default()
+		c.Apply(&hooks)
+		hooks.Unmarshal.PushBackHook(decodeJSON)
+
+		op := gorequest.Operation{Method: http.MethodGet, Path: "/me"}
+		headers := map[string][2]string{
+			"Bearer alice": {"Authorization", "Bearer alice"},
+			"Bearer bob":   {"Authorization", "Bearer bob"},
+			"session=bob":  {"Cookie", "session=bob"},
+		}
+
+		var wg sync.WaitGroup
+		results := make(map[string]*post)
+		for user, header := range headers {
+			result := &post{}
+			results[user] = result
+			req := gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, nil, nil, result)
+			req.Request.Header.Set(header[0], header[1])
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, req.Send())
+			}()
+		}
+
+		assert.Eventually(t, func() bool { return hits.Load() == int32(len(headers)) }, time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		for user, result := range results {
+			assert.Equal(t, user, result.Title)
+		}
+	})
+
+	t.Run("test that the shared call does not change the request of the first caller", func(t *testing.T) {
+		var hits atomic.Int32
+		release := make(chan struct{})
+		server := newServer(&hits, release)
+		defer server.Close()
+
+		c := coalesce.New(coalesce.Config{})
+		hooks := corehooks.Default()
+		hooks.Build.PushBackHook(corehooks.TraceConnection)
+		c.Apply(&hooks)
+		hooks.Unmarshal.PushBackHook(decodeJSON)
+
+		op := gorequest.Operation{Method: http.MethodGet, Path: "/posts/1"}
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, nil, nil, &post{})
+		done := make(chan error)
+		go func() {
+			done <- req.Send()
+		}()
+		assert.Eventually(t, func() bool { return hits.Load() == 1 }, time.Second, time.Millisecond)
+
+		close(release)
+		assert.NoError(t, <-done)
+		// the connection was made by the shared call
+		assert.True(t, req.Timing().IsZero())
+	})
+
+	t.Run("test that bodies larger than the limit fail the call", func(t *testing.T) {
+		var hits atomic.Int32
+		server := newServer(&hits, nil)
+		defer server.Close()
+
+		c := coalesce.New(coalesce.Config{MaxBodySize: 8})
+		hooks := corehooks.Default()
+		c.Apply(&hooks)
+
+		op := gorequest.Operation{Method: http.MethodGet, Path: "/posts/1"}
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, nil, nil, &post{})
+		assert.ErrorIs(t, req.Send(), coalesce.ErrBodyTooLarge)
+	})
+}
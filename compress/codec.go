@@ -0,0 +1,170 @@
+package compress
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec decodes an http content coding.
+type Codec interface {
+	// Encoding returns the content coding token, as used in the
+	// Content-Encoding and Accept-Encoding headers.
+	Encoding() string
+	// NewReader returns a reader that decodes r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// Encoder encodes an http content coding.
+type Encoder interface {
+	// Encoding returns the content coding token.
+	Encoding() string
+	// NewWriter returns a writer that encodes to w. Closing the writer
+	// flushes the encoded data to w, but does not close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// DefaultCodecs returns the gzip, zstd, brotli and deflate codecs, in the
+// order they are preferred in the Accept-Encoding header.
+func DefaultCodecs() []Codec {
+	return []Codec{Gzip(), Zstd(), Brotli(), Deflate()}
+}
+
+// The codecs returned by Gzip, Deflate, Brotli and Zstd are Encoders as well.
+// Their writers come from a pool and go back to it when closed.
+
+// Gzip returns the gzip codec.
+func Gzip() Codec { return gzipCodec{} }
+
+// Deflate returns the deflate codec. Deflate is meant to be the zlib format,
+// but some servers send raw deflate data, which is decoded as well.
+func Deflate() Codec { return deflateCodec{} }
+
+// Brotli returns the br codec.
+func Brotli() Codec { return brotliCodec{} }
+
+// Zstd returns the zstd codec.
+func Zstd() Codec { return zstdCodec{} }
+
+type gzipCodec struct{}
+
+func (gzipCodec) Encoding() string { return "gzip" }
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+var gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	gw := gzipWriters.Get().(*gzip.Writer)
+	gw.Reset(w)
+	return &pooledWriter{w: gw, pool: &gzipWriters}, nil
+}
+
+type deflateCodec struct{}
+
+func (deflateCodec) Encoding() string { return "deflate" }
+
+func (deflateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if isZlibHeader(header) {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+var zlibWriters = sync.Pool{New: func() any { return zlib.NewWriter(nil) }}
+
+func (deflateCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	zw := zlibWriters.Get().(*zlib.Writer)
+	zw.Reset(w)
+	return &pooledWriter{w: zw, pool: &zlibWriters}, nil
+}
+
+// isZlibHeader reports whether b starts with a zlib header using the deflate
+// method (RFC 1950 section 2.2)
+func isZlibHeader(b []byte) bool {
+	return len(b) == 2 && b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
+
+type brotliCodec struct{}
+
+func (brotliCodec) Encoding() string { return "br" }
+
+func (brotliCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(brotli.NewReader(r)), nil
+}
+
+var brotliWriters = sync.Pool{New: func() any { return brotli.NewWriter(nil) }}
+
+func (brotliCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	bw := brotliWriters.Get().(*brotli.Writer)
+	bw.Reset(w)
+	return &pooledWriter{w: bw, pool: &brotliWriters}, nil
+}
+
+type zstdCodec struct{}
+
+func (zstdCodec) Encoding() string { return "zstd" }
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
+
+var zstdWriters = sync.Pool{New: func() any {
+	// only fails for invalid options
+	zw, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	return zw
+}}
+
+func (zstdCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	zw := zstdWriters.Get().(*zstd.Encoder)
+	zw.Reset(w)
+	return &pooledWriter{w: zw, pool: &zstdWriters}, nil
+}
+
+// resetWriter is a compressing writer that can be reused for a new stream
+type resetWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// pooledWriter returns its writer to the pool when closed
+type pooledWriter struct {
+	w    resetWriter
+	pool *sync.Pool
+}
+
+func (p *pooledWriter) Write(b []byte) (int, error) {
+	if p.w == nil {
+		return 0, errClosed
+	}
+	return p.w.Write(b)
+}
+
+func (p *pooledWriter) Close() error {
+	if p.w == nil {
+		return errClosed
+	}
+	err := p.w.Close()
+	// drop the reference to the destination before pooling the writer
+	p.w.Reset(nil)
+	p.pool.Put(p.w)
+	p.w = nil
+	return err
+}
@@ -0,0 +1,397 @@
+package compress_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/compress"
+	"github.com/SirWaithaka/gorequest/corehooks"
+)
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+type post struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+var decodeJSON = gorequest.Hook{Name: "test.Decode", Fn: func(r *gorequest.Request) {
+	defer r.Response.Body.Close()
+	if err := json.NewDecoder(r.Response.Body).Decode(r.Data); err != nil {
+		r.Error = err
+	}
+}}
+
+func encode(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		w, err = zstd.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown encoding %s", encoding)
+	}
+	assert.NoError(t, err)
+
+	_, err = w.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// newServer returns a server responding with body encoded with the given
+// encodings, applied in order
+func newServer(t *testing.T, body []byte, encodings ...string) *httptest.Server {
+	for _, encoding := range encodings {
+		body = encode(t, encoding, body)
+	}
+	header := strings.ReplaceAll(strings.Join(encodings, ", "), "raw-deflate", "deflate")
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+		if header != "" {
+			w.Header().Set("Content-Encoding", header)
+		}
+		_, _ = w.Write(body)
+	}))
+}
+
+func newRequest(cfg gorequest.Config, method string, d *compress.Decompressor, out any) *gorequest.Request {
+	hooks := corehooks.Default()
+	d.Apply(&hooks)
+	if out != nil {
+		hooks.Unmarshal.PushBackHook(decodeJSON)
+	}
+	op := gorequest.Operation{Name: "GetPost", Method: method, Path: "/posts/1"}
+	return gorequest.New(cfg, op, hooks, nil, nil, out)
+}
+
+func TestDecompressor(t *testing.T) {
+	body := []byte(`{"id": 1, "title": "compressed"}`)
+
+	t.Run("test that responses are decoded by content encoding", func(t *testing.T) {
+		tests := map[string][]string{
+			"gzip":            {"gzip"},
+			"deflate":         {"deflate"},
+			"raw deflate":     {"raw-deflate"},
+			"brotli":          {"br"},
+			"zstd":            {"zstd"},
+			"stacked codings": {"deflate", "gzip"},
+			"identity":        nil,
+		}
+
+		for name, encodings := range tests {
+			t.Run(name, func(t *testing.T) {
+				server := newServer(t, body, encodings...)
+				defer server.Close()
+
+				out := &post{}
+				req := newRequest(gorequest.Config{Endpoint: server.URL}, http.MethodGet, compress.NewDecompressor(compress.Config{}), out)
+				assert.NoError(t, req.Send())
+
+				assert.Equal(t, "compressed", out.Title)
+				assert.Equal(t, "gzip, zstd, br, deflate", req.Response.Header.Get("X-Accept-Encoding"))
+				assert.Empty(t, req.Response.Header.Get("Content-Encoding"))
+				if len(encodings) > 0 {
+					assert.Empty(t, req.Response.Header.Get("Content-Length"))
+					assert.Equal(t, int64(-1), req.Response.ContentLength)
+				}
+			})
+		}
+	})
+
+	t.Run("test that responses are decoded when the transport is used directly", func(t *testing.T) {
+		server := newServer(t, body, "gzip")
+		defer server.Close()
+
+		cfg := gorequest.Config{
+			Endpoint:               server.URL,
+			HTTPClient:             &http.Client{Transport: &http.Transport{DisableCompression: true}},
+			DisableFollowRedirects: true,
+		}
+		out := &post{}
+		req := newRequest(cfg, http.MethodGet, compress.NewDecompressor(compress.Config{}), out)
+		assert.NoError(t, req.Send())
+		assert.Equal(t, "compressed", out.Title)
+	})
+
+	t.Run("test that an explicit accept encoding is kept", func(t *testing.T) {
+		server := newServer(t, body, "br")
+		defer server.Close()
+
+		out := &post{}
+		req := newRequest(gorequest.Config{Endpoint: server.URL}, http.MethodGet, compress.NewDecompressor(compress.Config{}), out)
+		req.Request.Header.Set("Accept-Encoding", "br")
+		assert.NoError(t, req.Send())
+
+		assert.Equal(t, "br", req.Response.Header.Get("X-Accept-Encoding"))
+		assert.Equal(t, "compressed", out.Title)
+	})
+
+	t.Run("test that codings without a codec are left untouched", func(t *testing.T) {
+		server := newServer(t, body, "zstd")
+		defer server.Close()
+
+		d := compress.NewDecompressor(compress.Config{Codecs: []compress.Codec{compress.Gzip()}})
+		req := newRequest(gorequest.Config{Endpoint: server.URL}, http.MethodGet, d, nil)
+		assert.NoError(t, req.Send())
+
+		assert.Equal(t, "gzip", req.Response.Header.Get("X-Accept-Encoding"))
+		assert.Equal(t, "zstd", req.Response.Header.Get("Content-Encoding"))
+		raw, err := io.ReadAll(req.Response.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, encode(t, "zstd", body)[:4], raw[:4])
+	})
+
+	t.Run("test that bodies decompressing past the size limit fail", func(t *testing.T) {
+		server := newServer(t, bytes.Repeat([]byte(" "), 1<<20), "gzip")
+		defer server.Close()
+
+		d := compress.NewDecompressor(compress.Config{MaxSize: 1024})
+		req := newRequest(gorequest.Config{Endpoint: server.URL}, http.MethodGet, d, nil)
+		req.Hooks.Unmarshal.PushBack(func(r *gorequest.Request) {
+			_, r.Error = io.ReadAll(r.Response.Body)
+		})
+
+		err := req.Send()
+		assert.ErrorIs(t, err, compress.ErrTooLarge)
+		assert.Equal(t, gorequest.ErrorKindDecode, gorequest.Classify(req))
+	})
+
+	t.Run("test that encoded responses without a body do not fail", func(t *testing.T) {
+		server := newServer(t, body, "gzip")
+		defer server.Close()
+
+		req := newRequest(gorequest.Config{Endpoint: server.URL}, http.MethodHead, compress.NewDecompressor(compress.Config{}), nil)
+		req.Hooks.Unmarshal.PushBack(func(r *gorequest.Request) {
+			_, r.Error = io.ReadAll(r.Response.Body)
+		})
+		assert.NoError(t, req.Send())
+	})
+}
+
+type upload struct {
+	Data string `json:"data"`
+}
+
+// newUploadServer returns a server recording the Content-Encoding and decoded
+// body of every request
+func newUploadServer(t *testing.T, encodings *[]string, bodies *[]string, fail int) *httptest.Server {
+	codecs := map[string]compress.Codec{}
+	for _, codec := range compress.DefaultCodecs() {
+		codecs[codec.Encoding()] = codec
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.Header.Get("Content-Encoding")
+		var body io.Reader = r.Body
+		if encoding != "" {
+			rc, err := codecs[encoding].NewReader(r.Body)
+			assert.NoError(t, err)
+			body = rc
+		}
+		b, err := io.ReadAll(body)
+		assert.NoError(t, err)
+
+		*encodings = append(*encodings, encoding)
+		*bodies = append(*bodies, string(b))
+		if len(*bodies) <= fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+}
+
+func newUpload(cfg gorequest.Config, operation string, c *compress.Compressor, params any) *gorequest.Request {
+	hooks := corehooks.Default()
+	hooks.Build.PushBackHook(corehooks.EncodeRequestBody)
+	c.Apply(&hooks)
+	hooks.Unmarshal.PushBackHook(corehooks.ResponseStatusCode)
+	op := gorequest.Operation{Name: operation, Method: http.MethodPost, Path: "/ingest"}
+	return gorequest.New(cfg, op, hooks, nil, params, nil)
+}
+
+func TestCompressor(t *testing.T) {
+	large := upload{Data: strings.Repeat("event ", 1000)}
+	small := upload{Data: "event"}
+
+	t.Run("test that request bodies are compressed with the encoder", func(t *testing.T) {
+		for _, codec := range compress.DefaultCodecs() {
+			t.Run(codec.Encoding(), func(t *testing.T) {
+				var encodings, bodies []string
+				server := newUploadServer(t, &encodings, &bodies, 0)
+				defer server.Close()
+
+				c := compress.NewCompressor(compress.CompressorConfig{Encoder: codec.(compress.Encoder), MinSize: 1})
+				req := newUpload(gorequest.Config{Endpoint: server.URL}, "Ingest", c, large)
+				assert.NoError(t, req.Send())
+
+				assert.Equal(t, []string{codec.Encoding()}, encodings)
+				assert.JSONEq(t, `{"data": "`+large.Data+`"}`, bodies[0])
+				assert.Less(t, req.Request.ContentLength, int64(len(large.Data)))
+			})
+		}
+	})
+
+	t.Run("test that request bodies are compressed by operation or size", func(t *testing.T) {
+		tests := map[string]struct {
+			operation string
+			params    upload
+			encoding  string
+		}{
+			"large body":            {operation: "Ingest", params: large, encoding: "gzip"},
+			"small body":            {operation: "Ingest", params: small, encoding: ""},
+			"small body, operation": {operation: "BulkIngest", params: small, encoding: "gzip"},
+		}
+
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				var encodings, bodies []string
+				server := newUploadServer(t, &encodings, &bodies, 0)
+				defer server.Close()
+
+				c := compress.NewCompressor(compress.CompressorConfig{Operations: []string{"BulkIngest"}, MinSize: 1024})
+				req := newUpload(gorequest.Config{Endpoint: server.URL}, tc.operation, c, tc.params)
+				assert.NoError(t, req.Send())
+
+				assert.Equal(t, []string{tc.encoding}, encodings)
+				assert.JSONEq(t, `{"data": "`+tc.params.Data+`"}`, bodies[0])
+			})
+		}
+	})
+
+	t.Run("test that compressed bodies are sent again on retries", func(t *testing.T) {
+		var encodings, bodies []string
+		server := newUploadServer(t, &encodings, &bodies, 1)
+		defer server.Close()
+
+		c := compress.NewCompressor(compress.CompressorConfig{MinSize: 1})
+		req := newUpload(gorequest.Config{Endpoint: server.URL}, "Ingest", c, large)
+		retryHook := corehooks.NewRetryer()
+		req.Hooks.Retry.PushBackHook(retryHook.Retry())
+		// retrying a POST is opted into with a policy
+		req.Retryer = gorequest.NewRetryPolicy().RetryOn(gorequest.RetryOnErrorKinds(gorequest.ErrorKindServerError))
+		req.WithRetryConfig(gorequest.RetryConfig{MaxRetries: 1, InitialDelay: time.Millisecond, Multiplier: 1, MaxDelay: time.Millisecond})
+		assert.NoError(t, req.Send())
+
+		assert.Equal(t, []string{"gzip", "gzip"}, encodings)
+		assert.Equal(t, bodies[0], bodies[1])
+	})
+
+	t.Run("test that encoded bodies are not compressed again", func(t *testing.T) {
+		var encodings, bodies []string
+		server := newUploadServer(t, &encodings, &bodies, 0)
+		defer server.Close()
+
+		c := compress.NewCompressor(compress.CompressorConfig{MinSize: 1})
+		req := newUpload(gorequest.Config{Endpoint: server.URL}, "Ingest", c, nil)
+		req.Hooks.Build.PushFront(func(r *gorequest.Request) {
+			r.Request.Body = io.NopCloser(bytes.NewReader(encode(t, "zstd", []byte("raw"))))
+			r.Request.Header.Set("Content-Encoding", "zstd")
+		})
+		assert.NoError(t, req.Send())
+
+		assert.Equal(t, []string{"zstd"}, encodings)
+		assert.Equal(t, []string{"raw"}, bodies)
+	})
+
+	t.Run("test that bodies are only read as far as needed to decide on compression", func(t *testing.T) {
+		data := strings.Repeat("event ", 1000)
+
+		tests := map[string]struct {
+			minSize       int64
+			contentLength int64
+			expected      int
+			encoding      string
+		}{
+			"no min size":               {minSize: 0, contentLength: -1, expected: 0},
+			"known length below size":   {minSize: 10000, contentLength: int64(len(data)), expected: 0},
+			"unknown length below size": {minSize: 10000, contentLength: -1, expected: len(data)},
+			"unknown length above size": {minSize: 1024, contentLength: -1, expected: len(data), encoding: "gzip"},
+		}
+
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				c := compress.NewCompressor(compress.CompressorConfig{MinSize: tc.minSize})
+				body := &countingReader{r: strings.NewReader(data)}
+
+				req := gorequest.New(gorequest.Config{}, gorequest.Operation{Name: "Ingest"}, gorequest.Hooks{}, nil, nil, nil)
+				req.Request.Body = io.NopCloser(body)
+				req.Request.ContentLength = tc.contentLength
+				c.Compress().Fn(req)
+
+				assert.NoError(t, req.Error)
+				assert.Equal(t, tc.expected, body.n)
+				assert.Equal(t, tc.encoding, req.Request.Header.Get("Content-Encoding"))
+				if tc.encoding == "" {
+					sent, err := io.ReadAll(req.Request.Body)
+					assert.NoError(t, err)
+					assert.Equal(t, data, string(sent))
+				}
+			})
+		}
+	})
+
+	t.Run("test that pooled writers produce independent streams", func(t *testing.T) {
+		encoder := compress.Zstd().(compress.Encoder)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				data := []byte(strings.Repeat(strconv.Itoa(i), 100))
+
+				var buf bytes.Buffer
+				w, err := encoder.NewWriter(&buf)
+				assert.NoError(t, err)
+				_, err = w.Write(data)
+				assert.NoError(t, err)
+				assert.NoError(t, w.Close())
+
+				r, err := compress.Zstd().NewReader(&buf)
+				assert.NoError(t, err)
+				decoded, err := io.ReadAll(r)
+				assert.NoError(t, err)
+				assert.Equal(t, data, decoded)
+			}(i)
+		}
+		wg.Wait()
+	})
+}
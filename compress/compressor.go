@@ -0,0 +1,126 @@
+package compress
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/SirWaithaka/gorequest"
+)
+
+var errClosed = errors.New("compress: write to closed writer")
+
+// CompressorConfig configures a Compressor.
+type CompressorConfig struct {
+	// Encoder compresses the request bodies. Defaults to Gzip.
+	Encoder Encoder
+	// Operations are the names of operations whose request bodies are
+	// always compressed.
+	Operations []string
+	// MinSize is the size from which the request bodies of other operations
+	// are compressed. Zero means only the bodies of Operations are
+	// compressed.
+	MinSize int64
+}
+
+// NewCompressor returns a Compressor.
+func NewCompressor(cfg CompressorConfig) *Compressor {
+	if cfg.Encoder == nil {
+		cfg.Encoder = gzipCodec{}
+	}
+	return &Compressor{cfg: cfg}
+}
+
+// Compressor provides the build hook that compresses request bodies for
+// services accepting encoded uploads. Compressed bodies stay replayable, so
+// retries send the same compressed body again.
+type Compressor struct {
+	cfg CompressorConfig
+}
+
+// Apply registers the compression hook as the last build hook.
+func (c *Compressor) Apply(hooks *gorequest.Hooks) {
+	hooks.Build.PushBackHook(c.Compress())
+}
+
+// Compress returns a build hook that compresses the request body and sets the
+// Content-Encoding header. Register it after the hook encoding the body, such
+// as corehooks.EncodeRequestBody. Bodies that already have a Content-Encoding
+// are left alone.
+func (c *Compressor) Compress() gorequest.Hook {
+	return gorequest.Hook{Name: "compress.Compress", Fn: func(r *gorequest.Request) {
+		body := r.Request.Body
+		if body == nil || body == http.NoBody || r.Request.Header.Get("Content-Encoding") != "" {
+			return
+		}
+
+		data, ok, err := c.read(r.Request, r.Operation.Name)
+		if err != nil {
+			r.Error = err
+			return
+		}
+		if !ok {
+			return
+		}
+
+		var buf bytes.Buffer
+		w, err := c.cfg.Encoder.NewWriter(&buf)
+		if err != nil {
+			r.Error = err
+			return
+		}
+		_, err = w.Write(data)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			r.Error = err
+			return
+		}
+
+		setBody(r.Request, buf.Bytes())
+		r.Request.Header.Set("Content-Encoding", c.cfg.Encoder.Encoding())
+	}}
+}
+
+// read returns the body of req when it is to be compressed. The body is only
+// read when the operation or the content length do not decide it, and then
+// only up to MinSize. A body that is read and not compressed is put back.
+func (c *Compressor) read(req *http.Request, operation string) ([]byte, bool, error) {
+	body := req.Body
+	if !slices.Contains(c.cfg.Operations, operation) {
+		if c.cfg.MinSize <= 0 || (req.ContentLength > 0 && req.ContentLength < c.cfg.MinSize) {
+			return nil, false, nil
+		}
+		if req.ContentLength <= 0 {
+			head, err := io.ReadAll(io.LimitReader(body, c.cfg.MinSize))
+			if err != nil || int64(len(head)) < c.cfg.MinSize {
+				_ = body.Close()
+				setBody(req, head)
+				return nil, false, err
+			}
+			body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(head), body), body}
+		}
+	}
+
+	data, err := io.ReadAll(body)
+	_ = body.Close()
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// setBody sets a replayable body on the request
+func setBody(req *http.Request, data []byte) {
+	req.ContentLength = int64(len(data))
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+}
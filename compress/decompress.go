@@ -0,0 +1,208 @@
+package compress
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/SirWaithaka/gorequest"
+)
+
+// DefaultMaxSize is the default limit on the decompressed size of a response
+// body.
+const DefaultMaxSize = 64 << 20
+
+// ErrTooLarge is returned when reading a response body that decompresses to
+// more than the size limit.
+var ErrTooLarge error = decodeError("compress: decompressed response body exceeds the size limit")
+
+type decodeError string
+
+func (e decodeError) Error() string { return string(e) }
+
+func (e decodeError) ErrorKind() gorequest.ErrorKind { return gorequest.ErrorKindDecode }
+
+// Config configures a Decompressor.
+type Config struct {
+	// Codecs are the content codings accepted and decoded, in order of
+	// preference. Defaults to DefaultCodecs.
+	Codecs []Codec
+	// MaxSize limits the decompressed size of a response body, so that a
+	// small compressed body can not exhaust memory. Defaults to
+	// DefaultMaxSize. A negative value disables the limit.
+	MaxSize int64
+}
+
+// NewDecompressor returns a Decompressor.
+func NewDecompressor(cfg Config) *Decompressor {
+	if cfg.Codecs == nil {
+		cfg.Codecs = DefaultCodecs()
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = DefaultMaxSize
+	}
+
+	d := &Decompressor{cfg: cfg, codecs: make(map[string]Codec, len(cfg.Codecs))}
+	encodings := make([]string, 0, len(cfg.Codecs))
+	for _, codec := range cfg.Codecs {
+		d.codecs[codec.Encoding()] = codec
+		encodings = append(encodings, codec.Encoding())
+	}
+	d.acceptEncoding = strings.Join(encodings, ", ")
+	return d
+}
+
+// Decompressor provides the hooks that negotiate the content coding of
+// responses and decode their bodies. Setting Accept-Encoding explicitly turns
+// off the transparent gzip handling of http.Transport, so responses are
+// decoded the same way whether or not a custom transport is used.
+type Decompressor struct {
+	cfg            Config
+	codecs         map[string]Codec
+	acceptEncoding string
+}
+
+// Apply registers the decompression hooks on hooks.
+func (d *Decompressor) Apply(hooks *gorequest.Hooks) {
+	hooks.Build.PushBackHook(d.AcceptEncoding())
+	hooks.Unmarshal.PushFrontHook(d.Decode())
+}
+
+// AcceptEncoding returns a build hook that sets the Accept-Encoding header to
+// the codecs of the Decompressor, unless the request already has one.
+func (d *Decompressor) AcceptEncoding() gorequest.Hook {
+	return gorequest.Hook{Name: "compress.AcceptEncoding", Fn: func(r *gorequest.Request) {
+		if r.Request.Header.Get("Accept-Encoding") == "" {
+			r.Request.Header.Set("Accept-Encoding", d.acceptEncoding)
+		}
+	}}
+}
+
+// Decode returns an unmarshal hook that replaces the response body with a
+// decoding reader for its Content-Encoding. The Content-Encoding and
+// Content-Length headers are removed since they describe the encoded body.
+// Register it as the first unmarshal hook. Responses with a coding without a
+// codec are left untouched.
+func (d *Decompressor) Decode() gorequest.Hook {
+	return gorequest.Hook{Name: "compress.Decode", Fn: func(r *gorequest.Request) {
+		resp := r.Response
+		if resp == nil || resp.Uncompressed || resp.Body == nil || resp.Body == http.NoBody {
+			return
+		}
+
+		codecs, ok := d.codecsFor(resp.Header)
+		if !ok || len(codecs) == 0 {
+			return
+		}
+
+		var body io.Reader = resp.Body
+		for _, codec := range codecs {
+			body = &lazyReader{codec: codec, src: body}
+		}
+		if d.cfg.MaxSize > 0 {
+			body = &limitedReader{r: body, n: d.cfg.MaxSize}
+		}
+		resp.Body = &decodedBody{Reader: body, src: resp.Body}
+
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}}
+}
+
+// codecsFor returns the codecs decoding the Content-Encoding of a response, in
+// the order they must be applied. ok is false if a coding has no codec.
+func (d *Decompressor) codecsFor(header http.Header) (codecs []Codec, ok bool) {
+	var encodings []string
+	for _, value := range header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+
+	// codings are listed in the order they were applied
+	for i := len(encodings) - 1; i >= 0; i-- {
+		codec, found := d.codecs[encodings[i]]
+		if !found {
+			return nil, false
+		}
+		codecs = append(codecs, codec)
+	}
+	return codecs, true
+}
+
+// lazyReader creates its decoder on the first read, so that bodies that are
+// never read, such as those of HEAD requests, do not fail.
+type lazyReader struct {
+	codec Codec
+	src   io.Reader
+	rc    io.ReadCloser
+	err   error
+}
+
+func (l *lazyReader) Read(p []byte) (int, error) {
+	if l.rc == nil && l.err == nil {
+		l.rc, l.err = l.codec.NewReader(l.src)
+	}
+	if l.err != nil {
+		return 0, l.err
+	}
+	return l.rc.Read(p)
+}
+
+func (l *lazyReader) Close() error {
+	if l.rc == nil {
+		return nil
+	}
+	return l.rc.Close()
+}
+
+// decodedBody closes the decoders and the original body
+type decodedBody struct {
+	io.Reader
+	src io.ReadCloser
+}
+
+func (b *decodedBody) Close() error {
+	r := b.Reader
+	if l, ok := r.(*limitedReader); ok {
+		r = l.r
+	}
+	for {
+		l, ok := r.(*lazyReader)
+		if !ok {
+			break
+		}
+		_ = l.Close()
+		r = l.src
+	}
+	return b.src.Close()
+}
+
+// limitedReader fails with ErrTooLarge once more than n bytes are read
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// the limit is reached, the body is too large if there is more
+		var b [1]byte
+		n, err := l.r.Read(b[:])
+		if n > 0 {
+			return 0, ErrTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
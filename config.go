@@ -11,6 +11,18 @@ type Config struct {
 	// ServiceName of the external service being called
 	ServiceName string
 
+	// EndpointResolver resolves the endpoint of the requests from the
+	// service name, region and operation, replacing Endpoint.
+	EndpointResolver EndpointResolver
+	// Region of the service, used to resolve the endpoint
+	Region string
+	// EndpointVariant selects an alternative endpoint of the service, such
+	// as a FIPS or dual-stack endpoint
+	EndpointVariant EndpointVariant
+	// EndpointVariables are additional variables of endpoint templates, such
+	// as an environment or api version
+	EndpointVariables map[string]string
+
 	// Set this to `true` to disable SSL when sending requests. Defaults
 	// to `false`
 	DisableSSL bool
@@ -18,6 +30,11 @@ type Config struct {
 	// The HTTP client to use when sending requests
 	HTTPClient *http.Client
 
+	// TLS configures the TLS connections of the HTTP client. Its transport
+	// is replaced by a clone configured by TLS, built once and shared by the
+	// requests with the same configuration.
+	TLS *TLSConfig
+
 	DisableFollowRedirects bool
 
 	LogLevel LogLevel
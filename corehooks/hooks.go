@@ -3,9 +3,11 @@ package corehooks
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/http/httputil"
 	"net/url"
 	"regexp"
@@ -16,6 +18,7 @@ import (
 	"github.com/rs/xid"
 
 	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/credentials"
 )
 
 func Default() gorequest.Hooks {
@@ -34,11 +37,99 @@ func defaultHooks() gorequest.Hooks {
 
 // SetBasicAuth modifies the http.Request headers and adds basic auth credentials
 func SetBasicAuth(username, password string) gorequest.Hook {
+	return BasicAuth(credentials.Static(credentials.Credentials{ID: username, Secret: password}))
+}
+
+// BasicAuth sets the basic auth credentials of the http.Request to the ID and
+// Secret of the credentials retrieved from provider. Push it to the send hooks
+// so that the credentials are retrieved for every attempt, and rotated
+// credentials are used by retries.
+func BasicAuth(provider credentials.Provider) gorequest.Hook {
 	return gorequest.Hook{Name: "core.BasicAuth", Fn: func(r *gorequest.Request) {
-		r.Request.SetBasicAuth(username, password)
+		if r.Error != nil {
+			return
+		}
+
+		creds, err := provider.Retrieve(r.Context())
+		if err != nil {
+			r.Error = fmt.Errorf("basic auth: %w", err)
+			return
+		}
+		r.Request.SetBasicAuth(creds.ID, creds.Secret)
 	}}
 }
 
+// APIKeyLocation is the part of the http.Request an API key is placed in.
+type APIKeyLocation int
+
+const (
+	// APIKeyInHeader places the key in a header.
+	APIKeyInHeader APIKeyLocation = iota
+	// APIKeyInQuery places the key in a query parameter of the url.
+	APIKeyInQuery
+	// APIKeyInCookie places the key in a cookie.
+	APIKeyInCookie
+)
+
+// APIKeyConfig configures the APIKey hook.
+type APIKeyConfig struct {
+	// Provider provides the key as the Secret of its credentials.
+	Provider credentials.Provider
+	// In is where the key is placed. Defaults to APIKeyInHeader.
+	In APIKeyLocation
+	// Name is the name of the header, query parameter or cookie.
+	Name string
+	// Prefix is prepended to the key, for example "ApiKey ".
+	Prefix string
+}
+
+// APIKey places the key retrieved from the provider in a header, query
+// parameter or cookie of the http.Request. The hook is named "core.APIKey",
+// so the key of a single request can be replaced with HookList.Swap. The
+// placement is recorded on the request so that LogHTTPRequest masks the key.
+//
+// Push it to the build hooks to retrieve the key once per request, or to the
+// send hooks for a rotated key to be used by retries.
+func APIKey(cfg APIKeyConfig) gorequest.Hook {
+	return gorequest.Hook{Name: "core.APIKey", Fn: func(r *gorequest.Request) {
+		if r.Error != nil {
+			return
+		}
+
+		creds, err := cfg.Provider.Retrieve(r.Context())
+		if err != nil {
+			r.Error = fmt.Errorf("api key: %w", err)
+			return
+		}
+		key := cfg.Prefix + creds.Secret
+
+		switch cfg.In {
+		case APIKeyInQuery:
+			query := r.Request.URL.Query()
+			query.Set(cfg.Name, key)
+			r.Request.URL.RawQuery = query.Encode()
+		case APIKeyInCookie:
+			setCookie(r.Request, &http.Cookie{Name: cfg.Name, Value: key})
+		default:
+			r.Request.Header.Set(cfg.Name, key)
+		}
+		redact(r, cfg.In, cfg.Name)
+	}}
+}
+
+// setCookie adds cookie to the request, replacing a cookie with the same name
+// so that the key is not duplicated when the hook runs on every attempt
+func setCookie(req *http.Request, cookie *http.Cookie) {
+	cookies := req.Cookies()
+	req.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name != cookie.Name {
+			req.AddCookie(c)
+		}
+	}
+	req.AddCookie(cookie)
+}
+
 // SetHTTPClient sets the http.Client to be used for requests.
 // Sets a default http.Client if none is provided with a timeout of 30 seconds.
 func SetHTTPClient(client *http.Client) gorequest.Hook {
@@ -70,12 +161,38 @@ func AddScheme(endpoint string, disableSSL bool) string {
 	return endpoint
 }
 
+// ResolveEndpoint adds a scheme to the endpoint of the request. When the config
+// has an EndpointResolver, the endpoint is first resolved from the service
+// name, region, variant and operation, and the url of the http request is
+// moved to the resolved endpoint with gorequest.Request.SetEndpoint. The
+// resolution is logged at LogDebug.
 var ResolveEndpoint = gorequest.Hook{Name: "core.ResolveEndpoint", Fn: func(r *gorequest.Request) {
-	r.Config.Endpoint = AddScheme(r.Config.Endpoint, r.Config.DisableSSL)
+	resolver := r.Config.EndpointResolver
+	if resolver == nil {
+		r.Config.Endpoint = AddScheme(r.Config.Endpoint, r.Config.DisableSSL)
+		return
+	}
+
+	params := gorequest.EndpointParamsFromRequest(r)
+	endpoint, err := resolver.ResolveEndpoint(r.Context(), params)
+	if err != nil {
+		r.Error = fmt.Errorf("resolving endpoint: %w", err)
+		return
+	}
+	if err = r.SetEndpoint(AddScheme(endpoint, r.Config.DisableSSL)); err != nil {
+		r.Error = err
+		return
+	}
+
+	if r.Config.LogLevel.AtLeast(gorequest.LogDebug) && r.Config.Logger != nil {
+		r.Config.Logger.Log(fmt.Sprintf("DEBUG: %s, resolved endpoint %s for service %q, region %q, variant %s",
+			r.Operation.Name, r.Config.Endpoint, params.ServiceName, params.Region, params.Variant))
+	}
 }}
 
 // EncodeRequestBody converts the value in r.Params into an io reader and adds it
-// to the http.Request instance
+// to the http.Request instance. The body is replayable, so it is sent again
+// when the request is retried.
 var EncodeRequestBody = gorequest.Hook{Name: "core.EncodeRequestBody", Fn: func(r *gorequest.Request) {
 	if r.Params == nil {
 		return
@@ -88,13 +205,25 @@ var EncodeRequestBody = gorequest.Hook{Name: "core.EncodeRequestBody", Fn: func(
 	}
 
 	// add as body to request
-	r.Request.Body = io.NopCloser(buf)
+	data := buf.Bytes()
+	r.Request.ContentLength = int64(len(data))
+	r.Request.Body = io.NopCloser(bytes.NewReader(data))
+	r.Request.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
 
 }}
 
 var reStatusCode = regexp.MustCompile(`^(\d{3})`)
 
+// SendHook sends the http request. The request is not sent if a send hook
+// registered before it failed, so gates such as rate and concurrency limiters
+// can stop an attempt by setting Request.Error.
 var SendHook = gorequest.Hook{Name: "core.Send", Fn: func(r *gorequest.Request) {
+	if r.Error != nil {
+		return
+	}
+
 	sender := sendFollowRedirects
 	if r.Config.DisableFollowRedirects {
 		sender = sendWithoutFollowRedirects
@@ -241,7 +370,7 @@ func (r *RetryHook) Retry() gorequest.Hook {
 		ctx := req.Context()
 		// Stop retrying if context is canceled
 		if err := context.Cause(ctx); err != nil {
-			req.Error = err
+			req.StopRetry(err)
 			return
 		}
 
@@ -251,7 +380,7 @@ func (r *RetryHook) Retry() gorequest.Hook {
 		select {
 		case <-r.timer.C():
 		case <-ctx.Done():
-			req.Error = context.Cause(ctx)
+			req.StopRetry(context.Cause(ctx))
 			return
 		}
 
@@ -280,7 +409,10 @@ func logRequest(r *gorequest.Request) {
 	}
 
 	logBody := r.Config.LogLevel.Equals(gorequest.LogDebugWithHTTPBody)
-	b, err := httputil.DumpRequest(r.Request, logBody)
+	req := redactedRequest(r)
+	b, err := httputil.DumpRequest(req, logBody)
+	// dumping the body replaces it with a copy of what was read
+	r.Request.Body = req.Body
 	if err != nil {
 		r.Config.Logger.Log(fmt.Sprintf("DEBUG: %s failed, error %v",
 			r.Operation.Name, err))
@@ -292,6 +424,97 @@ func logRequest(r *gorequest.Request) {
 
 }
 
+// Redacted replaces secrets in the requests logged by LogHTTPRequest.
+const Redacted = "REDACTED"
+
+type redactionsKey struct{}
+
+type redaction struct {
+	in   APIKeyLocation
+	name string
+}
+
+// redact records that the header, query parameter or cookie name of the
+// request holds a secret
+func redact(r *gorequest.Request, in APIKeyLocation, name string) {
+	redactions, _ := r.Context().Value(redactionsKey{}).(*[]redaction)
+	if redactions == nil {
+		redactions = &[]redaction{}
+		r.WithContext(context.WithValue(r.Context(), redactionsKey{}, redactions))
+	}
+	for _, rd := range *redactions {
+		if rd.in == in && rd.name == name {
+			return
+		}
+	}
+	*redactions = append(*redactions, redaction{in: in, name: name})
+}
+
+// redactedRequest returns a shallow copy of the http.Request with the recorded
+// secrets replaced by Redacted. The copy shares the body of the request.
+func redactedRequest(r *gorequest.Request) *http.Request {
+	req := *r.Request
+	redactions, _ := r.Context().Value(redactionsKey{}).(*[]redaction)
+	if redactions == nil {
+		return &req
+	}
+
+	req.Header = r.Request.Header.Clone()
+	u := *r.Request.URL
+	req.URL = &u
+	for _, rd := range *redactions {
+		switch rd.in {
+		case APIKeyInQuery:
+			query := req.URL.Query()
+			if query.Has(rd.name) {
+				query.Set(rd.name, Redacted)
+				req.URL.RawQuery = query.Encode()
+			}
+		case APIKeyInCookie:
+			if _, err := req.Cookie(rd.name); err == nil {
+				setCookie(&req, &http.Cookie{Name: rd.name, Value: Redacted})
+			}
+		default:
+			if req.Header.Get(rd.name) != "" {
+				req.Header.Set(rd.name, Redacted)
+			}
+		}
+	}
+	return &req
+}
+
+// TraceConnection is a build hook that attaches a httptrace.ClientTrace to the
+// http request context. The trace records the DNS, connect, TLS and time to first
+// byte timings of each attempt into the request's TimingRecorder, read with
+// Request.Timing.
+var TraceConnection = gorequest.Hook{Name: "core.TraceConnection", Fn: func(r *gorequest.Request) {
+	timing := r.TimingRecorder()
+	trace := &httptrace.ClientTrace{
+		GetConn:  func(string) { timing.GetConn(time.Now()) },
+		DNSStart: func(httptrace.DNSStartInfo) { timing.DNSStart(time.Now()) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { timing.DNSDone(time.Now()) },
+		ConnectStart: func(string, string) {
+			timing.ConnectStart(time.Now())
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				timing.ConnectDone(time.Now())
+			}
+		},
+		TLSHandshakeStart: func() { timing.TLSHandshakeStart(time.Now()) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			timing.TLSHandshakeDone(time.Now(), err)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			timing.GotConnection(time.Now(), info.Reused, info.WasIdle, info.IdleTime)
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { timing.WroteRequest(time.Now()) },
+		GotFirstResponseByte: func() { timing.GotFirstResponseByte(time.Now()) },
+	}
+
+	r.WithContext(httptrace.WithClientTrace(r.Context(), trace))
+}}
+
 // SetRequestID will set a default request id to the request if no id generator
 // function is given
 func SetRequestID(fn ...func() string) gorequest.Hook {
@@ -1,9 +1,13 @@
 package corehooks_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,6 +16,8 @@ import (
 
 	"github.com/SirWaithaka/gorequest"
 	"github.com/SirWaithaka/gorequest/corehooks"
+	"github.com/SirWaithaka/gorequest/credentials"
+	"github.com/SirWaithaka/gorequest/endpoints"
 )
 
 func TestAddScheme(t *testing.T) {
@@ -59,6 +65,52 @@ func (t *testSendHandlerTransport) RoundTrip(r *http.Request) (*http.Response, e
 	return nil, errors.New("mock error")
 }
 
+func TestResolveEndpoint(t *testing.T) {
+	paths := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	op := gorequest.Operation{Name: "GetPost", Method: http.MethodGet, Path: "/posts/1"}
+
+	t.Run("test that the endpoint is resolved and logged", func(t *testing.T) {
+		var logs []string
+		cfg := gorequest.Config{
+			ServiceName:       "posts",
+			Region:            "eu-west-1",
+			DisableSSL:        true,
+			EndpointVariables: map[string]string{"version": "v2"},
+			EndpointResolver: endpoints.New(endpoints.Config{
+				// the scheme is added to templates without one
+				Template: host + "/{region}/{version}",
+			}),
+			LogLevel: gorequest.LogDebug,
+			Logger:   gorequest.LoggerFunc(func(args ...any) { logs = append(logs, fmt.Sprint(args...)) }),
+		}
+
+		req := gorequest.New(cfg, op, corehooks.Default(), nil, nil, nil)
+		assert.NoError(t, req.Send())
+		assert.Equal(t, "/eu-west-1/v2/posts/1", <-paths)
+		assert.Equal(t, server.URL+"/eu-west-1/v2", req.Config.Endpoint)
+		assert.Equal(t, fmt.Sprintf(`DEBUG: GetPost, resolved endpoint %s/eu-west-1/v2 for service "posts", region "eu-west-1", variant default`, server.URL), logs[0])
+	})
+
+	t.Run("test that resolver errors stop the request", func(t *testing.T) {
+		cfg := gorequest.Config{
+			ServiceName:      "posts",
+			EndpointVariant:  gorequest.EndpointFIPS,
+			EndpointResolver: endpoints.New(endpoints.Config{Template: server.URL}),
+		}
+
+		req := gorequest.New(cfg, op, corehooks.Default(), nil, nil, nil)
+		err := req.Send()
+		assert.ErrorIs(t, err, endpoints.ErrNoEndpoint)
+		assert.ErrorContains(t, err, "resolving endpoint: endpoints: no endpoint for variant fips")
+	})
+}
+
 func TestSendHook(t *testing.T) {
 
 	t.Run("test redirect", func(t *testing.T) {
@@ -112,6 +164,26 @@ func TestSendHook(t *testing.T) {
 		}
 	})
 
+	t.Run("test that the request is not sent if a previous send hook failed", func(t *testing.T) {
+		sent := false
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sent = true
+		}))
+		defer server.Close()
+
+		gateErr := errors.New("gate closed")
+		hooks := gorequest.Hooks{}
+		hooks.Send.PushBack(func(r *gorequest.Request) {
+			r.Error = gateErr
+		})
+		hooks.Send.PushBackHook(corehooks.SendHook)
+
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, gorequest.Operation{}, hooks, nil, nil, nil)
+		err := req.Send()
+		assert.ErrorIs(t, err, gateErr)
+		assert.False(t, sent)
+	})
+
 	t.Run("test handle send error", func(t *testing.T) {
 
 		t.Run("transport error", func(t *testing.T) {
@@ -146,6 +218,146 @@ func TestSendHook(t *testing.T) {
 	})
 }
 
+func TestBasicAuth(t *testing.T) {
+	var passwords []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, password, _ := r.BasicAuth()
+		passwords = append(passwords, password)
+		if len(passwords) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	// the provider rotates the password on every call
+	var calls int
+	provider := credentials.ProviderFunc(func(ctx context.Context) (credentials.Credentials, error) {
+		calls++
+		return credentials.Credentials{ID: "user", Secret: fmt.Sprintf("password-%d", calls)}, nil
+	})
+
+	hooks := corehooks.Default()
+	hooks.Send.PushFrontHook(corehooks.BasicAuth(provider))
+	hooks.Unmarshal.PushBackHook(corehooks.ResponseStatusCode)
+	retryHook := corehooks.NewRetryer()
+	hooks.Retry.PushBackHook(retryHook.Retry())
+
+	op := gorequest.Operation{Name: "GetPost", Method: http.MethodGet, Path: "/posts/1"}
+	req := gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, gorequest.DefaultRetryer, nil, nil)
+	req.WithRetryConfig(gorequest.RetryConfig{MaxRetries: 1, InitialDelay: time.Millisecond, Multiplier: 1, MaxDelay: time.Millisecond})
+
+	assert.NoError(t, req.Send())
+	assert.Equal(t, []string{"password-1", "password-2"}, passwords)
+
+	t.Run("test that provider errors stop the request", func(t *testing.T) {
+		hooks := corehooks.Default()
+		hooks.Send.PushFrontHook(corehooks.BasicAuth(credentials.Chain()))
+
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, nil, nil, nil)
+		err := req.Send()
+		assert.ErrorIs(t, err, credentials.ErrNotFound)
+		assert.Len(t, passwords, 2)
+	})
+}
+
+func TestAPIKey(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+	}))
+	defer server.Close()
+
+	key := credentials.Static(credentials.Credentials{Secret: "k3y"})
+	op := gorequest.Operation{Name: "GetPost", Method: http.MethodGet, Path: "/posts/1"}
+
+	send := func(hook gorequest.Hook, logger gorequest.Logger) (*http.Request, error) {
+		hooks := corehooks.Default()
+		hooks.Build.PushBack(func(r *gorequest.Request) {
+			r.Request.URL.RawQuery = "page=2"
+			r.Request.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+		})
+		hooks.Build.PushBackHook(hook)
+		// log the request after the key was placed
+		hooks.Build.PushBackHook(corehooks.LogHTTPRequest)
+
+		cfg := gorequest.Config{Endpoint: server.URL, LogLevel: gorequest.LogDebug, Logger: logger}
+		req := gorequest.New(cfg, op, hooks, nil, nil, nil)
+		if err := req.Send(); err != nil {
+			return nil, err
+		}
+		return <-requests, nil
+	}
+
+	tcs := map[string]struct {
+		config corehooks.APIKeyConfig
+		check  func(t *testing.T, r *http.Request)
+		logged string
+	}{
+		"header with prefix": {
+			config: corehooks.APIKeyConfig{Provider: key, Name: "Authorization", Prefix: "ApiKey "},
+			check: func(t *testing.T, r *http.Request) {
+				assert.Equal(t, "ApiKey k3y", r.Header.Get("Authorization"))
+			},
+			logged: "Authorization: REDACTED",
+		},
+		"query parameter": {
+			config: corehooks.APIKeyConfig{Provider: key, In: corehooks.APIKeyInQuery, Name: "api_key"},
+			check: func(t *testing.T, r *http.Request) {
+				assert.Equal(t, "k3y", r.URL.Query().Get("api_key"))
+				assert.Equal(t, "2", r.URL.Query().Get("page"))
+			},
+			logged: "/posts/1?api_key=REDACTED&page=2",
+		},
+		"cookie": {
+			config: corehooks.APIKeyConfig{Provider: key, In: corehooks.APIKeyInCookie, Name: "key"},
+			check: func(t *testing.T, r *http.Request) {
+				cookie, err := r.Cookie("key")
+				assert.NoError(t, err)
+				assert.Equal(t, "k3y", cookie.Value)
+				cookie, err = r.Cookie("session")
+				assert.NoError(t, err)
+				assert.Equal(t, "abc", cookie.Value)
+			},
+			logged: "Cookie: session=abc; key=REDACTED",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run("test that the key is placed in the "+name, func(t *testing.T) {
+			var logs []string
+			logger := gorequest.LoggerFunc(func(args ...any) { logs = append(logs, fmt.Sprint(args...)) })
+
+			r, err := send(corehooks.APIKey(tc.config), logger)
+			assert.NoError(t, err)
+			tc.check(t, r)
+
+			// the first log is the request before the key was placed
+			assert.Len(t, logs, 2)
+			assert.Contains(t, logs[1], tc.logged)
+			assert.NotContains(t, logs[1], "k3y")
+		})
+	}
+
+	t.Run("test that the key of a request can be swapped", func(t *testing.T) {
+		hooks := corehooks.Default()
+		hooks.Build.PushBackHook(corehooks.APIKey(corehooks.APIKeyConfig{Provider: key, Name: "X-API-Key"}))
+
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, nil, nil, nil)
+		other := credentials.Static(credentials.Credentials{Secret: "partner"})
+		req.Hooks.Build.Swap("core.APIKey", corehooks.APIKey(corehooks.APIKeyConfig{Provider: other, Name: "X-API-Key"}))
+
+		assert.NoError(t, req.Send())
+		assert.Equal(t, "partner", (<-requests).Header.Get("X-API-Key"))
+	})
+
+	t.Run("test that provider errors stop the request", func(t *testing.T) {
+		hook := corehooks.APIKey(corehooks.APIKeyConfig{Provider: credentials.Chain(), Name: "X-API-Key"})
+		_, err := send(hook, gorequest.LoggerFunc(func(...any) {}))
+		assert.ErrorIs(t, err, credentials.ErrNotFound)
+		assert.True(t, strings.HasPrefix(err.Error(), "api key: "))
+	})
+}
+
 func TestSetRequestID(t *testing.T) {
 	rid := xid.New().String()
 
@@ -164,3 +376,95 @@ func TestSetRequestID(t *testing.T) {
 	err := req.Send()
 	assert.Nil(t, err)
 }
+
+func TestTraceConnection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	defer client.CloseIdleConnections()
+
+	hooks := corehooks.Default()
+	hooks.Build.PushBackHook(corehooks.TraceConnection)
+	// read the response body so the connection is returned to the pool
+	hooks.Unmarshal.PushBack(func(r *gorequest.Request) {
+		_, _ = io.Copy(io.Discard, r.Response.Body)
+		_ = r.Response.Body.Close()
+	})
+
+	cfg := gorequest.Config{Endpoint: server.URL, HTTPClient: client}
+	op := gorequest.Operation{Name: "Timed", Method: http.MethodGet}
+
+	// first request dials a new connection
+	req := gorequest.New(cfg, op, hooks, nil, nil, nil)
+	assert.NoError(t, req.Send())
+
+	timing := req.Timing()
+	assert.False(t, timing.IsZero())
+	assert.False(t, timing.ConnReused)
+	assert.Greater(t, timing.Connect, time.Duration(0))
+	assert.GreaterOrEqual(t, timing.ServerProcessing, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, timing.TimeToFirstByte, timing.ServerProcessing)
+
+	// second request reuses the idle connection
+	req = gorequest.New(cfg, op, hooks, nil, nil, nil)
+	assert.NoError(t, req.Send())
+
+	timing = req.Timing()
+	assert.True(t, timing.ConnReused)
+	assert.Equal(t, time.Duration(0), timing.Connect)
+	assert.GreaterOrEqual(t, timing.ServerProcessing, 10*time.Millisecond)
+}
+
+func TestTraceConnection_Attempts(t *testing.T) {
+
+	t.Run("test that an attempt failing before connecting records no timing", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		hooks := corehooks.Default()
+		hooks.Build.PushBackHook(corehooks.TraceConnection)
+		hooks.Send.PushFront(func(r *gorequest.Request) {
+			if len(r.Attempts) == 1 {
+				r.Error = errors.New("offline")
+			}
+		})
+		hooks.Unmarshal.PushBackHook(corehooks.ResponseStatusCode)
+		retryHook := corehooks.NewRetryer()
+		hooks.Retry.PushBackHook(retryHook.Retry())
+
+		op := gorequest.Operation{Name: "Timed", Method: http.MethodGet}
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, op, hooks, gorequest.DefaultRetryer, nil, nil)
+		req.WithRetryConfig(gorequest.RetryConfig{MaxRetries: 1, InitialDelay: time.Millisecond, Multiplier: 1, MaxDelay: time.Millisecond})
+		assert.Error(t, req.Send())
+
+		if assert.Len(t, req.Attempts, 2) {
+			assert.False(t, req.Attempts[0].Timing.IsZero())
+			assert.True(t, req.Attempts[1].Timing.IsZero())
+		}
+	})
+
+	t.Run("test that a failed tls handshake is recorded", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		// the client does not trust the certificate of the server
+		client := &http.Client{Transport: &http.Transport{}}
+		hooks := corehooks.Default()
+		hooks.Build.PushBackHook(corehooks.TraceConnection)
+
+		op := gorequest.Operation{Name: "Timed", Method: http.MethodGet}
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL, HTTPClient: client}, op, hooks, nil, nil, nil)
+		assert.Error(t, req.Send())
+
+		timing := req.Timing()
+		assert.Error(t, timing.TLSHandshakeErr)
+		assert.Greater(t, timing.TLSHandshake, time.Duration(0))
+		assert.Contains(t, timing.String(), "tls_error=")
+	})
+}
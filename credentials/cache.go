@@ -0,0 +1,54 @@
+package credentials
+
+import (
+	"context"
+	"time"
+
+	"github.com/SirWaithaka/gorequest/internal/refresh"
+)
+
+// CacheConfig configures a Cache.
+type CacheConfig struct {
+	// TTL is how long credentials without an expiry are reused. Zero reuses
+	// them until the cache is invalidated.
+	TTL time.Duration
+	// EarlyExpiry renews credentials this long before they expire, so that
+	// requests do not race their expiry.
+	EarlyExpiry time.Duration
+}
+
+// NewCache returns a Cache of the credentials of provider.
+func NewCache(provider Provider, cfg CacheConfig) *Cache {
+	return &Cache{cache: refresh.New(refresh.Config[Credentials]{
+		Fetch: provider.Retrieve,
+		Valid: func(creds Credentials) bool { return !creds.Expired() },
+		Fresh: func(creds Credentials, retrieved time.Time) bool {
+			switch {
+			case !creds.Expires.IsZero():
+				return !creds.expiresWithin(cfg.EarlyExpiry)
+			case cfg.TTL > 0:
+				return time.Since(retrieved) < cfg.TTL
+			default:
+				return true
+			}
+		},
+	})}
+}
+
+// Cache is a Provider that reuses the credentials of another provider until
+// they expire. Concurrent calls needing new credentials share a single call
+// to the provider. If renewing fails while the cached credentials have not
+// expired, the cached credentials are returned.
+type Cache struct {
+	cache *refresh.Cache[Credentials]
+}
+
+func (c *Cache) Retrieve(ctx context.Context) (Credentials, error) {
+	return c.cache.Get(ctx)
+}
+
+// Invalidate drops the cached credentials, for example after they were
+// rejected, so that the next call retrieves them again.
+func (c *Cache) Invalidate() {
+	c.cache.Invalidate(func(Credentials) bool { return true })
+}
@@ -0,0 +1,102 @@
+// Package credentials provides the secrets used to authenticate requests,
+// retrieved from providers so that they can rotate while the client runs.
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrNotFound is the error of a provider that has no credentials.
+var ErrNotFound = errors.New("credentials: not found")
+
+// Credentials are a secret and what identifies it, such as a username and
+// password or an access key.
+type Credentials struct {
+	// ID identifies the secret, such as a username or an access key id.
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+	// Token is the session token of temporary credentials.
+	Token string `json:"token,omitempty"`
+	// Expires is when the credentials expire. A zero Expires means the
+	// credentials do not expire.
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// Expired reports whether the credentials have expired.
+func (c Credentials) Expired() bool {
+	return c.expiresWithin(0)
+}
+
+// expiresWithin reports whether the credentials expire within d
+func (c Credentials) expiresWithin(d time.Duration) bool {
+	return !c.Expires.IsZero() && time.Until(c.Expires) <= d
+}
+
+// Provider retrieves credentials. Implementations must be safe for
+// concurrent use.
+type Provider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// ProviderFunc is an adapter to use a function as a Provider.
+type ProviderFunc func(ctx context.Context) (Credentials, error)
+
+func (f ProviderFunc) Retrieve(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// Static returns a Provider that always returns creds.
+func Static(creds Credentials) Provider {
+	return ProviderFunc(func(context.Context) (Credentials, error) {
+		return creds, nil
+	})
+}
+
+// Env returns a Provider reading the credentials from environment variables
+// on every call. tokenVar is optional. The error wraps ErrNotFound when
+// idVar or secretVar is not set.
+func Env(idVar, secretVar, tokenVar string) Provider {
+	return ProviderFunc(func(context.Context) (Credentials, error) {
+		var creds Credentials
+		for _, v := range []struct {
+			name  string
+			value *string
+		}{{idVar, &creds.ID}, {secretVar, &creds.Secret}} {
+			value, ok := os.LookupEnv(v.name)
+			if !ok {
+				return Credentials{}, fmt.Errorf("%w: environment variable %s is not set", ErrNotFound, v.name)
+			}
+			*v.value = value
+		}
+		if tokenVar != "" {
+			creds.Token = os.Getenv(tokenVar)
+		}
+		return creds, nil
+	})
+}
+
+// Chain returns a Provider returning the credentials of the first of
+// providers that has them. The error joins the errors of all the providers.
+func Chain(providers ...Provider) Provider {
+	return ProviderFunc(func(ctx context.Context) (Credentials, error) {
+		errs := make([]error, 0, len(providers))
+		for _, provider := range providers {
+			creds, err := provider.Retrieve(ctx)
+			if err == nil {
+				return creds, nil
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return Credentials{}, ctxErr
+			}
+			errs = append(errs, err)
+		}
+		if len(errs) == 0 {
+			return Credentials{}, ErrNotFound
+		}
+		return Credentials{}, errors.Join(errs...)
+	})
+}
@@ -0,0 +1,211 @@
+package credentials_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/SirWaithaka/gorequest/credentials"
+)
+
+func TestEnv(t *testing.T) {
+	provider := credentials.Env("TEST_USER", "TEST_PASSWORD", "TEST_TOKEN")
+
+	t.Run("test that credentials are read from the environment", func(t *testing.T) {
+		t.Setenv("TEST_USER", "user")
+		t.Setenv("TEST_PASSWORD", "secret")
+		t.Setenv("TEST_TOKEN", "token")
+
+		creds, err := provider.Retrieve(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, credentials.Credentials{ID: "user", Secret: "secret", Token: "token"}, creds)
+	})
+
+	t.Run("test that missing variables are not found", func(t *testing.T) {
+		t.Setenv("TEST_USER", "user")
+
+		_, err := provider.Retrieve(context.Background())
+		assert.ErrorIs(t, err, credentials.ErrNotFound)
+		assert.EqualError(t, err, "credentials: not found: environment variable TEST_PASSWORD is not set")
+	})
+}
+
+func TestChain(t *testing.T) {
+	missing := credentials.ProviderFunc(func(context.Context) (credentials.Credentials, error) {
+		return credentials.Credentials{}, credentials.ErrNotFound
+	})
+
+	t.Run("test that the first provider with credentials is used", func(t *testing.T) {
+		chain := credentials.Chain(missing, credentials.Static(credentials.Credentials{ID: "second"}), credentials.Static(credentials.Credentials{ID: "third"}))
+		creds, err := chain.Retrieve(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "second", creds.ID)
+	})
+
+	t.Run("test that the errors of all providers are returned", func(t *testing.T) {
+		failing := credentials.ProviderFunc(func(context.Context) (credentials.Credentials, error) {
+			return credentials.Credentials{}, errors.New("vault sealed")
+		})
+		_, err := credentials.Chain(missing, failing).Retrieve(context.Background())
+		assert.ErrorIs(t, err, credentials.ErrNotFound)
+		assert.ErrorContains(t, err, "vault sealed")
+
+		_, err = credentials.Chain().Retrieve(context.Background())
+		assert.ErrorIs(t, err, credentials.ErrNotFound)
+	})
+}
+
+func TestFileProvider(t *testing.T) {
+	write := func(t *testing.T, path, content string, modTime time.Time) {
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		assert.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	t.Run("test that rotated credentials are loaded", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "credentials.json")
+		write(t, path, `{"id": "user", "secret": "first"}`, time.Now().Add(-time.Minute))
+
+		provider := credentials.NewFileProvider(credentials.FileConfig{Path: path, PollInterval: 5 * time.Millisecond})
+
+		creds, err := provider.Retrieve(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, credentials.Credentials{ID: "user", Secret: "first"}, creds)
+
+		write(t, path, `{"id": "user", "secret": "second"}`, time.Now())
+		assert.Eventually(t, func() bool {
+			creds, _ := provider.Retrieve(context.Background())
+			return creds.Secret == "second"
+		}, time.Second, 5*time.Millisecond)
+
+		// a file that can not be parsed leaves the loaded credentials
+		write(t, path, `{"id": `, time.Now().Add(time.Minute))
+		time.Sleep(20 * time.Millisecond)
+		creds, err = provider.Retrieve(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "second", creds.Secret)
+	})
+
+	t.Run("test that the file is not checked again within the poll interval", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "credentials.json")
+		write(t, path, `{"id": "user", "secret": "first"}`, time.Now().Add(-time.Minute))
+
+		provider := credentials.NewFileProvider(credentials.FileConfig{Path: path, PollInterval: time.Hour})
+		write(t, path, `{"id": "user", "secret": "second"}`, time.Now())
+
+		creds, err := provider.Retrieve(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "first", creds.Secret)
+	})
+
+	t.Run("test that the file format is configurable", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "password")
+		write(t, path, "s3cret\n", time.Now())
+
+		provider := credentials.NewFileProvider(credentials.FileConfig{Path: path, Parse: func(data []byte) (credentials.Credentials, error) {
+			return credentials.Credentials{ID: "service", Secret: string(data[:len(data)-1])}, nil
+		}})
+
+		creds, err := provider.Retrieve(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, credentials.Credentials{ID: "service", Secret: "s3cret"}, creds)
+	})
+
+	t.Run("test that a missing file is not found", func(t *testing.T) {
+		provider := credentials.NewFileProvider(credentials.FileConfig{Path: filepath.Join(t.TempDir(), "missing.json")})
+
+		_, err := provider.Retrieve(context.Background())
+		assert.ErrorIs(t, err, credentials.ErrNotFound)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+// countingProvider returns credentials with secrets numbered from 1 that
+// expire after ttl
+type countingProvider struct {
+	calls atomic.Int32
+	ttl   time.Duration
+	delay time.Duration
+	err   error
+}
+
+func (p *countingProvider) Retrieve(context.Context) (credentials.Credentials, error) {
+	n := p.calls.Add(1)
+	time.Sleep(p.delay)
+	if p.err != nil && n > 1 {
+		return credentials.Credentials{}, p.err
+	}
+	creds := credentials.Credentials{ID: "user", Secret: fmt.Sprintf("secret-%d", n)}
+	if p.ttl > 0 {
+		creds.Expires = time.Now().Add(p.ttl)
+	}
+	return creds, nil
+}
+
+func TestCache(t *testing.T) {
+
+	t.Run("test that concurrent calls share one retrieval", func(t *testing.T) {
+		provider := &countingProvider{delay: 20 * time.Millisecond}
+		cache := credentials.NewCache(provider, credentials.CacheConfig{})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				creds, err := cache.Retrieve(context.Background())
+				assert.NoError(t, err)
+				assert.Equal(t, "secret-1", creds.Secret)
+			}()
+		}
+		wg.Wait()
+
+		_, _ = cache.Retrieve(context.Background())
+		assert.Equal(t, int32(1), provider.calls.Load())
+	})
+
+	t.Run("test that credentials are renewed before they expire", func(t *testing.T) {
+		provider := &countingProvider{ttl: 30 * time.Second}
+		cache := credentials.NewCache(provider, credentials.CacheConfig{EarlyExpiry: time.Minute})
+
+		_, _ = cache.Retrieve(context.Background())
+		creds, err := cache.Retrieve(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "secret-2", creds.Secret)
+	})
+
+	t.Run("test that credentials without expiry are renewed after the ttl", func(t *testing.T) {
+		provider := &countingProvider{}
+		cache := credentials.NewCache(provider, credentials.CacheConfig{TTL: 10 * time.Millisecond})
+
+		_, _ = cache.Retrieve(context.Background())
+		creds, _ := cache.Retrieve(context.Background())
+		assert.Equal(t, "secret-1", creds.Secret)
+
+		time.Sleep(20 * time.Millisecond)
+		creds, _ = cache.Retrieve(context.Background())
+		assert.Equal(t, "secret-2", creds.Secret)
+	})
+
+	t.Run("test that cached credentials are used when renewing them fails", func(t *testing.T) {
+		provider := &countingProvider{ttl: 30 * time.Second, err: errors.New("vault sealed")}
+		cache := credentials.NewCache(provider, credentials.CacheConfig{EarlyExpiry: time.Minute})
+
+		_, _ = cache.Retrieve(context.Background())
+		creds, err := cache.Retrieve(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "secret-1", creds.Secret)
+
+		// invalidated credentials are not reused
+		cache.Invalidate()
+		_, err = cache.Retrieve(context.Background())
+		assert.EqualError(t, err, "vault sealed")
+	})
+}
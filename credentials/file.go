@@ -0,0 +1,125 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval is how often a FileProvider checks its file for
+// changes by default.
+const DefaultPollInterval = 30 * time.Second
+
+// FileConfig configures a FileProvider.
+type FileConfig struct {
+	Path string
+	// PollInterval is how often the file is checked for changes, when
+	// credentials are retrieved. Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+	// Parse returns the credentials of the file content. Defaults to parsing
+	// the JSON encoding of Credentials.
+	Parse func(data []byte) (Credentials, error)
+}
+
+// NewFileProvider returns a FileProvider that has loaded the file.
+func NewFileProvider(cfg FileConfig) *FileProvider {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.Parse == nil {
+		cfg.Parse = parseJSON
+	}
+
+	p := &FileProvider{cfg: cfg, checked: time.Now()}
+	p.load()
+	return p
+}
+
+// FileProvider provides the credentials of a file, such as a mounted
+// secret. When credentials are retrieved, at most once every poll interval,
+// the file is checked for changes and loaded again when its size or
+// modification time changed, so rotated credentials are used without a
+// restart. While a changed file fails to load, the credentials loaded before
+// are returned.
+type FileProvider struct {
+	cfg FileConfig
+
+	mu      sync.RWMutex
+	creds   Credentials
+	loaded  bool
+	err     error
+	version string
+	// checked is when the file was last checked for changes
+	checked time.Time
+}
+
+func (p *FileProvider) Retrieve(context.Context) (Credentials, error) {
+	p.mu.Lock()
+	due := time.Since(p.checked) >= p.cfg.PollInterval
+	if due {
+		p.checked = time.Now()
+	}
+	p.mu.Unlock()
+	if due {
+		p.load()
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if !p.loaded {
+		return Credentials{}, p.err
+	}
+	return p.creds, nil
+}
+
+// load loads the file if it changed since it was last loaded
+func (p *FileProvider) load() {
+	info, err := os.Stat(p.cfg.Path)
+	if err != nil {
+		p.fail(err)
+		return
+	}
+	version := fmt.Sprintf("%s/%d", info.ModTime().Format(time.RFC3339Nano), info.Size())
+
+	p.mu.RLock()
+	unchanged := p.loaded && version == p.version
+	p.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	data, err := os.ReadFile(p.cfg.Path)
+	if err != nil {
+		p.fail(err)
+		return
+	}
+	creds, err := p.cfg.Parse(data)
+	if err != nil {
+		p.fail(fmt.Errorf("credentials: parsing %s: %w", p.cfg.Path, err))
+		return
+	}
+
+	p.mu.Lock()
+	p.creds, p.loaded, p.err, p.version = creds, true, nil, version
+	p.mu.Unlock()
+}
+
+func (p *FileProvider) fail(err error) {
+	if os.IsNotExist(err) {
+		err = fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+
+	p.mu.Lock()
+	p.err = err
+	p.mu.Unlock()
+}
+
+func parseJSON(data []byte) (Credentials, error) {
+	var creds Credentials
+	err := json.Unmarshal(data, &creds)
+	return creds, err
+}
@@ -0,0 +1,196 @@
+// Package digest provides hooks that set and verify the integrity fields of
+// RFC 9530, Content-Digest and Repr-Digest.
+package digest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/SirWaithaka/gorequest"
+)
+
+// Algorithm is a digest algorithm of the hash algorithms for HTTP digest
+// fields registry.
+type Algorithm string
+
+const (
+	SHA256 Algorithm = "sha-256"
+	SHA512 Algorithm = "sha-512"
+)
+
+// newHash returns the hash of the algorithm. ok is false for algorithms that
+// are not supported, including the insecure ones of the registry.
+func (a Algorithm) newHash() (h hash.Hash, ok bool) {
+	switch a {
+	case SHA256:
+		return sha256.New(), true
+	case SHA512:
+		return sha512.New(), true
+	}
+	return nil, false
+}
+
+const (
+	ContentDigestHeader = "Content-Digest"
+	ReprDigestHeader    = "Repr-Digest"
+)
+
+// ErrMissingDigest is the error of a response without a digest of a
+// supported algorithm, when digests are required.
+var ErrMissingDigest = errors.New("digest: response has no supported digest")
+
+// IntegrityError is the error of a response whose body does not match a
+// digest field. It is classified as gorequest.ErrorKindDecode.
+type IntegrityError struct {
+	// Header is the field of the digest, Content-Digest or Repr-Digest.
+	Header    string
+	Algorithm Algorithm
+	Expected  []byte
+	Actual    []byte
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("digest: %s %s mismatch, expected %s, got %s", e.Header, e.Algorithm,
+		base64.StdEncoding.EncodeToString(e.Expected), base64.StdEncoding.EncodeToString(e.Actual))
+}
+
+// ErrorKind classifies the error as a failure decoding the response
+func (e *IntegrityError) ErrorKind() gorequest.ErrorKind {
+	return gorequest.ErrorKindDecode
+}
+
+// Config configures a Digest.
+type Config struct {
+	// Algorithms are the algorithms of the Content-Digest of request bodies.
+	// Defaults to SHA256.
+	Algorithms []Algorithm
+	// Require fails responses with a body that have no digest of a
+	// supported algorithm with ErrMissingDigest.
+	Require bool
+}
+
+// New returns a Digest.
+func New(cfg Config) *Digest {
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = []Algorithm{SHA256}
+	}
+	return &Digest{cfg: cfg}
+}
+
+// Digest provides the hooks that set the Content-Digest of request bodies and
+// verify the Content-Digest and Repr-Digest of response bodies.
+type Digest struct {
+	cfg Config
+}
+
+// Apply registers the digest hooks on hooks. Apply it after a Compressor, so
+// that the digest is computed over the compressed body, and after a
+// Decompressor, so that the digest is verified over the body as it was
+// received.
+func (d *Digest) Apply(hooks *gorequest.Hooks) {
+	hooks.Build.PushBackHook(d.Content())
+	hooks.Unmarshal.PushFrontHook(d.Verify())
+	hooks.Unmarshal.PushBackHook(d.Check())
+}
+
+// Content returns a build hook that sets the Content-Digest header of the
+// request to the digest of its body. Register it after the hooks encoding the
+// body, and before a hook signing the request. The body is made replayable
+// if it is not, so that retries send the body the digest is of.
+func (d *Digest) Content() gorequest.Hook {
+	return gorequest.Hook{Name: "digest.Content", Fn: func(r *gorequest.Request) {
+		req := r.Request
+		if req.Body == nil || req.Body == http.NoBody {
+			return
+		}
+
+		if req.GetBody == nil {
+			data, err := io.ReadAll(req.Body)
+			_ = req.Body.Close()
+			if err != nil {
+				r.Error = err
+				return
+			}
+			req.ContentLength = int64(len(data))
+			req.Body = io.NopCloser(bytes.NewReader(data))
+			req.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(data)), nil
+			}
+		}
+
+		body, err := req.GetBody()
+		if err != nil {
+			r.Error = err
+			return
+		}
+		defer body.Close()
+
+		hashes := make([]hash.Hash, 0, len(d.cfg.Algorithms))
+		writers := make([]io.Writer, 0, len(d.cfg.Algorithms))
+		for _, alg := range d.cfg.Algorithms {
+			h, ok := alg.newHash()
+			if !ok {
+				r.Error = fmt.Errorf("digest: unsupported algorithm %s", alg)
+				return
+			}
+			hashes = append(hashes, h)
+			writers = append(writers, h)
+		}
+		if _, err = io.Copy(io.MultiWriter(writers...), body); err != nil {
+			r.Error = err
+			return
+		}
+
+		members := make([]string, len(hashes))
+		for i, h := range hashes {
+			members[i] = string(d.cfg.Algorithms[i]) + "=:" + base64.StdEncoding.EncodeToString(h.Sum(nil)) + ":"
+		}
+		req.Header.Set(ContentDigestHeader, strings.Join(members, ", "))
+	}}
+}
+
+// parseDigests parses a digest field, a dictionary of byte sequences keyed
+// by algorithm. Members of algorithms that are not supported are skipped.
+func parseDigests(header http.Header, name string) (map[Algorithm][]byte, error) {
+	values := header.Values(name)
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	digests := map[Algorithm][]byte{}
+	for _, member := range strings.Split(strings.Join(values, ","), ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(member, "=")
+		if !ok {
+			return nil, fmt.Errorf("digest: invalid %s member %q", name, member)
+		}
+
+		alg := Algorithm(strings.TrimSpace(key))
+		if _, supported := alg.newHash(); !supported {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		// parameters of the member are ignored
+		value, _, _ = strings.Cut(value, ";")
+		if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+			return nil, fmt.Errorf("digest: invalid %s member %q", name, member)
+		}
+		digest, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+		if err != nil {
+			return nil, fmt.Errorf("digest: invalid %s member %q: %w", name, member, err)
+		}
+		digests[alg] = digest
+	}
+	return digests, nil
+}
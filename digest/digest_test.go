@@ -0,0 +1,233 @@
+package digest_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/compress"
+	"github.com/SirWaithaka/gorequest/corehooks"
+	"github.com/SirWaithaka/gorequest/digest"
+)
+
+// the body and digests of the examples of RFC 9530
+const (
+	helloWorld       = `{"hello": "world"}`
+	helloWorldSHA256 = "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"
+	helloWorldSHA512 = "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:"
+)
+
+var decodeJSON = gorequest.Hook{Name: "test.Decode", Fn: func(r *gorequest.Request) {
+	defer r.Response.Body.Close()
+	if err := json.NewDecoder(r.Response.Body).Decode(r.Data); err != nil {
+		r.Error = err
+	}
+}}
+
+func newRequest(endpoint string, d *digest.Digest, method, body string, data any) *gorequest.Request {
+	hooks := corehooks.Default()
+	if body != "" {
+		hooks.Build.PushBack(func(r *gorequest.Request) {
+			r.Request.Body = io.NopCloser(strings.NewReader(body))
+		})
+	}
+	hooks.Unmarshal.PushBackHook(corehooks.ResponseStatusCode)
+	if data != nil {
+		hooks.Unmarshal.PushBackHook(decodeJSON)
+	}
+	if d != nil {
+		d.Apply(&hooks)
+	}
+
+	op := gorequest.Operation{Name: "Hello", Method: method, Path: "/hello"}
+	return gorequest.New(gorequest.Config{Endpoint: endpoint}, op, hooks, nil, nil, data)
+}
+
+func TestDigest_Content(t *testing.T) {
+
+	t.Run("test that request bodies have a content digest", func(t *testing.T) {
+		testCases := map[string]struct {
+			algorithms []digest.Algorithm
+			expected   string
+		}{
+			"default": {expected: helloWorldSHA256},
+			"sha-512": {algorithms: []digest.Algorithm{digest.SHA512}, expected: helloWorldSHA512},
+			"both":    {algorithms: []digest.Algorithm{digest.SHA512, digest.SHA256}, expected: helloWorldSHA512 + ", " + helloWorldSHA256},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				var digests []string
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					digests = append(digests, r.Header.Get("Content-Digest"))
+				}))
+				defer server.Close()
+
+				req := newRequest(server.URL, digest.New(digest.Config{Algorithms: tc.algorithms}), http.MethodPost, helloWorld, nil)
+				assert.NoError(t, req.Send())
+				assert.Equal(t, []string{tc.expected}, digests)
+			})
+		}
+	})
+
+	t.Run("test that the digested body is sent again on retries", func(t *testing.T) {
+		var bodies, digests []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			digests = append(digests, r.Header.Get("Content-Digest"))
+			if len(bodies) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+
+		req := newRequest(server.URL, digest.New(digest.Config{}), http.MethodPost, helloWorld, nil)
+		retryHook := corehooks.NewRetryer()
+		req.Hooks.Retry.PushBackHook(retryHook.Retry())
+		// retrying a POST is opted into with a policy
+		req.Retryer = gorequest.NewRetryPolicy().RetryOn(gorequest.RetryOnErrorKinds(gorequest.ErrorKindServerError))
+		req.WithRetryConfig(gorequest.RetryConfig{MaxRetries: 1, InitialDelay: time.Millisecond, Multiplier: 1, MaxDelay: time.Millisecond})
+		assert.NoError(t, req.Send())
+
+		assert.Equal(t, []string{helloWorld, helloWorld}, bodies)
+		assert.Equal(t, []string{helloWorldSHA256, helloWorldSHA256}, digests)
+	})
+
+	t.Run("test that requests without a body have no digest", func(t *testing.T) {
+		var digests []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			digests = append(digests, r.Header.Get("Content-Digest"))
+		}))
+		defer server.Close()
+
+		assert.NoError(t, newRequest(server.URL, digest.New(digest.Config{}), http.MethodGet, "", nil).Send())
+		assert.Equal(t, []string{""}, digests)
+	})
+}
+
+func TestDigest_Verify(t *testing.T) {
+	newServer := func(status int, header http.Header, body []byte) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write(body)
+		}))
+	}
+
+	t.Run("test that response digests are verified", func(t *testing.T) {
+		testCases := map[string]struct {
+			cfg    digest.Config
+			status int
+			header http.Header
+			err    error
+		}{
+			"content digest": {
+				header: http.Header{"Content-Digest": {helloWorldSHA256}},
+			},
+			"repr digest": {
+				header: http.Header{"Repr-Digest": {helloWorldSHA512 + ", " + helloWorldSHA256}},
+			},
+			"unsupported algorithm": {
+				header: http.Header{"Content-Digest": {"md5=:Sd/dVLAcvNLSq16eXua5uQ==:"}},
+			},
+			"missing": {},
+			"missing and required": {
+				cfg: digest.Config{Require: true},
+				err: digest.ErrMissingDigest,
+			},
+			"mismatch": {
+				header: http.Header{"Content-Digest": {"sha-256=:RK/0qy18MlBSVnWgjwz6lZEWjP/lF5HF9bvEF8FabDg=:"}},
+				err:    &digest.IntegrityError{},
+			},
+			"repr digest of partial content": {
+				status: http.StatusPartialContent,
+				header: http.Header{"Repr-Digest": {"sha-256=:RK/0qy18MlBSVnWgjwz6lZEWjP/lF5HF9bvEF8FabDg=:"}},
+			},
+			"malformed": {
+				header: http.Header{"Content-Digest": {"sha-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE="}},
+				err:    errors.New(`digest: invalid Content-Digest member "sha-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE="`),
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				status := tc.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				server := newServer(status, tc.header, []byte(helloWorld))
+				defer server.Close()
+
+				var data map[string]string
+				err := newRequest(server.URL, digest.New(tc.cfg), http.MethodGet, "", &data).Send()
+
+				var integrityErr *digest.IntegrityError
+				switch {
+				case tc.err == nil:
+					assert.NoError(t, err)
+					assert.Equal(t, map[string]string{"hello": "world"}, data)
+				case errors.As(tc.err, &integrityErr):
+					assert.ErrorAs(t, err, &integrityErr)
+					assert.Equal(t, digest.ContentDigestHeader, integrityErr.Header)
+					assert.Equal(t, digest.SHA256, integrityErr.Algorithm)
+					actual := sha256.Sum256([]byte(helloWorld))
+					assert.Equal(t, actual[:], integrityErr.Actual)
+					assert.Equal(t, gorequest.ErrorKindDecode, integrityErr.ErrorKind())
+				default:
+					assert.EqualError(t, err, tc.err.Error())
+				}
+			})
+		}
+	})
+
+	t.Run("test that streamed bodies are verified when they are read", func(t *testing.T) {
+		server := newServer(http.StatusOK, http.Header{"Content-Digest": {helloWorldSHA512}}, []byte(`{"hello": "world!"}`))
+		defer server.Close()
+
+		req := newRequest(server.URL, digest.New(digest.Config{}), http.MethodGet, "", nil)
+		assert.NoError(t, req.Send())
+
+		body, err := io.ReadAll(req.Response.Body)
+		var integrityErr *digest.IntegrityError
+		assert.ErrorAs(t, err, &integrityErr)
+		assert.Equal(t, digest.SHA512, integrityErr.Algorithm)
+		assert.Equal(t, `{"hello": "world!"}`, string(body))
+	})
+
+	t.Run("test that digests of compressed responses are verified before decoding", func(t *testing.T) {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, _ = gz.Write([]byte(helloWorld))
+		_ = gz.Close()
+		sum := sha256.Sum256(buf.Bytes())
+
+		server := newServer(http.StatusOK, http.Header{
+			"Content-Encoding": {"gzip"},
+			"Content-Digest":   {"sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"},
+		}, buf.Bytes())
+		defer server.Close()
+
+		var data map[string]string
+		req := newRequest(server.URL, nil, http.MethodGet, "", &data)
+		compress.NewDecompressor(compress.Config{}).Apply(&req.Hooks)
+		// applied after the decompressor, the digest is verified first
+		digest.New(digest.Config{Require: true}).Apply(&req.Hooks)
+		assert.NoError(t, req.Send())
+		assert.Equal(t, map[string]string{"hello": "world"}, data)
+	})
+}
@@ -0,0 +1,172 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"hash"
+	"io"
+	"net/http"
+
+	"github.com/SirWaithaka/gorequest"
+)
+
+type bodyKey struct{}
+
+// supported are the algorithms verified, in the order they are compared
+var supported = []Algorithm{SHA512, SHA256}
+
+// Verify returns an unmarshal hook that replaces the response body with a
+// reader verifying it against the Content-Digest and Repr-Digest of the
+// response as it is read. Reading the end of the body returns an
+// *IntegrityError when a digest does not match, so a body that is streamed
+// by the caller is verified too. Register it as the first unmarshal hook.
+//
+// Responses decoded by the transport are not verified, since the digests
+// are of the encoded body. Repr-Digest is not verified for partial content.
+func (d *Digest) Verify() gorequest.Hook {
+	return gorequest.Hook{Name: "digest.Verify", Fn: func(r *gorequest.Request) {
+		resp := r.Response
+		if r.Error != nil || resp == nil || resp.Uncompressed || !hasContent(r.Request, resp) {
+			return
+		}
+
+		body := &verifyingBody{src: resp.Body, response: resp}
+		for _, name := range []string{ContentDigestHeader, ReprDigestHeader} {
+			if name == ReprDigestHeader && resp.StatusCode == http.StatusPartialContent {
+				continue
+			}
+			digests, err := parseDigests(resp.Header, name)
+			if err != nil {
+				r.Error = err
+				return
+			}
+			for _, alg := range supported {
+				if expected, ok := digests[alg]; ok {
+					h, _ := alg.newHash()
+					body.digests = append(body.digests, expectedDigest{header: name, alg: alg, expected: expected, hash: h})
+				}
+			}
+		}
+
+		if len(body.digests) == 0 {
+			if d.cfg.Require {
+				r.Error = ErrMissingDigest
+			}
+			return
+		}
+		if resp.Body == nil {
+			body.src = http.NoBody
+		}
+		resp.Body = body
+		r.WithContext(context.WithValue(r.Context(), bodyKey{}, body))
+	}}
+}
+
+// Check returns an unmarshal hook that completes the verification of a body
+// that the unmarshal hooks started reading without reading to its end, as
+// decoders of a single value do, and sets the error of a mismatch. Bodies
+// that were not read are left for the caller to stream. Register it as the
+// last unmarshal hook.
+func (d *Digest) Check() gorequest.Hook {
+	return gorequest.Hook{Name: "digest.Check", Fn: func(r *gorequest.Request) {
+		// the body of a previous attempt may be in the context
+		body, ok := r.Context().Value(bodyKey{}).(*verifyingBody)
+		if !ok || body.response != r.Response {
+			return
+		}
+		if body.read && !body.done {
+			body.drain()
+		}
+		if body.err != nil {
+			r.Error = body.err
+		}
+	}}
+}
+
+// hasContent returns false for responses that have no content by definition,
+// whose digest fields describe a representation that is not sent
+func hasContent(req *http.Request, resp *http.Response) bool {
+	if req != nil && req.Method == http.MethodHead {
+		return false
+	}
+	return resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified
+}
+
+type expectedDigest struct {
+	header   string
+	alg      Algorithm
+	expected []byte
+	hash     hash.Hash
+}
+
+// verifyingBody hashes a body as it is read and compares the digests once it
+// is read to the end
+type verifyingBody struct {
+	src      io.ReadCloser
+	response *http.Response
+	digests  []expectedDigest
+	// read is set once the body was read from
+	read bool
+	done bool
+	err  error
+}
+
+func (b *verifyingBody) Read(p []byte) (int, error) {
+	if b.done {
+		if b.err != nil {
+			return 0, b.err
+		}
+		return 0, io.EOF
+	}
+
+	b.read = true
+	n, err := b.src.Read(p)
+	b.write(p[:n])
+	if err == io.EOF {
+		b.finish()
+		if b.err != nil {
+			return n, b.err
+		}
+	}
+	return n, err
+}
+
+func (b *verifyingBody) write(p []byte) {
+	for _, d := range b.digests {
+		d.hash.Write(p)
+	}
+}
+
+// drain reads the rest of the body into the hashes and compares the digests
+func (b *verifyingBody) drain() {
+	buf := make([]byte, 32<<10)
+	for !b.done {
+		if _, err := b.Read(buf); err != nil && err != io.EOF && b.err == nil {
+			// the body could not be read to the end, the read error is left
+			// to the hooks reading the body
+			b.done = true
+		}
+	}
+}
+
+func (b *verifyingBody) finish() {
+	b.done = true
+	for _, d := range b.digests {
+		if actual := d.hash.Sum(nil); !bytes.Equal(actual, d.expected) {
+			b.err = &IntegrityError{Header: d.header, Algorithm: d.alg, Expected: d.expected, Actual: actual}
+			return
+		}
+	}
+}
+
+// Close completes the verification of a body that was partially read before
+// closing the body, and returns the error of a mismatch.
+func (b *verifyingBody) Close() error {
+	if b.read && !b.done {
+		b.drain()
+	}
+	if err := b.src.Close(); err != nil {
+		return err
+	}
+	return b.err
+}
@@ -0,0 +1,66 @@
+package gorequest
+
+import (
+	"context"
+	"strings"
+)
+
+// EndpointVariant is a set of alternative endpoints of a service.
+type EndpointVariant uint
+
+const (
+	// EndpointFIPS selects endpoints using FIPS 140 validated cryptography.
+	EndpointFIPS EndpointVariant = 1 << iota
+	// EndpointDualStack selects endpoints reachable over IPv4 and IPv6.
+	EndpointDualStack
+)
+
+func (v EndpointVariant) String() string {
+	if v == 0 {
+		return "default"
+	}
+
+	var names []string
+	if v&EndpointFIPS != 0 {
+		names = append(names, "fips")
+	}
+	if v&EndpointDualStack != 0 {
+		names = append(names, "dualstack")
+	}
+	return strings.Join(names, "|")
+}
+
+// EndpointParams are the parameters an endpoint is resolved from.
+type EndpointParams struct {
+	ServiceName string
+	Region      string
+	Operation   Operation
+	Variant     EndpointVariant
+	// Variables are additional variables of endpoint templates
+	Variables map[string]string
+}
+
+// EndpointParamsFromRequest returns the endpoint parameters of the request
+// config and operation.
+func EndpointParamsFromRequest(r *Request) EndpointParams {
+	return EndpointParams{
+		ServiceName: r.Config.ServiceName,
+		Region:      r.Config.Region,
+		Operation:   r.Operation,
+		Variant:     r.Config.EndpointVariant,
+		Variables:   r.Config.EndpointVariables,
+	}
+}
+
+// EndpointResolver resolves the endpoint url of a request. The operation path
+// is appended to the resolved endpoint.
+type EndpointResolver interface {
+	ResolveEndpoint(ctx context.Context, params EndpointParams) (string, error)
+}
+
+// EndpointResolverFunc is a function implementing EndpointResolver.
+type EndpointResolverFunc func(ctx context.Context, params EndpointParams) (string, error)
+
+func (f EndpointResolverFunc) ResolveEndpoint(ctx context.Context, params EndpointParams) (string, error) {
+	return f(ctx, params)
+}
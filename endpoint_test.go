@@ -0,0 +1,46 @@
+package gorequest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointVariant_String(t *testing.T) {
+	assert.Equal(t, "default", EndpointVariant(0).String())
+	assert.Equal(t, "fips", EndpointFIPS.String())
+	assert.Equal(t, "fips|dualstack", (EndpointFIPS | EndpointDualStack).String())
+}
+
+func TestRequest_SetEndpoint(t *testing.T) {
+	op := Operation{Name: "GetPost", Method: "GET", Path: "/posts/1?fields=id"}
+	req := New(Config{Endpoint: "https://example.com"}, op, Hooks{}, nil, nil, nil)
+
+	t.Run("test that the operation path is appended to the endpoint", func(t *testing.T) {
+		assert.NoError(t, req.SetEndpoint("https://eu-west-1.api.example.com/v2/"))
+		assert.Equal(t, "https://eu-west-1.api.example.com/v2/", req.Config.Endpoint)
+		assert.Equal(t, "https://eu-west-1.api.example.com/v2/posts/1?fields=id", req.Request.URL.String())
+	})
+
+	t.Run("test that changes of earlier hooks to the path and query are kept", func(t *testing.T) {
+		req := New(Config{Endpoint: "https://example.com/v1"}, op, Hooks{}, nil, nil, nil)
+		req.Request.URL.Path += "/comments"
+		query := req.Request.URL.Query()
+		query.Set("api_key", "secret")
+		req.Request.URL.RawQuery = query.Encode()
+
+		assert.NoError(t, req.SetEndpoint("http://eu-west-1.api.example.com/v2/"))
+		assert.Equal(t, "http://eu-west-1.api.example.com/v2/posts/1/comments?api_key=secret&fields=id", req.Request.URL.String())
+	})
+
+	t.Run("test that the url is moved from an empty endpoint", func(t *testing.T) {
+		req := New(Config{}, op, Hooks{}, nil, nil, nil)
+		assert.NoError(t, req.SetEndpoint("https://api.example.com/v2"))
+		assert.Equal(t, "https://api.example.com/v2/posts/1?fields=id", req.Request.URL.String())
+	})
+
+	t.Run("test that invalid endpoints are an error", func(t *testing.T) {
+		assert.ErrorContains(t, req.SetEndpoint("https://example.com/%zz"), "invalid endpoint url")
+		assert.Equal(t, "https://eu-west-1.api.example.com/v2/", req.Config.Endpoint)
+	})
+}
@@ -0,0 +1,154 @@
+// Package endpoints resolves the endpoints of requests from url templates,
+// such as "https://{region}.api.example.com/{version}".
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/SirWaithaka/gorequest"
+)
+
+var (
+	// ErrNoEndpoint is returned when no template matches the endpoint parameters.
+	ErrNoEndpoint = errors.New("endpoints: no endpoint")
+	// ErrInvalidVariable is returned when the region or a variable of the
+	// request holds characters other than letters, digits and "-._~".
+	ErrInvalidVariable = errors.New("endpoints: invalid variable")
+)
+
+// Config configures a Resolver. The template of an endpoint is, in order of
+// precedence, the template of the operation, of the variant, of the service,
+// and the default template.
+//
+// Templates reference variables in braces. The variables are {service},
+// {region} and {operation}, the Defaults, and the variables of the request,
+// which replace the defaults. The region and the variables of the request may
+// only hold letters, digits and "-._~", so that they can not change the host
+// or path the template points to.
+type Config struct {
+	// Template is the default template.
+	Template string
+	// Services are the templates by service name.
+	Services map[string]string
+	// Operations are the templates by operation name, such as an operation
+	// served by another host. They are not used for endpoint variants.
+	Operations map[string]string
+	// Variants are the templates by endpoint variant, such as
+	// "https://{service}-fips.{region}.example.com". A request for a variant
+	// without a template fails, rather than using a default endpoint.
+	Variants map[gorequest.EndpointVariant]string
+	// Defaults are the default values of template variables.
+	Defaults map[string]string
+	// RegionEnv is the environment variable the region is read from when the
+	// request has no region.
+	RegionEnv string
+}
+
+// New returns a Resolver of the templates of cfg.
+func New(cfg Config) *Resolver {
+	return &Resolver{cfg: cfg}
+}
+
+// Resolver is a gorequest.EndpointResolver expanding endpoint templates.
+type Resolver struct {
+	cfg Config
+}
+
+func (r *Resolver) ResolveEndpoint(_ context.Context, params gorequest.EndpointParams) (string, error) {
+	template, err := r.template(params)
+	if err != nil {
+		return "", err
+	}
+
+	region := params.Region
+	if region == "" && r.cfg.RegionEnv != "" {
+		region = os.Getenv(r.cfg.RegionEnv)
+	}
+
+	if !validValue(region) {
+		return "", fmt.Errorf("%w: region %q", ErrInvalidVariable, region)
+	}
+
+	vars := make(map[string]string, len(r.cfg.Defaults)+len(params.Variables)+3)
+	for k, v := range r.cfg.Defaults {
+		vars[k] = v
+	}
+	for k, v := range params.Variables {
+		if !validValue(v) {
+			return "", fmt.Errorf("%w: {%s} %q", ErrInvalidVariable, k, v)
+		}
+		vars[k] = v
+	}
+	vars["service"] = params.ServiceName
+	vars["region"] = region
+	vars["operation"] = params.Operation.Name
+
+	return expand(template, vars)
+}
+
+// template returns the template matching params
+func (r *Resolver) template(params gorequest.EndpointParams) (string, error) {
+	if params.Variant != 0 {
+		if template, ok := r.cfg.Variants[params.Variant]; ok {
+			return template, nil
+		}
+		return "", fmt.Errorf("%w for variant %s", ErrNoEndpoint, params.Variant)
+	}
+	if template, ok := r.cfg.Operations[params.Operation.Name]; ok {
+		return template, nil
+	}
+	if template, ok := r.cfg.Services[params.ServiceName]; ok {
+		return template, nil
+	}
+	if r.cfg.Template != "" {
+		return r.cfg.Template, nil
+	}
+	return "", fmt.Errorf("%w for service %q", ErrNoEndpoint, params.ServiceName)
+}
+
+// expand replaces the variables of template with their values. Variables
+// without a value are an error, so that a missing region does not produce a
+// malformed host.
+func expand(template string, vars map[string]string) (string, error) {
+	orig := template
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			b.WriteString(template)
+			return b.String(), nil
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("endpoints: unclosed variable in template %q", orig)
+		}
+		end += start
+
+		name := template[start+1 : end]
+		value := vars[name]
+		if value == "" {
+			return "", fmt.Errorf("endpoints: no value for variable {%s}", name)
+		}
+		b.WriteString(template[:start])
+		b.WriteString(value)
+		template = template[end+1:]
+	}
+}
+
+// validValue reports whether v only holds unreserved url characters, which
+// can not end a host or path segment
+func validValue(v string) bool {
+	for _, c := range v {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
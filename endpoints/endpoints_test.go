@@ -0,0 +1,104 @@
+package endpoints_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/endpoints"
+)
+
+func TestResolver(t *testing.T) {
+	resolver := endpoints.New(endpoints.Config{
+		Template: "https://{service}.{region}.example.com/{version}",
+		Services: map[string]string{
+			"billing": "https://{region}.billing.example.com/{env}",
+		},
+		Operations: map[string]string{
+			"UploadFile": "https://uploads.{region}.example.com",
+		},
+		Variants: map[gorequest.EndpointVariant]string{
+			gorequest.EndpointFIPS:                               "https://{service}-fips.{region}.example.com/{version}",
+			gorequest.EndpointDualStack:                          "https://{service}.{region}.api.example.net/{version}",
+			gorequest.EndpointFIPS | gorequest.EndpointDualStack: "https://{service}-fips.{region}.api.example.net/{version}",
+		},
+		Defaults: map[string]string{"version": "v1", "env": "prod"},
+	})
+
+	tcs := map[string]struct {
+		params   gorequest.EndpointParams
+		expected string
+	}{
+		"default template": {
+			params:   gorequest.EndpointParams{ServiceName: "posts", Region: "eu-west-1"},
+			expected: "https://posts.eu-west-1.example.com/v1",
+		},
+		"service template": {
+			params:   gorequest.EndpointParams{ServiceName: "billing", Region: "eu-west-1", Variables: map[string]string{"env": "staging"}},
+			expected: "https://eu-west-1.billing.example.com/staging",
+		},
+		"operation override": {
+			params:   gorequest.EndpointParams{ServiceName: "posts", Region: "us-east-1", Operation: gorequest.Operation{Name: "UploadFile"}},
+			expected: "https://uploads.us-east-1.example.com",
+		},
+		"fips variant": {
+			params:   gorequest.EndpointParams{ServiceName: "posts", Region: "us-east-1", Operation: gorequest.Operation{Name: "UploadFile"}, Variant: gorequest.EndpointFIPS},
+			expected: "https://posts-fips.us-east-1.example.com/v1",
+		},
+		"fips and dualstack variant": {
+			params:   gorequest.EndpointParams{ServiceName: "posts", Region: "us-east-1", Variant: gorequest.EndpointFIPS | gorequest.EndpointDualStack, Variables: map[string]string{"version": "v2"}},
+			expected: "https://posts-fips.us-east-1.api.example.net/v2",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run("test that the endpoint is resolved from the "+name, func(t *testing.T) {
+			endpoint, err := resolver.ResolveEndpoint(context.Background(), tc.params)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, endpoint)
+		})
+	}
+
+	t.Run("test that the region is read from the environment", func(t *testing.T) {
+		t.Setenv("TEST_REGION", "ap-south-1")
+		resolver := endpoints.New(endpoints.Config{Template: "https://{region}.example.com", RegionEnv: "TEST_REGION"})
+
+		endpoint, err := resolver.ResolveEndpoint(context.Background(), gorequest.EndpointParams{})
+		assert.NoError(t, err)
+		assert.Equal(t, "https://ap-south-1.example.com", endpoint)
+
+		endpoint, err = resolver.ResolveEndpoint(context.Background(), gorequest.EndpointParams{Region: "eu-west-1"})
+		assert.NoError(t, err)
+		assert.Equal(t, "https://eu-west-1.example.com", endpoint)
+	})
+
+	t.Run("test that unresolved endpoints are an error", func(t *testing.T) {
+		_, err := resolver.ResolveEndpoint(context.Background(), gorequest.EndpointParams{ServiceName: "posts"})
+		assert.EqualError(t, err, "endpoints: no value for variable {region}")
+
+		_, err = endpoints.New(endpoints.Config{Template: "https://{region.example.com"}).ResolveEndpoint(context.Background(), gorequest.EndpointParams{Region: "eu-west-1"})
+		assert.EqualError(t, err, `endpoints: unclosed variable in template "https://{region.example.com"`)
+
+		_, err = endpoints.New(endpoints.Config{}).ResolveEndpoint(context.Background(), gorequest.EndpointParams{ServiceName: "posts"})
+		assert.ErrorIs(t, err, endpoints.ErrNoEndpoint)
+
+		_, err = endpoints.New(endpoints.Config{Template: "https://example.com"}).ResolveEndpoint(context.Background(), gorequest.EndpointParams{Variant: gorequest.EndpointFIPS})
+		assert.ErrorIs(t, err, endpoints.ErrNoEndpoint)
+		assert.EqualError(t, err, "endpoints: no endpoint for variant fips")
+	})
+	t.Run("test that variables of the request can not change the host", func(t *testing.T) {
+		for _, value := range []string{"evil.com/", "user@evil.com", "evil.com:8080", "v1?x=1", "v1#x"} {
+			_, err := resolver.ResolveEndpoint(context.Background(), gorequest.EndpointParams{
+				ServiceName: "posts",
+				Region:      "eu-west-1",
+				Variables:   map[string]string{"version": value},
+			})
+			assert.ErrorIs(t, err, endpoints.ErrInvalidVariable, value)
+		}
+
+		_, err := resolver.ResolveEndpoint(context.Background(), gorequest.EndpointParams{ServiceName: "posts", Region: "evil.com/"})
+		assert.ErrorIs(t, err, endpoints.ErrInvalidVariable)
+	})
+}
@@ -0,0 +1,265 @@
+package gorequest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// Phase is the stage of a request's lifecycle an error occurred in.
+type Phase string
+
+const (
+	PhaseValidate  Phase = "Validate"
+	PhaseBuild     Phase = "Build"
+	PhaseSend      Phase = "Send"
+	PhaseUnmarshal Phase = "Unmarshal"
+)
+
+// RequestError wraps an error returned by the hooks of a request phase.
+//
+// Send returns hook errors wrapped in a RequestError, and the request Error
+// holds the wrapped error, so comparing them to an error with == no longer
+// matches. Use errors.Is and errors.As instead.
+type RequestError struct {
+	Phase Phase
+	Err   error
+}
+
+func (e *RequestError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// withPhase wraps err with the phase it occurred in. Errors that already carry
+// a phase are returned unchanged.
+func withPhase(phase Phase, err error) error {
+	if err == nil {
+		return nil
+	}
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return err
+	}
+	return &RequestError{Phase: phase, Err: err}
+}
+
+// PhaseOf returns the phase err occurred in, and false if the error does not
+// carry a phase.
+func PhaseOf(err error) (Phase, bool) {
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.Phase, true
+	}
+	return "", false
+}
+
+// ErrorKind is the class of failure of a request.
+type ErrorKind uint
+
+const (
+	// ErrorKindNone is the kind of a nil error
+	ErrorKindNone ErrorKind = iota
+	// ErrorKindUnknown is the kind of errors that could not be classified
+	ErrorKindUnknown
+	ErrorKindCanceled
+	ErrorKindDeadlineExceeded
+	ErrorKindDNS
+	ErrorKindConnectionRefused
+	ErrorKindConnectionReset
+	ErrorKindTLS
+	ErrorKindTimeout
+	// ErrorKindThrottled is the kind of 429 responses
+	ErrorKindThrottled
+	// ErrorKindClientError is the kind of 4xx responses other than 429
+	ErrorKindClientError
+	// ErrorKindServerError is the kind of 5xx responses
+	ErrorKindServerError
+	// ErrorKindDecode is the kind of errors decoding a response
+	ErrorKindDecode
+	// ErrorKindValidation is the kind of errors validating a request
+	ErrorKindValidation
+	// ErrorKindRejected is the kind of errors of requests the client shed
+	// before sending them, for example by a bulkhead or limiter. Rejected
+	// requests are never retried.
+	ErrorKindRejected
+)
+
+var errorKindNames = [...]string{
+	ErrorKindNone:              "none",
+	ErrorKindUnknown:           "unknown",
+	ErrorKindCanceled:          "canceled",
+	ErrorKindDeadlineExceeded:  "deadline_exceeded",
+	ErrorKindDNS:               "dns",
+	ErrorKindConnectionRefused: "connection_refused",
+	ErrorKindConnectionReset:   "connection_reset",
+	ErrorKindTLS:               "tls",
+	ErrorKindTimeout:           "timeout",
+	ErrorKindThrottled:         "throttled",
+	ErrorKindClientError:       "client_error",
+	ErrorKindServerError:       "server_error",
+	ErrorKindDecode:            "decode",
+	ErrorKindValidation:        "validation",
+	ErrorKindRejected:          "rejected",
+}
+
+func (k ErrorKind) String() string {
+	if int(k) < len(errorKindNames) {
+		return errorKindNames[k]
+	}
+	return errorKindNames[ErrorKindUnknown]
+}
+
+// Retryable returns true for kinds of failures that are usually transient.
+func (k ErrorKind) Retryable() bool {
+	switch k {
+	case ErrorKindConnectionRefused, ErrorKindConnectionReset, ErrorKindTimeout,
+		ErrorKindThrottled, ErrorKindServerError:
+		return true
+	default:
+		return false
+	}
+}
+
+// ErrorKinder is implemented by errors that know their own kind.
+type ErrorKinder interface {
+	ErrorKind() ErrorKind
+}
+
+// Classify returns the kind of the error of r. The response status code is
+// used to classify errors set by hooks checking the response.
+func Classify(r *Request) ErrorKind {
+	if r.Error == nil {
+		return ErrorKindNone
+	}
+
+	if kind := classifyTransportError(r.Error); kind != ErrorKindUnknown {
+		return kind
+	}
+
+	if r.Response != nil {
+		if kind := ClassifyStatus(r.Response.StatusCode); kind != ErrorKindNone {
+			return kind
+		}
+	}
+
+	return classifyPhaseError(r.Error)
+}
+
+// ClassifyError returns the kind of err without the context of a response.
+func ClassifyError(err error) ErrorKind {
+	if err == nil {
+		return ErrorKindNone
+	}
+	if kind := classifyTransportError(err); kind != ErrorKindUnknown {
+		return kind
+	}
+	return classifyPhaseError(err)
+}
+
+// ClassifyStatus returns the kind of failure a response status code represents,
+// or ErrorKindNone for status codes that are not failures.
+func ClassifyStatus(code int) ErrorKind {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return ErrorKindThrottled
+	case code >= 500 && code < 600:
+		return ErrorKindServerError
+	case code >= 400 && code < 500:
+		return ErrorKindClientError
+	default:
+		return ErrorKindNone
+	}
+}
+
+func classifyTransportError(err error) ErrorKind {
+	var kinder ErrorKinder
+	if errors.As(err, &kinder) {
+		return kinder.ErrorKind()
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorKindCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorKindDeadlineExceeded
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorKindDNS
+	}
+
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorKindConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, syscall.ECONNABORTED), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorKindConnectionReset
+	}
+	// the transport returns io.EOF when the server closes the connection
+	// before sending a response
+	if phase, _ := PhaseOf(err); phase == PhaseSend && errors.Is(err, io.EOF) {
+		return ErrorKindConnectionReset
+	}
+
+	if isTLSError(err) {
+		return ErrorKindTLS
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorKindTimeout
+	}
+
+	return ErrorKindUnknown
+}
+
+func isTLSError(err error) bool {
+	var (
+		recordErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+	if errors.As(err, &recordErr) || errors.As(err, &alertErr) ||
+		errors.As(err, &verifyErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return true
+	}
+
+	// crypto/tls reports the alerts sent by the server, such as failed
+	// version or cipher suite negotiation, as a remote error
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "remote error"
+}
+
+func classifyPhaseError(err error) ErrorKind {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return ErrorKindDecode
+	}
+
+	phase, _ := PhaseOf(err)
+	switch phase {
+	case PhaseValidate:
+		return ErrorKindValidation
+	case PhaseUnmarshal:
+		return ErrorKindDecode
+	}
+	return ErrorKindUnknown
+}
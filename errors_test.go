@@ -0,0 +1,114 @@
+package gorequest
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	opErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://example.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", err)}}
+	}
+
+	tcs := map[string]struct {
+		Err      error
+		Expected ErrorKind
+	}{
+		"nil":                {Err: nil, Expected: ErrorKindNone},
+		"unknown":            {Err: errors.New("fake error"), Expected: ErrorKindUnknown},
+		"canceled":           {Err: fmt.Errorf("send: %w", context.Canceled), Expected: ErrorKindCanceled},
+		"deadline exceeded":  {Err: &url.Error{Err: context.DeadlineExceeded}, Expected: ErrorKindDeadlineExceeded},
+		"dns":                {Err: &url.Error{Err: &net.OpError{Err: &net.DNSError{Err: "no such host", Name: "foo.invalid"}}}, Expected: ErrorKindDNS},
+		"connection refused": {Err: opErr(syscall.ECONNREFUSED), Expected: ErrorKindConnectionRefused},
+		"connection reset":   {Err: opErr(syscall.ECONNRESET), Expected: ErrorKindConnectionReset},
+		"unexpected eof":     {Err: &url.Error{Err: io.ErrUnexpectedEOF}, Expected: ErrorKindConnectionReset},
+		"eof on send":        {Err: &RequestError{Phase: PhaseSend, Err: &url.Error{Err: io.EOF}}, Expected: ErrorKindConnectionReset},
+		"tls":                {Err: &url.Error{Err: x509.UnknownAuthorityError{}}, Expected: ErrorKindTLS},
+		"tls alert":          {Err: &url.Error{Err: &net.OpError{Op: "remote error", Err: errors.New("tls: handshake failure")}}, Expected: ErrorKindTLS},
+		"timeout":            {Err: &url.Error{Err: timeoutError{}}, Expected: ErrorKindTimeout},
+		"json syntax":        {Err: &json.SyntaxError{}, Expected: ErrorKindDecode},
+		"unmarshal phase":    {Err: &RequestError{Phase: PhaseUnmarshal, Err: errors.New("bad body")}, Expected: ErrorKindDecode},
+		"validate phase":     {Err: &RequestError{Phase: PhaseValidate, Err: errors.New("missing field")}, Expected: ErrorKindValidation},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, ClassifyError(tc.Err))
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tcs := map[string]struct {
+		StatusCode int
+		Expected   ErrorKind
+	}{
+		"throttled":    {StatusCode: http.StatusTooManyRequests, Expected: ErrorKindThrottled},
+		"client error": {StatusCode: http.StatusNotFound, Expected: ErrorKindClientError},
+		"server error": {StatusCode: http.StatusBadGateway, Expected: ErrorKindServerError},
+		"success":      {StatusCode: http.StatusOK, Expected: ErrorKindDecode},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			hooks := Hooks{}
+			hooks.Send.PushBack(func(r *Request) {
+				r.Response = &http.Response{StatusCode: tc.StatusCode, Header: http.Header{}}
+			})
+			hooks.Unmarshal.PushBack(func(r *Request) {
+				r.Error = errors.New("unexpected response")
+			})
+
+			req := New(Config{}, Operation{}, hooks, nil, nil, nil)
+			assert.Error(t, req.Send())
+			assert.Equal(t, tc.Expected, Classify(req))
+		})
+	}
+}
+
+func TestRequestError_Phase(t *testing.T) {
+	tcs := map[string]struct {
+		Hooks    func(*Hooks, error)
+		Expected Phase
+	}{
+		"validate":  {Hooks: func(h *Hooks, err error) { h.Validate.PushBack(func(r *Request) { r.Error = err }) }, Expected: PhaseValidate},
+		"build":     {Hooks: func(h *Hooks, err error) { h.Build.PushBack(func(r *Request) { r.Error = err }) }, Expected: PhaseBuild},
+		"send":      {Hooks: func(h *Hooks, err error) { h.Send.PushBack(func(r *Request) { r.Error = err }) }, Expected: PhaseSend},
+		"unmarshal": {Hooks: func(h *Hooks, err error) { h.Unmarshal.PushBack(func(r *Request) { r.Error = err }) }, Expected: PhaseUnmarshal},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			hookErr := errors.New("hook error")
+			hooks := Hooks{}
+			tc.Hooks(&hooks, hookErr)
+
+			req := New(Config{}, Operation{}, hooks, nil, nil, nil)
+			err := req.Send()
+
+			assert.ErrorIs(t, err, hookErr)
+			assert.Equal(t, hookErr.Error(), err.Error())
+			phase, ok := PhaseOf(err)
+			assert.True(t, ok)
+			assert.Equal(t, tc.Expected, phase)
+		})
+	}
+}
@@ -3,7 +3,9 @@ module github.com/SirWaithaka/gorequest
 go 1.24
 
 require (
+	github.com/andybalholm/brotli v1.2.6
 	github.com/json-iterator/go v1.1.12
+	github.com/klauspost/compress v1.19.0
 	github.com/rs/xid v1.6.0
 	github.com/stretchr/testify v1.11.1
 )
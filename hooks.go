@@ -19,8 +19,18 @@ type (
 		Build     HookList
 		Send      HookList
 		Unmarshal HookList
-		Retry     HookList
-		Complete  HookList
+		// Retry hooks run before a failed attempt is sent again. A hook stops
+		// the request with Request.StopRetry, or by setting Request.Error to
+		// another error.
+		Retry HookList
+		// Reauth hooks renew the credentials of an attempt marked with
+		// Request.Reauthenticate, before it is sent again.
+		Reauth HookList
+		// Fallback hooks run when the attempts to send a request failed,
+		// before the Complete hooks. They may answer the request with another
+		// response through Request.Recover.
+		Fallback HookList
+		Complete HookList
 	}
 )
 
@@ -132,6 +142,8 @@ func (h *Hooks) Copy() Hooks {
 		Send:      h.Send.copy(),
 		Unmarshal: h.Unmarshal.copy(),
 		Retry:     h.Retry.copy(),
+		Reauth:    h.Reauth.copy(),
+		Fallback:  h.Fallback.copy(),
 		Complete:  h.Complete.copy(),
 	}
 }
@@ -153,6 +165,12 @@ func (h *Hooks) IsEmpty() bool {
 	if h.Retry.Len() != 0 {
 		return false
 	}
+	if h.Reauth.Len() != 0 {
+		return false
+	}
+	if h.Fallback.Len() != 0 {
+		return false
+	}
 	if h.Complete.Len() != 0 {
 		return false
 	}
@@ -166,6 +184,8 @@ func (h *Hooks) Debug() map[string]string {
 	hooks["send"] = h.Send.Debug()
 	hooks["unmarshal"] = h.Unmarshal.Debug()
 	hooks["retry"] = h.Retry.Debug()
+	hooks["reauth"] = h.Reauth.Debug()
+	hooks["fallback"] = h.Fallback.Debug()
 	hooks["complete"] = h.Complete.Debug()
 	return hooks
 }
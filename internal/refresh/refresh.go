@@ -0,0 +1,104 @@
+// Package refresh caches a value that expires, such as a token or
+// credentials, and renews it with a single call shared by concurrent callers.
+package refresh
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Config configures a Cache.
+type Config[T any] struct {
+	// Fetch returns a new value.
+	Fetch func(ctx context.Context) (T, error)
+	// Valid reports whether a value may still be used.
+	Valid func(v T) bool
+	// Fresh reports whether a valid value, fetched at the given time, is
+	// used without being renewed.
+	Fresh func(v T, fetched time.Time) bool
+}
+
+// New returns a Cache.
+func New[T any](cfg Config[T]) *Cache[T] {
+	return &Cache[T]{cfg: cfg}
+}
+
+// Cache holds the last value fetched. Concurrent calls needing a new value
+// share a single fetch. If renewing fails while the cached value is still
+// valid, the cached value is returned.
+type Cache[T any] struct {
+	cfg Config[T]
+
+	mu      sync.Mutex
+	value   T
+	cached  bool
+	fetched time.Time
+	call    *call[T]
+}
+
+// call is an in-flight fetch
+type call[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// Get returns the cached value, fetching a new one when it is not fresh.
+func (c *Cache[T]) Get(ctx context.Context) (T, error) {
+	c.mu.Lock()
+	cached, ok := c.value, c.cached && c.cfg.Valid(c.value)
+	if ok && c.cfg.Fresh(cached, c.fetched) {
+		c.mu.Unlock()
+		return cached, nil
+	}
+
+	cl := c.call
+	if cl == nil {
+		cl = &call[T]{done: make(chan struct{})}
+		c.call = cl
+		// the call is shared, so it must not be canceled with the context of
+		// the caller that started it
+		go c.fetch(context.WithoutCancel(ctx), cl)
+	}
+	c.mu.Unlock()
+
+	var zero T
+	select {
+	case <-cl.done:
+	case <-ctx.Done():
+		return zero, context.Cause(ctx)
+	}
+
+	if cl.err != nil {
+		if ok {
+			return cached, nil
+		}
+		return zero, cl.err
+	}
+	return cl.value, nil
+}
+
+func (c *Cache[T]) fetch(ctx context.Context, cl *call[T]) {
+	cl.value, cl.err = c.cfg.Fetch(ctx)
+
+	c.mu.Lock()
+	if cl.err == nil {
+		c.value, c.cached, c.fetched = cl.value, true, time.Now()
+	}
+	c.call = nil
+	c.mu.Unlock()
+	close(cl.done)
+}
+
+// Invalidate drops the cached value if match reports true for it, so that the
+// next call fetches a new value.
+func (c *Cache[T]) Invalidate(match func(v T) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached && match(c.value) {
+		var zero T
+		c.value, c.cached, c.fetched = zero, false, time.Time{}
+	}
+}
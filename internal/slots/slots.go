@@ -0,0 +1,125 @@
+// Package slots bounds the number of requests in flight, queueing the
+// requests waiting for a slot in a bounded FIFO queue.
+package slots
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrFull is returned when all the slots are taken and the queue is full.
+	ErrFull = errors.New("slots: queue full")
+	// ErrTimeout is returned when a request waited in the queue for longer
+	// than the queue timeout.
+	ErrTimeout = errors.New("slots: queue timeout")
+)
+
+// New returns a Queue of limit slots. The limit is read whenever a slot may
+// be handed out, so it may change over time.
+func New(limit func() int, maxQueue int, timeout time.Duration) *Queue {
+	return &Queue{limit: limit, maxQueue: maxQueue, timeout: timeout}
+}
+
+// Queue hands out slots in the order they were asked for.
+type Queue struct {
+	limit    func() int
+	maxQueue int
+	timeout  time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	waiters  []chan struct{}
+}
+
+// InFlight returns the number of slots taken.
+func (q *Queue) InFlight() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.inFlight
+}
+
+// Queued returns the number of requests waiting for a slot.
+func (q *Queue) Queued() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiters)
+}
+
+// Acquire takes a slot, waiting in the queue if all the slots are taken. It
+// returns the number of slots taken including this one.
+func (q *Queue) Acquire(ctx context.Context) (int, error) {
+	q.mu.Lock()
+	if q.inFlight < q.limit() && len(q.waiters) == 0 {
+		q.inFlight++
+		n := q.inFlight
+		q.mu.Unlock()
+		return n, nil
+	}
+	if len(q.waiters) >= q.maxQueue {
+		q.mu.Unlock()
+		return 0, ErrFull
+	}
+
+	ready := make(chan struct{})
+	q.waiters = append(q.waiters, ready)
+	q.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if q.timeout > 0 {
+		t := time.NewTimer(q.timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case <-ready:
+		q.mu.Lock()
+		n := q.inFlight
+		q.mu.Unlock()
+		return n, nil
+	case <-timeout:
+		return 0, q.leave(ready, ErrTimeout)
+	case <-ctx.Done():
+		return 0, q.leave(ready, context.Cause(ctx))
+	}
+}
+
+// leave removes a waiter from the queue. If the waiter was handed a slot
+// meanwhile, the slot is released and err is still returned.
+func (q *Queue) leave(ready chan struct{}, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, waiter := range q.waiters {
+		if waiter == ready {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return err
+		}
+	}
+
+	// the slot was already handed over
+	q.inFlight--
+	q.dispatch()
+	return err
+}
+
+// Release returns a slot.
+func (q *Queue) Release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inFlight--
+	q.dispatch()
+}
+
+// dispatch hands free slots to queued requests. q.mu must be held.
+func (q *Queue) dispatch() {
+	for len(q.waiters) > 0 && q.inFlight < q.limit() {
+		ready := q.waiters[0]
+		q.waiters = q.waiters[1:]
+		q.inFlight++
+		close(ready)
+	}
+}
@@ -0,0 +1,264 @@
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Sample is the measurement of a completed request used to update a limit.
+type Sample struct {
+	// RTT is the round trip time of the request
+	RTT time.Duration
+	// InFlight is the number of requests in flight when the request started
+	InFlight int
+	// Dropped is true if the request failed in a way that signals overload,
+	// such as a timeout or a throttle response
+	Dropped bool
+}
+
+// Algorithm computes a concurrency limit from request samples. Implementations
+// must be safe for concurrent use.
+type Algorithm interface {
+	// Limit returns the current concurrency limit
+	Limit() int
+	// Update adjusts the limit with sample and returns the new limit
+	Update(sample Sample) int
+}
+
+// NewFixed returns an Algorithm with a limit that never changes.
+func NewFixed(limit int) Algorithm {
+	return fixed(max(limit, 1))
+}
+
+type fixed int
+
+func (f fixed) Limit() int        { return int(f) }
+func (f fixed) Update(Sample) int { return int(f) }
+
+// AIMDConfig configures an additive increase, multiplicative decrease limit.
+type AIMDConfig struct {
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+	// BackoffRatio the limit is multiplied by on a drop. Defaults to 0.9.
+	BackoffRatio float64
+	// Timeout after which a sample is treated as a drop. Zero disables it.
+	Timeout time.Duration
+}
+
+// NewAIMD returns an Algorithm that grows the limit by one while requests
+// succeed with the limit in use, and cuts it multiplicatively on drops.
+func NewAIMD(cfg AIMDConfig) Algorithm {
+	cfg.MinLimit = max(cfg.MinLimit, 1)
+	if cfg.MaxLimit <= 0 {
+		cfg.MaxLimit = 200
+	}
+	if cfg.InitialLimit <= 0 {
+		cfg.InitialLimit = 20
+	}
+	if cfg.BackoffRatio <= 0 || cfg.BackoffRatio >= 1 {
+		cfg.BackoffRatio = 0.9
+	}
+	return &aimd{cfg: cfg, limit: float64(clamp(cfg.InitialLimit, cfg.MinLimit, cfg.MaxLimit))}
+}
+
+type aimd struct {
+	cfg   AIMDConfig
+	mu    sync.Mutex
+	limit float64
+}
+
+func (a *aimd) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.limit)
+}
+
+func (a *aimd) Update(s Sample) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch {
+	case s.Dropped || (a.cfg.Timeout > 0 && s.RTT > a.cfg.Timeout):
+		a.limit = a.limit * a.cfg.BackoffRatio
+	case s.InFlight*2 >= int(a.limit):
+		// only grow when the limit is actually in use
+		a.limit++
+	}
+	a.limit = math.Max(float64(a.cfg.MinLimit), math.Min(float64(a.cfg.MaxLimit), a.limit))
+	return int(a.limit)
+}
+
+// VegasConfig configures a Vegas limit.
+type VegasConfig struct {
+	InitialLimit int
+	MaxLimit     int
+	// Smoothing applied to limit changes, between 0 and 1. Defaults to 1.
+	Smoothing float64
+}
+
+// NewVegas returns an Algorithm based on TCP Vegas. It estimates the queue
+// at the service from the ratio of the lowest observed RTT to the current RTT,
+// growing the limit while the estimated queue is small and shrinking it once
+// the queue grows.
+func NewVegas(cfg VegasConfig) Algorithm {
+	if cfg.InitialLimit <= 0 {
+		cfg.InitialLimit = 20
+	}
+	if cfg.MaxLimit <= 0 {
+		cfg.MaxLimit = 1000
+	}
+	if cfg.Smoothing <= 0 || cfg.Smoothing > 1 {
+		cfg.Smoothing = 1
+	}
+	return &vegas{cfg: cfg, limit: float64(cfg.InitialLimit)}
+}
+
+type vegas struct {
+	cfg       VegasConfig
+	mu        sync.Mutex
+	limit     float64
+	rttNoLoad time.Duration
+}
+
+func (v *vegas) Limit() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return int(v.limit)
+}
+
+func (v *vegas) Update(s Sample) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if s.RTT <= 0 {
+		return int(v.limit)
+	}
+	if v.rttNoLoad == 0 || s.RTT < v.rttNoLoad {
+		v.rttNoLoad = s.RTT
+		return int(v.limit)
+	}
+
+	logLimit := math.Max(1, math.Log10(v.limit))
+	alpha, beta := 3*logLimit, 6*logLimit
+
+	var next float64
+	switch {
+	case s.Dropped:
+		next = v.limit - logLimit
+	case float64(s.InFlight)*2 < v.limit:
+		// the limit is not in use, there is nothing to learn
+		return int(v.limit)
+	default:
+		queue := math.Ceil(v.limit * (1 - float64(v.rttNoLoad)/float64(s.RTT)))
+		switch {
+		case queue <= logLimit:
+			next = v.limit + beta
+		case queue < alpha:
+			next = v.limit + logLimit
+		case queue > beta:
+			next = v.limit - logLimit
+		default:
+			return int(v.limit)
+		}
+	}
+
+	next = math.Max(1, math.Min(float64(v.cfg.MaxLimit), next))
+	v.limit = v.limit*(1-v.cfg.Smoothing) + next*v.cfg.Smoothing
+	return int(v.limit)
+}
+
+// Gradient2Config configures a Gradient2 limit.
+type Gradient2Config struct {
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+	// Smoothing applied to limit changes, between 0 and 1. Defaults to 0.2.
+	Smoothing float64
+	// Tolerance of the long term RTT before the limit is reduced. Defaults to 1.5.
+	Tolerance float64
+	// LongWindow is the number of samples in the long term RTT average.
+	// Defaults to 600.
+	LongWindow int
+}
+
+// NewGradient2 returns an Algorithm that compares a long term exponential
+// average of the RTT with the current RTT. The limit shrinks as the current RTT
+// exceeds the long term average by more than the tolerance and grows by the
+// square root of the limit otherwise.
+func NewGradient2(cfg Gradient2Config) Algorithm {
+	if cfg.InitialLimit <= 0 {
+		cfg.InitialLimit = 20
+	}
+	cfg.MinLimit = max(cfg.MinLimit, 1)
+	if cfg.MaxLimit <= 0 {
+		cfg.MaxLimit = 200
+	}
+	if cfg.Smoothing <= 0 || cfg.Smoothing > 1 {
+		cfg.Smoothing = 0.2
+	}
+	if cfg.Tolerance < 1 {
+		cfg.Tolerance = 1.5
+	}
+	if cfg.LongWindow <= 0 {
+		cfg.LongWindow = 600
+	}
+	return &gradient2{cfg: cfg, limit: float64(cfg.InitialLimit)}
+}
+
+type gradient2 struct {
+	cfg     Gradient2Config
+	mu      sync.Mutex
+	limit   float64
+	longRTT float64
+	samples int
+}
+
+func (g *gradient2) Limit() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return int(g.limit)
+}
+
+func (g *gradient2) Update(s Sample) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	short := float64(s.RTT)
+	if short <= 0 {
+		return int(g.limit)
+	}
+
+	// exponential moving average, starting as a plain average until the
+	// window is full
+	g.samples++
+	window := float64(min(g.samples, g.cfg.LongWindow))
+	if g.longRTT == 0 {
+		g.longRTT = short
+	} else {
+		g.longRTT += (short - g.longRTT) / window
+	}
+	// drift the long term average down quickly once the service recovers
+	if g.longRTT/short > 2 {
+		g.longRTT *= 0.95
+	}
+
+	// the limit is not in use, there is nothing to learn
+	if float64(s.InFlight) < g.limit/2 {
+		return int(g.limit)
+	}
+
+	gradient := math.Max(0.5, math.Min(1, g.cfg.Tolerance*g.longRTT/short))
+	if s.Dropped {
+		gradient = 0.5
+	}
+	next := g.limit*gradient + math.Sqrt(g.limit)
+	next = g.limit*(1-g.cfg.Smoothing) + next*g.cfg.Smoothing
+	g.limit = math.Max(float64(g.cfg.MinLimit), math.Min(float64(g.cfg.MaxLimit), next))
+	return int(g.limit)
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(hi, v))
+}
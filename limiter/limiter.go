@@ -0,0 +1,178 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/internal/slots"
+)
+
+// ErrLimitExceeded is returned when a request is shed because the concurrency
+// limit was reached and it could not be queued. It is classified as
+// gorequest.ErrorKindRejected.
+var ErrLimitExceeded error = rejectedError("concurrency limit exceeded")
+
+type rejectedError string
+
+func (e rejectedError) Error() string { return string(e) }
+
+func (e rejectedError) ErrorKind() gorequest.ErrorKind { return gorequest.ErrorKindRejected }
+
+// Config configures the limiters of a Limiters group.
+type Config struct {
+	// NewAlgorithm returns the limit algorithm for a service. Defaults to
+	// a Vegas limit.
+	NewAlgorithm func(service string) Algorithm
+	// MaxQueue is the number of requests that may wait for capacity. Requests
+	// beyond the queue are rejected. Zero rejects as soon as the limit is reached.
+	MaxQueue int
+	// QueueTimeout is the longest a request waits in the queue. Zero waits until
+	// the request context is done.
+	QueueTimeout time.Duration
+}
+
+// New returns a Limiters group creating a limiter per service on first use.
+func New(cfg Config) *Limiters {
+	if cfg.NewAlgorithm == nil {
+		cfg.NewAlgorithm = func(string) Algorithm { return NewVegas(VegasConfig{}) }
+	}
+	return &Limiters{cfg: cfg, limiters: make(map[string]*Limiter)}
+}
+
+// Limiters holds a concurrency limiter for every downstream service, keyed by
+// Config.ServiceName.
+type Limiters struct {
+	cfg Config
+
+	mu       sync.Mutex
+	limiters map[string]*Limiter
+}
+
+// Limiter returns the limiter of the service.
+func (l *Limiters) Limiter(service string) *Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.limiters[service]
+	if !ok {
+		limiter = NewLimiter(l.cfg.NewAlgorithm(service), l.cfg.MaxQueue, l.cfg.QueueTimeout)
+		l.limiters[service] = limiter
+	}
+	return limiter
+}
+
+// CurrentLimit returns the current concurrency limit of the service.
+func (l *Limiters) CurrentLimit(service string) int {
+	return l.Limiter(service).Limit()
+}
+
+// Apply registers the limiter hooks. The acquire hook runs before all other
+// send hooks, and the release hook before all other retry and complete hooks,
+// so that the slot is not held while waiting to retry.
+func (l *Limiters) Apply(hooks *gorequest.Hooks) {
+	hooks.Send.PushFrontHook(l.Acquire())
+	hooks.Retry.PushFrontHook(l.Release())
+	hooks.Complete.PushFrontHook(l.Release())
+}
+
+type tokenKey struct{}
+
+// token is a slot held by a request while an attempt is sent.
+type token struct {
+	limiter  *Limiter
+	start    time.Time
+	inFlight int
+}
+
+// Acquire returns a send hook that takes a slot from the service limiter before
+// an attempt, unless the request holds one. When the limit is reached the
+// request waits in the queue, or fails with ErrLimitExceeded.
+func (l *Limiters) Acquire() gorequest.Hook {
+	return gorequest.Hook{Name: "limiter.Acquire", Fn: func(r *gorequest.Request) {
+		t, ok := r.Context().Value(tokenKey{}).(*token)
+		if ok && t.limiter != nil {
+			return
+		}
+
+		limiter := l.Limiter(r.Config.ServiceName)
+		inFlight, err := limiter.Acquire(r.Context())
+		if err != nil {
+			r.Error = err
+			return
+		}
+		if !ok {
+			t = &token{}
+			r.WithContext(context.WithValue(r.Context(), tokenKey{}, t))
+		}
+		t.limiter, t.start, t.inFlight = limiter, time.Now(), inFlight
+	}}
+}
+
+// Release returns a retry or complete hook that releases the slot of the
+// request and updates the limit with the RTT of its last attempt.
+func (l *Limiters) Release() gorequest.Hook {
+	return gorequest.Hook{Name: "limiter.Release", Fn: func(r *gorequest.Request) {
+		t, ok := r.Context().Value(tokenKey{}).(*token)
+		if !ok || t.limiter == nil {
+			return
+		}
+
+		rtt := time.Since(t.start)
+		if n := len(r.Attempts); n > 0 {
+			rtt = r.Attempts[n-1].Duration()
+		}
+
+		t.limiter.Release(Sample{RTT: rtt, InFlight: t.inFlight, Dropped: dropped(r)})
+		t.limiter = nil
+	}}
+}
+
+// dropped reports whether the request failed in a way that signals overload
+func dropped(r *gorequest.Request) bool {
+	switch gorequest.Classify(r) {
+	case gorequest.ErrorKindTimeout, gorequest.ErrorKindDeadlineExceeded, gorequest.ErrorKindThrottled:
+		return true
+	}
+	return false
+}
+
+// NewLimiter returns a Limiter with the limit computed by alg.
+func NewLimiter(alg Algorithm, maxQueue int, queueTimeout time.Duration) *Limiter {
+	return &Limiter{alg: alg, slots: slots.New(alg.Limit, maxQueue, queueTimeout)}
+}
+
+// Limiter bounds the number of requests in flight to a limit computed by an
+// Algorithm.
+type Limiter struct {
+	alg   Algorithm
+	slots *slots.Queue
+}
+
+// Limit returns the current limit
+func (l *Limiter) Limit() int {
+	return l.alg.Limit()
+}
+
+// InFlight returns the number of requests holding a slot
+func (l *Limiter) InFlight() int {
+	return l.slots.InFlight()
+}
+
+// Acquire takes a slot, waiting in the queue if the limit is reached. It returns
+// the number of requests in flight including this one.
+func (l *Limiter) Acquire(ctx context.Context) (int, error) {
+	n, err := l.slots.Acquire(ctx)
+	if errors.Is(err, slots.ErrFull) || errors.Is(err, slots.ErrTimeout) {
+		return 0, ErrLimitExceeded
+	}
+	return n, err
+}
+
+// Release returns a slot and updates the limit with sample.
+func (l *Limiter) Release(sample Sample) {
+	l.alg.Update(sample)
+	l.slots.Release()
+}
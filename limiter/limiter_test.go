@@ -0,0 +1,187 @@
+package limiter_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/corehooks"
+	"github.com/SirWaithaka/gorequest/limiter"
+)
+
+func TestAlgorithms(t *testing.T) {
+
+	t.Run("test that a fixed limit never changes", func(t *testing.T) {
+		alg := limiter.NewFixed(5)
+		alg.Update(limiter.Sample{RTT: time.Second, InFlight: 5, Dropped: true})
+		assert.Equal(t, 5, alg.Limit())
+	})
+
+	t.Run("test that aimd grows additively and backs off on drops", func(t *testing.T) {
+		alg := limiter.NewAIMD(limiter.AIMDConfig{InitialLimit: 10, BackoffRatio: 0.5})
+
+		assert.Equal(t, 11, alg.Update(limiter.Sample{RTT: time.Millisecond, InFlight: 10}))
+		// an unused limit does not grow
+		assert.Equal(t, 11, alg.Update(limiter.Sample{RTT: time.Millisecond, InFlight: 1}))
+		assert.Equal(t, 5, alg.Update(limiter.Sample{RTT: time.Millisecond, InFlight: 11, Dropped: true}))
+	})
+
+	t.Run("test that vegas grows with a low rtt and shrinks as the queue grows", func(t *testing.T) {
+		alg := limiter.NewVegas(limiter.VegasConfig{InitialLimit: 20})
+
+		// the first sample sets the no load rtt
+		alg.Update(limiter.Sample{RTT: 10 * time.Millisecond, InFlight: 20})
+		grown := alg.Update(limiter.Sample{RTT: 10 * time.Millisecond, InFlight: 20})
+		assert.Greater(t, grown, 20)
+
+		// rtt doubled, half the requests are queued at the service
+		shrunk := alg.Update(limiter.Sample{RTT: 20 * time.Millisecond, InFlight: grown})
+		assert.Less(t, shrunk, grown)
+	})
+
+	t.Run("test that gradient2 shrinks when the rtt rises above the long term rtt", func(t *testing.T) {
+		alg := limiter.NewGradient2(limiter.Gradient2Config{InitialLimit: 50, Smoothing: 1})
+
+		for i := 0; i < 100; i++ {
+			alg.Update(limiter.Sample{RTT: 10 * time.Millisecond, InFlight: 50})
+		}
+		steady := alg.Limit()
+		assert.GreaterOrEqual(t, steady, 50)
+
+		for i := 0; i < 5; i++ {
+			alg.Update(limiter.Sample{RTT: 100 * time.Millisecond, InFlight: steady})
+		}
+		assert.Less(t, alg.Limit(), steady)
+	})
+}
+
+func TestLimiter(t *testing.T) {
+
+	t.Run("test that requests beyond the limit are rejected without a queue", func(t *testing.T) {
+		l := limiter.NewLimiter(limiter.NewFixed(1), 0, 0)
+
+		_, err := l.Acquire(context.Background())
+		assert.NoError(t, err)
+		_, err = l.Acquire(context.Background())
+		assert.ErrorIs(t, err, limiter.ErrLimitExceeded)
+
+		l.Release(limiter.Sample{})
+		_, err = l.Acquire(context.Background())
+		assert.NoError(t, err)
+	})
+
+	t.Run("test that queued requests get a slot on release", func(t *testing.T) {
+		l := limiter.NewLimiter(limiter.NewFixed(1), 1, time.Second)
+		_, _ = l.Acquire(context.Background())
+
+		done := make(chan error)
+		go func() {
+			_, err := l.Acquire(context.Background())
+			done <- err
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		l.Release(limiter.Sample{})
+		assert.NoError(t, <-done)
+		assert.Equal(t, 1, l.InFlight())
+	})
+
+	t.Run("test that queued requests time out", func(t *testing.T) {
+		l := limiter.NewLimiter(limiter.NewFixed(1), 1, 10*time.Millisecond)
+		_, _ = l.Acquire(context.Background())
+
+		_, err := l.Acquire(context.Background())
+		assert.ErrorIs(t, err, limiter.ErrLimitExceeded)
+		assert.Equal(t, 1, l.InFlight())
+	})
+
+	t.Run("test that queued requests honour the context", func(t *testing.T) {
+		l := limiter.NewLimiter(limiter.NewFixed(1), 1, 0)
+		_, _ = l.Acquire(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := l.Acquire(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestLimiters(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	limiters := limiter.New(limiter.Config{
+		NewAlgorithm: func(string) limiter.Algorithm { return limiter.NewFixed(1) },
+	})
+	hooks := corehooks.Default()
+	limiters.Apply(&hooks)
+
+	cfg := gorequest.Config{Endpoint: server.URL, ServiceName: "orders"}
+	op := gorequest.Operation{Method: http.MethodGet}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		req := gorequest.New(cfg, op, hooks, nil, nil, nil)
+		assert.NoError(t, req.Send())
+	}()
+
+	// wait for the first request to hold the slot
+	assert.Eventually(t, func() bool { return limiters.Limiter("orders").InFlight() == 1 }, time.Second, time.Millisecond)
+
+	req := gorequest.New(cfg, op, hooks, nil, nil, nil)
+	err := req.Send()
+	assert.ErrorIs(t, err, limiter.ErrLimitExceeded)
+
+	// other services have their own limit
+	other := gorequest.New(gorequest.Config{Endpoint: server.URL, ServiceName: "payments"}, op, hooks, nil, nil, nil)
+	go func() { _ = other.Send() }()
+	assert.Eventually(t, func() bool { return limiters.Limiter("payments").InFlight() == 1 }, time.Second, time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 0, limiters.Limiter("orders").InFlight())
+	assert.Equal(t, 1, limiters.CurrentLimit("orders"))
+}
+
+func TestLimiters_Retry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	limiters := limiter.New(limiter.Config{
+		NewAlgorithm: func(string) limiter.Algorithm { return limiter.NewFixed(1) },
+	})
+	hooks := corehooks.Default()
+	limiters.Apply(&hooks)
+	hooks.Unmarshal.PushBackHook(corehooks.ResponseStatusCode)
+	// record the slots in flight while the request waits to retry
+	var inFlight []int
+	hooks.Retry.PushBack(func(r *gorequest.Request) {
+		inFlight = append(inFlight, limiters.Limiter("orders").InFlight())
+	})
+	retryHook := corehooks.NewRetryer()
+	hooks.Retry.PushBackHook(retryHook.Retry())
+
+	cfg := gorequest.Config{Endpoint: server.URL, ServiceName: "orders"}
+	op := gorequest.Operation{Method: http.MethodGet}
+	req := gorequest.New(cfg, op, hooks, gorequest.DefaultRetryer, nil, nil)
+	req.WithRetryConfig(gorequest.RetryConfig{MaxRetries: 2, InitialDelay: time.Millisecond, Multiplier: 1, MaxDelay: time.Millisecond})
+	assert.Error(t, req.Send())
+
+	assert.Len(t, req.Attempts, 3)
+	assert.Equal(t, []int{0, 0}, inFlight)
+	assert.Equal(t, 0, limiters.Limiter("orders").InFlight())
+}
@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/SirWaithaka/gorequest"
+)
+
+// New returns a Metrics instance whose hooks record to sink.
+func New(sink Sink) Metrics {
+	return Metrics{sink: sink}
+}
+
+// Metrics provides the hooks that record request, attempt, retry and latency
+// metrics labelled by service, operation, method and status.
+type Metrics struct {
+	sink Sink
+}
+
+type stateKey struct{}
+
+// state is the per request bookkeeping shared by the hooks. It is stored in
+// the request context by the Build hook.
+type state struct {
+	start      time.Time
+	attempts   int
+	retryStart time.Time
+	retryDelay time.Duration
+}
+
+func stateFromRequest(r *gorequest.Request) *state {
+	s, _ := r.Context().Value(stateKey{}).(*state)
+	return s
+}
+
+// Apply registers all metrics hooks on hooks.
+func (m Metrics) Apply(hooks *gorequest.Hooks) {
+	hooks.Build.PushFrontHook(m.Build())
+	hooks.Send.PushFrontHook(m.Send())
+	hooks.Send.PushBackHook(m.Attempt())
+	hooks.Retry.PushFrontHook(m.Retry())
+	hooks.Complete.PushFrontHook(m.Complete())
+}
+
+// Build records the time the request started. Register it as the first build
+// hook so the recorded duration includes all the build hooks.
+func (m Metrics) Build() gorequest.Hook {
+	return gorequest.Hook{Name: "metrics.Build", Fn: func(r *gorequest.Request) {
+		r.WithContext(context.WithValue(r.Context(), stateKey{}, &state{start: time.Now()}))
+	}}
+}
+
+// Send counts an attempt and, for retries, the time waited since the previous
+// attempt failed. Register it as the first send hook.
+func (m Metrics) Send() gorequest.Hook {
+	return gorequest.Hook{Name: "metrics.Send", Fn: func(r *gorequest.Request) {
+		s := stateFromRequest(r)
+		if s == nil {
+			return
+		}
+
+		s.attempts++
+		if !s.retryStart.IsZero() {
+			s.retryDelay += time.Since(s.retryStart)
+			s.retryStart = time.Time{}
+		}
+	}}
+}
+
+// Attempt records the connection timing phases of the attempt that was just
+// sent. Register it as the last send hook. Nothing is recorded unless the
+// corehooks.TraceConnection hook is registered.
+func (m Metrics) Attempt() gorequest.Hook {
+	return gorequest.Hook{Name: "metrics.Attempt", Fn: func(r *gorequest.Request) {
+		timing := r.Timing()
+		if timing.IsZero() {
+			return
+		}
+
+		labels := RequestLabels(r)
+		if timing.ConnReused {
+			m.sink.AddCounter(MetricConnectionReused, labels, 1)
+		}
+
+		phases := []struct {
+			name     string
+			duration time.Duration
+		}{
+			{"dns", timing.DNS},
+			{"connect", timing.Connect},
+			{"tls", timing.TLSHandshake},
+			{"server", timing.ServerProcessing},
+			{"ttfb", timing.TimeToFirstByte},
+		}
+		for _, phase := range phases {
+			if phase.duration <= 0 {
+				continue
+			}
+			phaseLabels := copyLabels(labels)
+			phaseLabels[LabelPhase] = phase.name
+			m.sink.ObserveHistogram(MetricConnectionPhase, phaseLabels, phase.duration.Seconds())
+		}
+	}}
+}
+
+// Retry marks the start of the wait before the next attempt. Register it as
+// the first retry hook so the wait in the retry hooks is included.
+func (m Metrics) Retry() gorequest.Hook {
+	return gorequest.Hook{Name: "metrics.Retry", Fn: func(r *gorequest.Request) {
+		if s := stateFromRequest(r); s != nil {
+			s.retryStart = time.Now()
+		}
+	}}
+}
+
+// Complete records the request count, errors, cache status, attempts, retry
+// delay and duration of the request.
+func (m Metrics) Complete() gorequest.Hook {
+	return gorequest.Hook{Name: "metrics.Complete", Fn: func(r *gorequest.Request) {
+		labels := RequestLabels(r)
+
+		m.sink.AddCounter(MetricRequests, labels, 1)
+		if r.Error != nil {
+			errLabels := copyLabels(labels)
+			errLabels[LabelErrorClass] = ErrorClass(r)
+			m.sink.AddCounter(MetricErrors, errLabels, 1)
+		}
+		if errors.Is(r.Error, gorequest.ErrRetryBudgetExhausted) {
+			m.sink.AddCounter(MetricRetryBudgetExhausted, labels, 1)
+		}
+		if status := r.CacheStatus(); status != "" {
+			cacheLabels := copyLabels(labels)
+			cacheLabels[LabelCacheStatus] = status
+			m.sink.AddCounter(MetricCacheResults, cacheLabels, 1)
+		}
+
+		s := stateFromRequest(r)
+		if s == nil {
+			return
+		}
+		m.sink.AddCounter(MetricAttempts, labels, float64(s.attempts))
+		if s.retryDelay > 0 {
+			m.sink.AddCounter(MetricRetryDelay, labels, s.retryDelay.Seconds())
+		}
+		m.sink.ObserveHistogram(MetricDuration, labels, time.Since(s.start).Seconds())
+	}}
+}
+
+// RequestLabels returns the service, operation, method and status labels of r.
+// Requests without a response have the status "none".
+func RequestLabels(r *gorequest.Request) Labels {
+	status := "none"
+	if r.Response != nil && r.Response.StatusCode != 0 {
+		status = strconv.Itoa(r.Response.StatusCode)
+	}
+
+	method := r.Operation.Method
+	if r.Request != nil {
+		method = r.Request.Method
+	}
+
+	return Labels{
+		LabelService:   r.Config.ServiceName,
+		LabelOperation: r.Operation.Name,
+		LabelMethod:    method,
+		LabelStatus:    status,
+	}
+}
+
+// ErrorClass returns the kind of the request error as a label value.
+func ErrorClass(r *gorequest.Request) string {
+	if r.Error == nil {
+		return ""
+	}
+	return gorequest.Classify(r).String()
+}
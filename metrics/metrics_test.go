@@ -0,0 +1,190 @@
+package metrics_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/cache"
+	"github.com/SirWaithaka/gorequest/corehooks"
+	"github.com/SirWaithaka/gorequest/metrics"
+)
+
+type temporaryError struct{ error }
+
+func (temporaryError) Temporary() bool { return true }
+
+func TestMetrics(t *testing.T) {
+
+	t.Run("test that a successful request is recorded", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		sink := metrics.NewInMemorySink()
+		hooks := corehooks.Default()
+		metrics.New(sink).Apply(&hooks)
+
+		cfg := gorequest.Config{Endpoint: server.URL, ServiceName: "posts"}
+		op := gorequest.Operation{Name: "GetPost", Method: http.MethodGet}
+		for i := 0; i < 3; i++ {
+			req := gorequest.New(cfg, op, hooks, nil, nil, nil)
+			assert.NoError(t, req.Send())
+		}
+
+		labels := metrics.Labels{
+			metrics.LabelService:   "posts",
+			metrics.LabelOperation: "GetPost",
+			metrics.LabelMethod:    http.MethodGet,
+			metrics.LabelStatus:    "200",
+		}
+		snap := sink.Snapshot()
+		assert.Equal(t, float64(3), snap.Counter(metrics.MetricRequests, labels))
+		assert.Equal(t, float64(3), snap.Counter(metrics.MetricAttempts, labels))
+		assert.Equal(t, float64(0), snap.Counter(metrics.MetricErrors, nil))
+		count, _ := snap.Histogram(metrics.MetricDuration, labels)
+		assert.Equal(t, uint64(3), count)
+	})
+
+	t.Run("test that connection timing phases are recorded", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		sink := metrics.NewInMemorySink()
+		hooks := corehooks.Default()
+		hooks.Build.PushBackHook(corehooks.TraceConnection)
+		metrics.New(sink).Apply(&hooks)
+
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, gorequest.Operation{Method: http.MethodGet}, hooks, nil, nil, nil)
+		assert.NoError(t, req.Send())
+
+		snap := sink.Snapshot()
+		for _, phase := range []string{"connect", "server", "ttfb"} {
+			count, _ := snap.Histogram(metrics.MetricConnectionPhase, metrics.Labels{metrics.LabelPhase: phase})
+			assert.Equal(t, uint64(1), count, phase)
+		}
+	})
+
+	t.Run("test that errors, attempts and retry delays are recorded", func(t *testing.T) {
+		sink := metrics.NewInMemorySink()
+		hooks := gorequest.Hooks{}
+		hooks.Send.PushBack(func(r *gorequest.Request) {
+			r.Response = &http.Response{StatusCode: http.StatusBadGateway}
+			r.Error = temporaryError{errors.New("bad gateway")}
+		})
+		retryHook := corehooks.NewRetryer()
+		hooks.Retry.PushBackHook(retryHook.Retry())
+		metrics.New(sink).Apply(&hooks)
+
+		req := gorequest.New(gorequest.Config{ServiceName: "posts"}, gorequest.Operation{Name: "CreatePost"}, hooks, gorequest.DefaultRetryer, nil, nil)
+		req.WithRetryConfig(gorequest.RetryConfig{MaxRetries: 2, InitialDelay: 10 * time.Millisecond, Multiplier: 1, MaxDelay: 10 * time.Millisecond})
+		assert.Error(t, req.Send())
+
+		labels := metrics.Labels{metrics.LabelOperation: "CreatePost", metrics.LabelStatus: "502"}
+		snap := sink.Snapshot()
+		assert.Equal(t, float64(1), snap.Counter(metrics.MetricRequests, labels))
+		assert.Equal(t, float64(3), snap.Counter(metrics.MetricAttempts, labels))
+		assert.Equal(t, float64(1), snap.Counter(metrics.MetricErrors, metrics.Labels{metrics.LabelErrorClass: "server_error"}))
+		assert.GreaterOrEqual(t, snap.Counter(metrics.MetricRetryDelay, labels), (20 * time.Millisecond).Seconds())
+	})
+	t.Run("test that retry budget exhaustion is recorded", func(t *testing.T) {
+		sink := metrics.NewInMemorySink()
+		budget := gorequest.NewRetryBudget(0, 0.1)
+
+		hooks := gorequest.Hooks{}
+		hooks.Send.PushBack(func(r *gorequest.Request) {
+			r.Error = temporaryError{errors.New("connection reset")}
+		})
+		hooks.Complete.PushBackHook(budget.Complete())
+		metrics.New(sink).Apply(&hooks)
+
+		req := gorequest.New(gorequest.Config{ServiceName: "posts"}, gorequest.Operation{Name: "GetPost"}, hooks, budget.Retryer(gorequest.DefaultRetryer), nil, nil)
+		req.WithRetryConfig(gorequest.RetryConfig{MaxRetries: 2, InitialDelay: time.Millisecond})
+		assert.Error(t, req.Send())
+
+		snap := sink.Snapshot()
+		assert.Equal(t, float64(1), snap.Counter(metrics.MetricRetryBudgetExhausted, metrics.Labels{metrics.LabelService: "posts"}))
+	})
+
+	t.Run("test that cache results are recorded by cache status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}))
+		defer server.Close()
+
+		sink := metrics.NewInMemorySink()
+		hooks := corehooks.Default()
+		cache.New(cache.Config{}).Apply(&hooks)
+		metrics.New(sink).Apply(&hooks)
+
+		cfg := gorequest.Config{Endpoint: server.URL, ServiceName: "posts"}
+		op := gorequest.Operation{Name: "GetPost", Method: http.MethodGet}
+		for i := 0; i < 3; i++ {
+			req := gorequest.New(cfg, op, hooks, nil, nil, nil)
+			assert.NoError(t, req.Send())
+		}
+
+		snap := sink.Snapshot()
+		assert.Equal(t, float64(1), snap.Counter(metrics.MetricCacheResults, metrics.Labels{metrics.LabelCacheStatus: "MISS"}))
+		assert.Equal(t, float64(2), snap.Counter(metrics.MetricCacheResults, metrics.Labels{metrics.LabelCacheStatus: "HIT"}))
+	})
+}
+
+type testCounter struct {
+	desc   metrics.Descriptor
+	values map[string]float64
+}
+
+func (c *testCounter) Add(values []string, v float64) {
+	key := ""
+	for _, value := range values {
+		key += value + "|"
+	}
+	c.values[key] += v
+}
+
+type testHistogram struct{ observed int }
+
+func (h *testHistogram) Observe(_ []string, _ float64) { h.observed++ }
+
+type testRegistry struct {
+	counters   map[string]*testCounter
+	histograms map[string]*testHistogram
+}
+
+func (r *testRegistry) Counter(desc metrics.Descriptor) metrics.Counter {
+	c := &testCounter{desc: desc, values: make(map[string]float64)}
+	r.counters[desc.Name] = c
+	return c
+}
+
+func (r *testRegistry) Histogram(desc metrics.Descriptor) metrics.Histogram {
+	h := &testHistogram{}
+	r.histograms[desc.Name] = h
+	return h
+}
+
+func TestRegistrySink(t *testing.T) {
+	reg := &testRegistry{counters: map[string]*testCounter{}, histograms: map[string]*testHistogram{}}
+	sink := metrics.NewRegistrySink(reg)
+
+	// assert that an instrument was created for every descriptor
+	assert.Equal(t, len(metrics.Descriptors()), len(reg.counters)+len(reg.histograms))
+
+	labels := metrics.Labels{
+		metrics.LabelStatus:    "200",
+		metrics.LabelService:   "posts",
+		metrics.LabelMethod:    http.MethodGet,
+		metrics.LabelOperation: "GetPost",
+	}
+	sink.AddCounter(metrics.MetricRequests, labels, 2)
+	sink.ObserveHistogram(metrics.MetricDuration, labels, 0.2)
+
+	// label values are passed in descriptor order
+	assert.Equal(t, float64(2), reg.counters[metrics.MetricRequests].values["posts|GetPost|GET|200|"])
+	assert.Equal(t, 1, reg.histograms[metrics.MetricDuration].observed)
+}
@@ -0,0 +1,344 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metric names recorded by the hooks
+const (
+	// MetricRequests counts completed requests.
+	MetricRequests = "gorequest_requests_total"
+	// MetricErrors counts requests that completed with an error, by error class.
+	MetricErrors = "gorequest_request_errors_total"
+	// MetricAttempts counts the attempts made to send requests, retries included.
+	MetricAttempts = "gorequest_request_attempts_total"
+	// MetricRetryDelay accumulates the time spent waiting between attempts.
+	MetricRetryDelay = "gorequest_retry_delay_seconds_total"
+	// MetricDuration observes the time from build to completion of requests.
+	MetricDuration = "gorequest_request_duration_seconds"
+	// MetricConnectionPhase observes the connection timing phases of each
+	// attempt, when connection tracing is enabled.
+	MetricConnectionPhase = "gorequest_attempt_phase_duration_seconds"
+	// MetricConnectionReused counts attempts sent over a reused connection.
+	MetricConnectionReused = "gorequest_attempt_connection_reused_total"
+	// MetricRetryBudgetExhausted counts requests denied a retry by an empty
+	// retry budget.
+	MetricRetryBudgetExhausted = "gorequest_retry_budget_exhausted_total"
+	// MetricCacheResults counts responses produced by the cache package, by
+	// cache status.
+	MetricCacheResults = "gorequest_cache_results_total"
+)
+
+// Label names attached to metrics
+const (
+	LabelService     = "service"
+	LabelOperation   = "operation"
+	LabelMethod      = "method"
+	LabelStatus      = "status"
+	LabelErrorClass  = "error_class"
+	LabelPhase       = "phase"
+	LabelCacheStatus = "cache_status"
+)
+
+// Kind is the type of instrument a metric is recorded with
+type Kind uint
+
+const (
+	KindCounter Kind = iota
+	KindHistogram
+)
+
+// DefaultBuckets are the histogram bucket upper bounds, in seconds, used for
+// latency metrics.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Descriptor describes a metric recorded by the hooks. Registries that need
+// instruments created up front use descriptors to create them.
+type Descriptor struct {
+	Name       string
+	Help       string
+	Kind       Kind
+	LabelNames []string
+	// Buckets are the upper bounds of histogram buckets
+	Buckets []float64
+}
+
+var baseLabels = []string{LabelService, LabelOperation, LabelMethod, LabelStatus}
+
+var descriptors = []Descriptor{
+	{Name: MetricRequests, Help: "Number of completed requests.", Kind: KindCounter, LabelNames: baseLabels},
+	{Name: MetricErrors, Help: "Number of requests completed with an error.", Kind: KindCounter, LabelNames: append(append([]string{}, baseLabels...), LabelErrorClass)},
+	{Name: MetricAttempts, Help: "Number of attempts made to send requests.", Kind: KindCounter, LabelNames: baseLabels},
+	{Name: MetricRetryDelay, Help: "Total time in seconds spent waiting between attempts.", Kind: KindCounter, LabelNames: baseLabels},
+	{Name: MetricDuration, Help: "Request duration in seconds.", Kind: KindHistogram, LabelNames: baseLabels, Buckets: DefaultBuckets},
+	{Name: MetricConnectionPhase, Help: "Attempt connection phase duration in seconds.", Kind: KindHistogram, LabelNames: append(append([]string{}, baseLabels...), LabelPhase), Buckets: DefaultBuckets},
+	{Name: MetricConnectionReused, Help: "Number of attempts sent over a reused connection.", Kind: KindCounter, LabelNames: baseLabels},
+	{Name: MetricRetryBudgetExhausted, Help: "Number of requests denied a retry by the retry budget.", Kind: KindCounter, LabelNames: baseLabels},
+	{Name: MetricCacheResults, Help: "Number of responses produced by the cache, by cache status.", Kind: KindCounter, LabelNames: append(append([]string{}, baseLabels...), LabelCacheStatus)},
+}
+
+// Descriptors returns the descriptors of all metrics recorded by the hooks.
+func Descriptors() []Descriptor {
+	out := make([]Descriptor, len(descriptors))
+	copy(out, descriptors)
+	return out
+}
+
+// Labels are the label names and values of a single observation.
+type Labels map[string]string
+
+// Values returns the label values ordered by names. Missing labels have an
+// empty value.
+func (l Labels) Values(names []string) []string {
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = l[name]
+	}
+	return values
+}
+
+func (l Labels) key() string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(l[name])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// Sink receives metric observations from the hooks.
+type Sink interface {
+	// AddCounter adds value to the counter name.
+	AddCounter(name string, labels Labels, value float64)
+	// ObserveHistogram records value in the histogram name.
+	ObserveHistogram(name string, labels Labels, value float64)
+}
+
+// Counter is a labelled counter instrument created by a Registry.
+type Counter interface {
+	Add(labelValues []string, value float64)
+}
+
+// Histogram is a labelled histogram instrument created by a Registry.
+type Histogram interface {
+	Observe(labelValues []string, value float64)
+}
+
+// Registry creates instruments from descriptors. It is shaped after metric
+// registries like Prometheus, where a CounterVec created from the descriptor
+// is wrapped as:
+//
+//	func (c promCounter) Add(values []string, v float64) {
+//		c.vec.WithLabelValues(values...).Add(v)
+//	}
+//
+// or an OpenTelemetry meter, where the label names and values are zipped into
+// attributes when recording.
+type Registry interface {
+	Counter(desc Descriptor) Counter
+	Histogram(desc Descriptor) Histogram
+}
+
+// NewRegistrySink creates an instrument in reg for every metric descriptor and
+// returns a Sink that records to them.
+func NewRegistrySink(reg Registry) Sink {
+	s := &registrySink{
+		counters:   make(map[string]registered[Counter]),
+		histograms: make(map[string]registered[Histogram]),
+	}
+	for _, desc := range descriptors {
+		switch desc.Kind {
+		case KindCounter:
+			s.counters[desc.Name] = registered[Counter]{desc: desc, instrument: reg.Counter(desc)}
+		case KindHistogram:
+			s.histograms[desc.Name] = registered[Histogram]{desc: desc, instrument: reg.Histogram(desc)}
+		}
+	}
+	return s
+}
+
+type registered[T any] struct {
+	desc       Descriptor
+	instrument T
+}
+
+type registrySink struct {
+	counters   map[string]registered[Counter]
+	histograms map[string]registered[Histogram]
+}
+
+func (s *registrySink) AddCounter(name string, labels Labels, value float64) {
+	if c, ok := s.counters[name]; ok {
+		c.instrument.Add(labels.Values(c.desc.LabelNames), value)
+	}
+}
+
+func (s *registrySink) ObserveHistogram(name string, labels Labels, value float64) {
+	if h, ok := s.histograms[name]; ok {
+		h.instrument.Observe(labels.Values(h.desc.LabelNames), value)
+	}
+}
+
+// NewInMemorySink returns a Sink that aggregates observations in memory.
+func NewInMemorySink() *InMemorySink {
+	return &InMemorySink{
+		counters:   make(map[string]map[string]*CounterValue),
+		histograms: make(map[string]map[string]*HistogramValue),
+	}
+}
+
+// InMemorySink aggregates observations in memory. It is intended for tests and
+// for exposing metrics without a metrics system.
+type InMemorySink struct {
+	mu         sync.Mutex
+	counters   map[string]map[string]*CounterValue
+	histograms map[string]map[string]*HistogramValue
+}
+
+// CounterValue is the current value of a labelled counter.
+type CounterValue struct {
+	Labels Labels
+	Value  float64
+}
+
+// HistogramValue is the current state of a labelled histogram.
+type HistogramValue struct {
+	Labels Labels
+	Count  uint64
+	Sum    float64
+	// Buckets holds the cumulative count of observations less than or equal
+	// to the bound at the same index in Bounds.
+	Buckets []uint64
+	Bounds  []float64
+}
+
+func (s *InMemorySink) AddCounter(name string, labels Labels, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	series, ok := s.counters[name]
+	if !ok {
+		series = make(map[string]*CounterValue)
+		s.counters[name] = series
+	}
+	key := labels.key()
+	c, ok := series[key]
+	if !ok {
+		c = &CounterValue{Labels: copyLabels(labels)}
+		series[key] = c
+	}
+	c.Value += value
+}
+
+func (s *InMemorySink) ObserveHistogram(name string, labels Labels, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	series, ok := s.histograms[name]
+	if !ok {
+		series = make(map[string]*HistogramValue)
+		s.histograms[name] = series
+	}
+	key := labels.key()
+	h, ok := series[key]
+	if !ok {
+		h = &HistogramValue{
+			Labels:  copyLabels(labels),
+			Bounds:  DefaultBuckets,
+			Buckets: make([]uint64, len(DefaultBuckets)),
+		}
+		series[key] = h
+	}
+	h.Count++
+	h.Sum += value
+	for i, bound := range h.Bounds {
+		if value <= bound {
+			h.Buckets[i]++
+		}
+	}
+}
+
+// Snapshot is a point in time copy of the values held by an InMemorySink.
+type Snapshot struct {
+	Counters   map[string][]CounterValue
+	Histograms map[string][]HistogramValue
+}
+
+// Snapshot returns a copy of all the values currently held by the sink.
+func (s *InMemorySink) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := Snapshot{
+		Counters:   make(map[string][]CounterValue, len(s.counters)),
+		Histograms: make(map[string][]HistogramValue, len(s.histograms)),
+	}
+	for name, series := range s.counters {
+		for _, c := range series {
+			snap.Counters[name] = append(snap.Counters[name], CounterValue{Labels: copyLabels(c.Labels), Value: c.Value})
+		}
+	}
+	for name, series := range s.histograms {
+		for _, h := range series {
+			snap.Histograms[name] = append(snap.Histograms[name], HistogramValue{
+				Labels:  copyLabels(h.Labels),
+				Count:   h.Count,
+				Sum:     h.Sum,
+				Buckets: append([]uint64(nil), h.Buckets...),
+				Bounds:  h.Bounds,
+			})
+		}
+	}
+	return snap
+}
+
+// Counter returns the sum of the counter name over all series matching labels.
+// A label absent from labels matches any value.
+func (s Snapshot) Counter(name string, labels Labels) float64 {
+	var total float64
+	for _, c := range s.Counters[name] {
+		if matches(c.Labels, labels) {
+			total += c.Value
+		}
+	}
+	return total
+}
+
+// Histogram returns the number and sum of observations of the histogram name
+// over all series matching labels.
+func (s Snapshot) Histogram(name string, labels Labels) (count uint64, sum float64) {
+	for _, h := range s.Histograms[name] {
+		if matches(h.Labels, labels) {
+			count += h.Count
+			sum += h.Sum
+		}
+	}
+	return count, sum
+}
+
+func matches(labels, filter Labels) bool {
+	for name, value := range filter {
+		if labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func copyLabels(l Labels) Labels {
+	out := make(Labels, len(l))
+	for k, v := range l {
+		out[k] = v
+	}
+	return out
+}
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"reflect"
 	"strings"
 	"time"
 )
@@ -48,9 +50,27 @@ type (
 		Response  *http.Response
 
 		AttemptTime time.Time
+		// Attempts holds the outcome of every attempt made by Send
+		Attempts []AttemptRecord
 
 		// a boolean to indicate with request is build
 		built bool
+		// timer records the connection timing of the current attempt, once a
+		// connection tracing hook asked for it
+		timer *TimingRecorder
+		// cacheStatus is the cache status of the current response
+		cacheStatus string
+		// stopRetry is set by the retry and reauth hooks to stop the request
+		// from being sent again, and stopErr is the error they stopped it with
+		stopRetry bool
+		stopErr   error
+		// reauth is set when the current attempt is to be sent again after
+		// re-authentication
+		reauth bool
+		// fallingBack is set while the fallback hooks run, and recovery is the
+		// response a fallback hook recovered the request with
+		fallingBack bool
+		recovery    *http.Response
 	}
 
 	// An Option is a functional option that can augment or modify a request when
@@ -117,6 +137,15 @@ func (r *Request) ApplyOptions(opts ...Option) {
 // If no method is provided, the default method will be POST.
 // If no retryer is provided, a no-op retryer will be used.
 func New(cfg Config, operation Operation, hooks Hooks, retryer Retryer, params, data any) *Request {
+	var errs []error
+	if cfg.TLS != nil {
+		client, err := tlsClient(cfg.HTTPClient, cfg.TLS)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		cfg.HTTPClient = client
+	}
+
 	// set a default http client if not provided
 	if cfg.HTTPClient == nil {
 		cfg.HTTPClient = http.DefaultClient
@@ -134,10 +163,31 @@ func New(cfg Config, operation Operation, hooks Hooks, retryer Retryer, params,
 	httpReq, _ := http.NewRequest(method, "", nil)
 
 	var err error
-	httpReq.URL, err = url.Parse(cfg.Endpoint)
+	httpReq.URL, err = operationURL(cfg.Endpoint, operation)
 	if err != nil {
-		httpReq.URL = &url.URL{}
-		err = errors.Join(errors.New("invalid endpoint url"), err)
+		errs = append(errs, errors.New("invalid endpoint url"), err)
+	}
+
+	return &Request{
+		Config:      cfg,
+		Request:     httpReq,
+		Operation:   operation,
+		Hooks:       hooks.Copy(),
+		Params:      params,
+		Data:        data,
+		Error:       errors.Join(errs...),
+		Retryer:     retryer,
+		RetryConfig: RetryConfig{}, // noOp retry config
+	}
+}
+
+// operationURL parses endpoint and appends the path and query of the
+// operation. The url is empty apart from the operation path when the endpoint
+// can not be parsed.
+func operationURL(endpoint string, operation Operation) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		u = &url.URL{}
 	}
 
 	// append path to request url
@@ -149,24 +199,58 @@ func New(cfg Config, operation Operation, hooks Hooks, retryer Retryer, params,
 			opHTTPPath = opHTTPPath[:idx]
 		}
 
-		if strings.HasSuffix(httpReq.URL.Path, "/") && strings.HasPrefix(opHTTPPath, "/") {
+		if strings.HasSuffix(u.Path, "/") && strings.HasPrefix(opHTTPPath, "/") {
 			opHTTPPath = opHTTPPath[1:]
 		}
-		httpReq.URL.Path += opHTTPPath
-		httpReq.URL.RawQuery = opQueryString
+		u.Path += opHTTPPath
+		u.RawQuery = opQueryString
 	}
+	return u, err
+}
 
-	return &Request{
-		Config:      cfg,
-		Request:     httpReq,
-		Operation:   operation,
-		Hooks:       hooks.Copy(),
-		Params:      params,
-		Data:        data,
-		Error:       err,
-		Retryer:     retryer,
-		RetryConfig: RetryConfig{}, // noOp retry config
+// SetEndpoint sets the endpoint of the request, and moves the url of the http
+// request to the endpoint. The scheme and host of the url are replaced by
+// those of endpoint, and the path of the previous endpoint by its path, so the
+// operation path and the query, including changes of earlier build hooks, are
+// kept.
+func (r *Request) SetEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint url: %w", err)
 	}
+
+	// the url of the http request was built from the previous endpoint, which
+	// is parsed the same way, even when it was not a valid url
+	prev, err := url.Parse(r.Config.Endpoint)
+	if err != nil {
+		prev = &url.URL{}
+	}
+
+	next := *r.Request.URL
+	next.Scheme, next.Opaque, next.User, next.Host = u.Scheme, u.Opaque, u.User, u.Host
+
+	rest := strings.TrimPrefix(next.EscapedPath(), prev.EscapedPath())
+	escaped := u.EscapedPath()
+	if strings.HasSuffix(escaped, "/") && strings.HasPrefix(rest, "/") {
+		rest = rest[1:]
+	}
+	escaped += rest
+	if next.Path, err = url.PathUnescape(escaped); err != nil {
+		return fmt.Errorf("invalid endpoint url: %w", err)
+	}
+	next.RawPath = escaped
+
+	if u.RawQuery != "" {
+		if next.RawQuery != "" {
+			next.RawQuery = u.RawQuery + "&" + next.RawQuery
+		} else {
+			next.RawQuery = u.RawQuery
+		}
+	}
+
+	r.Config.Endpoint = endpoint
+	r.Request.URL = &next
+	return nil
 }
 
 func debugLogReqError(r *Request, stage string, err error) {
@@ -195,12 +279,14 @@ func (r *Request) Build() error {
 	// run validate hooks
 	r.Hooks.Validate.Run(r)
 	if r.Error != nil {
+		r.Error = withPhase(PhaseValidate, r.Error)
 		debugLogReqError(r, "Validate", r.Error)
 		return r.Error
 	}
 	// run build hooks
 	r.Hooks.Build.Run(r)
 	if r.Error != nil {
+		r.Error = withPhase(PhaseBuild, r.Error)
 		debugLogReqError(r, "Build", r.Error)
 		return r.Error
 	}
@@ -209,8 +295,18 @@ func (r *Request) Build() error {
 	return nil
 }
 
-func (r *Request) Send() error {
+// Send builds and sends the request, retrying failed attempts as allowed by
+// the Retryer. When the attempts failed, the Fallback hooks may answer the
+// request with another response through Recover. The Complete hooks run once
+// the request is done, whatever the outcome, and do not change the error Send
+// returns.
+func (r *Request) Send() (err error) {
 	defer func() {
+		if r.Error != nil && r.built {
+			r.fallback()
+		}
+		err = r.Error
+
 		// Ensure a non-nil HTTPResponse parameter is set to ensure hooks
 		// checking for HTTPResponse values, don't fail.
 		if r.Response == nil {
@@ -225,48 +321,186 @@ func (r *Request) Send() error {
 	}()
 
 	// build the request
-	err := r.Build()
+	err = r.Build()
 	if err != nil {
 		return r.Error
 	}
 
 	r.AttemptTime = time.Now()
+	r.Attempts = r.Attempts[:0]
 	for {
 		r.Error = nil
+		r.reauth = false
 
-		if err = r.sendRequest(); err == nil {
+		start := time.Now()
+		if n := len(r.Attempts); n > 0 {
+			r.Attempts[n-1].Delay = start.Sub(r.Attempts[n-1].End)
+		}
+
+		err = r.sendRequest()
+		r.recordAttempt(start)
+		if err == nil {
 			// return immediately to break loop if we encounter no error
 			return nil
 		}
 
+		// an attempt rejected for its credentials is sent again once the
+		// reauth hooks renewed them, without using up a retry or waiting
+		if r.reauth && r.reauthAllowed() {
+			r.RetryConfig.ReauthCount++
+			if r.runRetryHooks(r.Hooks.Reauth) {
+				r.Error = r.attemptsError()
+				return r.Error
+			}
+			if err := r.prepareRetry(); err != nil {
+				r.StopRetry(err)
+				r.Error = r.attemptsError()
+				return r.Error
+			}
+			continue
+		}
+
 		// if an error occurred, return if Request is not retryable
 		if r.Error != nil && !r.Retryer.Retryable(r) {
+			r.Error = r.attemptsError()
 			return r.Error
 		}
 
-		// run hooks to retry the request
-		r.Hooks.Retry.Run(r)
-		if r.Error != nil {
+		// run hooks to retry the request. The hooks can inspect the error of the
+		// failed attempt, and stop the retry with StopRetry or by replacing the
+		// error.
+		if r.runRetryHooks(r.Hooks.Retry) {
+			r.Error = r.attemptsError()
 			return r.Error
 		}
 
 		if err := r.prepareRetry(); err != nil {
-			r.Error = err
-			return err
+			r.StopRetry(err)
+			r.Error = r.attemptsError()
+			return r.Error
 		}
 	}
 }
 
+// Reauthenticate marks the current attempt to be sent again after the Reauth
+// hooks ran, when its response shows the credentials were rejected. Call it
+// from an unmarshal hook that sets the error of the attempt. The attempt is
+// sent again without consulting the Retryer, and without counting as a retry,
+// up to RetryConfig.MaxReauth times per request.
+func (r *Request) Reauthenticate() {
+	r.reauth = true
+}
+
+// reauthAllowed reports whether the limit of re-authentications allows
+// another one
+func (r *Request) reauthAllowed() bool {
+	limit := r.RetryConfig.MaxReauth
+	if limit == 0 {
+		limit = DefaultMaxReauth
+	}
+	return r.RetryConfig.ReauthCount < limit
+}
+
+// runRetryHooks runs the retry or reauth hooks of a failed attempt and reports
+// whether they stopped the request. Besides calling StopRetry, a hook stops the
+// request by setting r.Error to another error, as hooks did before StopRetry.
+func (r *Request) runRetryHooks(hooks HookList) bool {
+	attemptErr := r.Error
+	r.stopRetry, r.stopErr = false, nil
+	hooks.Run(r)
+	if !r.stopRetry && r.Error != nil && !sameError(attemptErr, r.Error) {
+		r.StopRetry(r.Error)
+	}
+	return r.stopRetry
+}
+
+// sameError reports whether err is the original error. Errors holding values
+// that can not be compared are compared deeply.
+func sameError(orig, err error) bool {
+	if orig == nil {
+		return false
+	}
+	a, b := reflect.ValueOf(orig), reflect.ValueOf(err)
+	if a.Type() != b.Type() {
+		return false
+	}
+	if a.Comparable() && b.Comparable() {
+		return a.Equal(b)
+	}
+	return reflect.DeepEqual(orig, err)
+}
+
+// StopRetry stops the request from being sent again, with err as the error
+// Send returns. A nil err keeps the error of the failed attempt. Retry and
+// Reauth hooks call it to end the request, for example when its context is
+// canceled while waiting for the retry delay. Setting r.Error to another error
+// in a retry or reauth hook stops the request too.
+func (r *Request) StopRetry(err error) {
+	if err != nil {
+		r.Error = err
+	}
+	r.stopRetry, r.stopErr = true, err
+}
+
+// fallback runs the Fallback hooks on a failed request. The hooks only change
+// the outcome of the request through Recover.
+func (r *Request) fallback() {
+	failedResponse, failedErr := r.Response, r.Error
+	r.fallingBack, r.recovery = true, nil
+	r.Hooks.Fallback.Run(r)
+	r.fallingBack = false
+
+	if r.recovery != nil {
+		r.Response, r.Error = r.recovery, nil
+		return
+	}
+	r.Response, r.Error = failedResponse, failedErr
+}
+
+// Recover answers a failed request with resp, such as a stale cached
+// response, from a Fallback hook. The Unmarshal hooks run on resp, and the
+// request keeps its error if they fail. Recover reports whether the request
+// was recovered; it does nothing outside the Fallback hooks or once the
+// request is recovered.
+func (r *Request) Recover(resp *http.Response) bool {
+	if !r.fallingBack || r.recovery != nil {
+		return false
+	}
+
+	failedResponse, failedErr := r.Response, r.Error
+	r.Response, r.Error = resp, nil
+	r.Hooks.Unmarshal.Run(r)
+	if r.Error != nil {
+		debugLogReqError(r, "Recover", r.Error)
+		r.Response, r.Error = failedResponse, failedErr
+		return false
+	}
+
+	if failedResponse != nil && failedResponse.Body != nil {
+		_ = failedResponse.Body.Close()
+	}
+	r.recovery = r.Response
+	return true
+}
+
 func (r *Request) prepareRetry() error {
 	if r.Config.LogLevel.Equals(LogDebugWithRequestRetries) && r.Config.Logger != nil {
-		r.Config.Logger.Log(fmt.Sprintf("DEBUG: Retrying Request %s, attempt %d",
-			r.Operation.Name, r.RetryConfig.RetryCount))
+		r.Config.Logger.Log(fmt.Sprintf("DEBUG: Retrying Request %s, attempt %d, previous attempt %s",
+			r.Operation.Name, r.RetryConfig.RetryCount, r.Timing()))
 	}
 
 	// The previous http.Request will have a reference to Request.Body,
 	// and the HTTP Client's Transport may still be reading from
-	// the request's body even though the Client's Do returned.
-	r.Request = copyHTTPRequest(r.Request, nil)
+	// the request's body even though the Client's Do returned. A new body
+	// is taken from GetBody, when the body is replayable.
+	var body io.ReadCloser
+	if r.Request.GetBody != nil {
+		var err error
+		if body, err = r.Request.GetBody(); err != nil {
+			return err
+		}
+	}
+	r.Request = copyHTTPRequest(r.Request, body)
 
 	// Closing the response body to ensure that no response body is leaked
 	// between retry attempts.
@@ -277,10 +511,77 @@ func (r *Request) prepareRetry() error {
 	return nil
 }
 
+// Timing returns the connection timing of the current attempt. It is only
+// recorded when a connection tracing hook is registered.
+func (r *Request) Timing() AttemptTiming {
+	if r.timer == nil {
+		return AttemptTiming{}
+	}
+	return r.timer.Timing()
+}
+
+// TimingRecorder returns the recorder of the connection timing of the
+// request's attempts, for hooks tracing the connection. The recorder is reset
+// at the start of each attempt.
+func (r *Request) TimingRecorder() *TimingRecorder {
+	if r.timer == nil {
+		r.timer = &TimingRecorder{}
+	}
+	return r.timer
+}
+
+// CacheStatus returns how a cache produced the response of the request, such
+// as "HIT" or "MISS", and an empty string if no cache handled it.
+func (r *Request) CacheStatus() string {
+	return r.cacheStatus
+}
+
+// SetCacheStatus sets the cache status of the response. It is called by the
+// hooks of a cache, and reset at the start of each attempt.
+func (r *Request) SetCacheStatus(status string) {
+	r.cacheStatus = status
+}
+
+// Detach returns a request for sending the http request of r apart from r,
+// such as a call shared with other requests or a refresh in the background.
+// It has the config and operation of r and a clone of its http request. Its
+// context keeps the values of the context of r, without its cancellation or
+// connection trace. The detached request has no hooks.
+func (r *Request) Detach() *Request {
+	ctx := untraced{context.WithoutCancel(r.Context())}
+	detached := &Request{
+		Config:    r.Config,
+		Operation: r.Operation,
+		Request:   r.Request.Clone(ctx),
+	}
+	detached.WithContext(ctx)
+	return detached
+}
+
+// untraced hides the httptrace.ClientTrace of its context, which records into
+// the request the context was taken from
+type untraced struct {
+	context.Context
+}
+
+func (ctx untraced) Value(key any) any {
+	v := ctx.Context.Value(key)
+	if _, ok := v.(*httptrace.ClientTrace); ok {
+		return nil
+	}
+	return v
+}
+
 func (r *Request) sendRequest() error {
+	if r.timer != nil {
+		r.timer.reset()
+	}
+	r.cacheStatus = ""
+
 	// run hooks that process sending the request
 	r.Hooks.Send.Run(r)
 	if r.Error != nil {
+		r.Error = withPhase(PhaseSend, r.Error)
 		debugLogReqError(r, "Send", r.Error)
 		return r.Error
 	}
@@ -288,6 +589,7 @@ func (r *Request) sendRequest() error {
 	// run any hooks that unmarshal/validate the response
 	r.Hooks.Unmarshal.Run(r)
 	if r.Error != nil {
+		r.Error = withPhase(PhaseUnmarshal, r.Error)
 		debugLogReqError(r, "Unmarshal", r.Error)
 		return r.Error
 	}
@@ -1,8 +1,12 @@
 package gorequest
 
 import (
+	"context"
 	"errors"
+	"io"
 	"net/http"
+	"net/http/httptrace"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,6 +22,16 @@ func (e FakeTemporaryError) Temporary() bool {
 	return e.temporary
 }
 
+type wrappedError struct {
+	err error
+}
+
+func (e wrappedError) Error() string { return e.err.Error() }
+
+type multiError []error
+
+func (e multiError) Error() string { return errors.Join(e...).Error() }
+
 type MockHooks struct {
 	str string
 }
@@ -250,4 +264,301 @@ func TestRequest_Send(t *testing.T) {
 		// confirm that request was retried
 		assert.Equal(t, cfg.MaxRetries, req.RetryConfig.RetryCount)
 	})
+	t.Run("test that the request is sent again after the retry hooks run", func(t *testing.T) {
+		hooks := Hooks{}
+
+		sent := 0
+		hooks.Send.PushBack(func(r *Request) {
+			sent++
+			r.Error = FakeTemporaryError{error: errors.New("fake error"), temporary: true}
+		})
+		hooks.Retry.PushBack(func(r *Request) {
+			r.RetryConfig.RetryCount++
+		})
+
+		req := New(Config{}, Operation{}, hooks, retryer{}, nil, nil)
+		req.WithRetryConfig(RetryConfig{MaxRetries: 2, InitialDelay: time.Millisecond})
+
+		err := req.Send()
+		assert.NotNil(t, err)
+		assert.Equal(t, 3, sent)
+	})
+
+	t.Run("test that a replayable body is sent again on retries", func(t *testing.T) {
+		hooks := Hooks{}
+		hooks.Build.PushBack(func(r *Request) {
+			r.Request.Body = io.NopCloser(strings.NewReader("payload"))
+			r.Request.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader("payload")), nil
+			}
+		})
+
+		var bodies []string
+		hooks.Send.PushBack(func(r *Request) {
+			b, _ := io.ReadAll(r.Request.Body)
+			bodies = append(bodies, string(b))
+			r.Error = FakeTemporaryError{error: errors.New("fake error"), temporary: true}
+		})
+		hooks.Retry.PushBack(func(r *Request) {
+			r.RetryConfig.RetryCount++
+		})
+
+		req := New(Config{}, Operation{}, hooks, retryer{}, nil, nil)
+		req.WithRetryConfig(RetryConfig{MaxRetries: 2, InitialDelay: time.Millisecond})
+
+		assert.Error(t, req.Send())
+		assert.Equal(t, []string{"payload", "payload", "payload"}, bodies)
+	})
+
+	t.Run("test that a retry hook error stops the request", func(t *testing.T) {
+		hooks := Hooks{}
+
+		sent := 0
+		hooks.Send.PushBack(func(r *Request) {
+			sent++
+			r.Error = FakeTemporaryError{error: errors.New("fake error"), temporary: true}
+		})
+		hookErr := errors.New("retry hook error")
+		hooks.Retry.PushBack(func(r *Request) {
+			r.StopRetry(hookErr)
+		})
+
+		req := New(Config{}, Operation{}, hooks, retryer{}, nil, nil)
+		req.WithRetryConfig(RetryConfig{MaxRetries: 2, InitialDelay: time.Millisecond})
+
+		err := req.Send()
+		assert.Equal(t, hookErr, err)
+		assert.Equal(t, 1, sent)
+	})
+	t.Run("test that a retry hook setting the error stops the request", func(t *testing.T) {
+		hooks := Hooks{}
+
+		sent := 0
+		hooks.Send.PushBack(func(r *Request) {
+			sent++
+			r.Error = FakeTemporaryError{error: multiError{errors.New("fake error")}, temporary: true}
+		})
+		// the errors are of a comparable type holding values that are not
+		// comparable
+		hooks.Retry.PushBack(func(r *Request) {
+			r.RetryConfig.RetryCount++
+			r.Error = FakeTemporaryError{error: wrappedError{err: multiError{errors.New("retry hook error")}}, temporary: true}
+		})
+
+		req := New(Config{}, Operation{}, hooks, retryer{}, nil, nil)
+		req.WithRetryConfig(RetryConfig{MaxRetries: 2, InitialDelay: time.Millisecond})
+
+		err := req.Send()
+		assert.ErrorContains(t, err, "retry hook error")
+		assert.Equal(t, 1, sent)
+	})
+	t.Run("test that a retry hook keeping an error that can not be compared retries the request", func(t *testing.T) {
+		hooks := Hooks{}
+
+		sent := 0
+		hooks.Send.PushBack(func(r *Request) {
+			sent++
+			r.Error = FakeTemporaryError{error: multiError{errors.New("fake error")}, temporary: true}
+		})
+		hooks.Retry.PushBack(func(r *Request) {
+			r.RetryConfig.RetryCount++
+		})
+
+		req := New(Config{}, Operation{}, hooks, retryer{}, nil, nil)
+		req.WithRetryConfig(RetryConfig{MaxRetries: 2, InitialDelay: time.Millisecond})
+
+		assert.Error(t, req.Send())
+		assert.Equal(t, 3, sent)
+	})
+
+	t.Run("test that a rejected attempt is sent again after re-authentication", func(t *testing.T) {
+		hooks := Hooks{}
+
+		var tokens []string
+		hooks.Build.PushBack(func(r *Request) {
+			r.Request.Header.Set("Authorization", "token-1")
+		})
+		hooks.Send.PushBack(func(r *Request) {
+			tokens = append(tokens, r.Request.Header.Get("Authorization"))
+		})
+		hooks.Unmarshal.PushBack(func(r *Request) {
+			if r.Request.Header.Get("Authorization") != "token-2" {
+				r.Error = errors.New("unauthorized")
+				r.Reauthenticate()
+			}
+		})
+		hooks.Reauth.PushBack(func(r *Request) {
+			r.Request.Header.Set("Authorization", "token-2")
+		})
+		retried := 0
+		hooks.Retry.PushBack(func(r *Request) {
+			retried++
+		})
+
+		// a retryer that would wait for an hour
+		req := New(Config{}, Operation{}, hooks, retryer{}, nil, nil)
+		req.WithRetryConfig(RetryConfig{MaxRetries: 1, InitialDelay: time.Hour})
+
+		assert.NoError(t, req.Send())
+		assert.Equal(t, []string{"token-1", "token-2"}, tokens)
+		assert.Equal(t, 0, retried)
+		assert.Equal(t, 0, req.RetryConfig.RetryCount)
+		assert.Equal(t, 1, req.RetryConfig.ReauthCount)
+		assert.Len(t, req.Attempts, 2)
+	})
+
+	t.Run("test that re-authentication is limited", func(t *testing.T) {
+		tcs := map[string]struct {
+			maxReauth int
+			sent      int
+		}{
+			"default":  {maxReauth: 0, sent: 1 + DefaultMaxReauth},
+			"custom":   {maxReauth: 4, sent: 5},
+			"disabled": {maxReauth: -1, sent: 1},
+		}
+
+		for name, tc := range tcs {
+			t.Run(name, func(t *testing.T) {
+				hooks := Hooks{}
+				sent := 0
+				errUnauthorized := errors.New("unauthorized")
+				hooks.Send.PushBack(func(r *Request) {
+					sent++
+					r.Error = errUnauthorized
+					r.Reauthenticate()
+				})
+
+				req := New(Config{}, Operation{}, hooks, nil, nil, nil)
+				req.WithRetryConfig(RetryConfig{MaxReauth: tc.maxReauth})
+
+				assert.ErrorIs(t, req.Send(), errUnauthorized)
+				assert.Equal(t, tc.sent, sent)
+			})
+		}
+	})
+
+	t.Run("test that a reauth hook error stops the request", func(t *testing.T) {
+		hooks := Hooks{}
+		sent := 0
+		hooks.Send.PushBack(func(r *Request) {
+			sent++
+			r.Error = errors.New("unauthorized")
+			r.Reauthenticate()
+		})
+		hookErr := errors.New("token endpoint down")
+		hooks.Reauth.PushBack(func(r *Request) {
+			r.StopRetry(hookErr)
+		})
+
+		req := New(Config{}, Operation{}, hooks, nil, nil, nil)
+		assert.Equal(t, hookErr, req.Send())
+		assert.Equal(t, 1, sent)
+	})
+
+	t.Run("test that a complete hook does not change the error returned", func(t *testing.T) {
+		sendErr := errors.New("fake error")
+		hooks := Hooks{}
+		hooks.Send.PushBack(func(r *Request) {
+			r.Error = sendErr
+		})
+		hooks.Complete.PushBack(func(r *Request) {
+			r.Error = nil
+		})
+
+		req := New(Config{}, Operation{}, hooks, retryer{}, nil, nil)
+		assert.ErrorIs(t, req.Send(), sendErr)
+	})
+
+	t.Run("test that a fallback hook can recover the request with a response", func(t *testing.T) {
+		sendErr := errors.New("fake error")
+		tcs := map[string]struct {
+			unmarshalErr error
+			expectedErr  error
+			expectedCode int
+		}{
+			"recovered": {
+				expectedCode: http.StatusOK,
+			},
+			"response rejected by the unmarshal hooks": {
+				unmarshalErr: errors.New("unmarshal error"),
+				expectedErr:  sendErr,
+				expectedCode: http.StatusServiceUnavailable,
+			},
+		}
+
+		for name, tc := range tcs {
+			t.Run(name, func(t *testing.T) {
+				var unmarshaled []int
+				hooks := Hooks{}
+				hooks.Send.PushBack(func(r *Request) {
+					r.Response = &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}
+					r.Error = sendErr
+				})
+				hooks.Unmarshal.PushBack(func(r *Request) {
+					unmarshaled = append(unmarshaled, r.Response.StatusCode)
+					if r.Response.StatusCode == http.StatusOK {
+						r.Error = tc.unmarshalErr
+					}
+				})
+				hooks.Fallback.PushBack(func(r *Request) {
+					r.Recover(&http.Response{StatusCode: http.StatusOK, Body: http.NoBody})
+				})
+				var completed error
+				hooks.Complete.PushBack(func(r *Request) {
+					completed = r.Error
+				})
+
+				req := New(Config{}, Operation{}, hooks, retryer{}, nil, nil)
+				err := req.Send()
+				if tc.expectedErr == nil {
+					assert.NoError(t, err)
+				} else {
+					assert.ErrorIs(t, err, tc.expectedErr)
+				}
+				assert.Equal(t, err, completed)
+				assert.Equal(t, tc.expectedCode, req.Response.StatusCode)
+				assert.Equal(t, []int{http.StatusOK}, unmarshaled)
+			})
+		}
+	})
+
+	t.Run("test that a fallback hook only changes the outcome with Recover", func(t *testing.T) {
+		sendErr := errors.New("fake error")
+		hooks := Hooks{}
+		hooks.Send.PushBack(func(r *Request) {
+			r.Error = sendErr
+		})
+		hooks.Fallback.PushBack(func(r *Request) {
+			r.Error = nil
+			r.Response = &http.Response{StatusCode: http.StatusOK}
+		})
+
+		req := New(Config{}, Operation{}, hooks, retryer{}, nil, nil)
+		assert.ErrorIs(t, req.Send(), sendErr)
+		assert.NotEqual(t, http.StatusOK, req.Response.StatusCode)
+		assert.False(t, req.Recover(&http.Response{StatusCode: http.StatusOK}))
+	})
+}
+
+func TestRequest_Detach(t *testing.T) {
+	type key struct{}
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{})
+
+	req := New(Config{ServiceName: "posts"}, Operation{Name: "GetPost", Method: http.MethodGet}, Hooks{}, nil, nil, nil)
+	req.WithContext(ctx)
+	req.Request.Header.Set("Accept", "application/json")
+
+	detached := req.Detach()
+	cancel()
+	req.Request.Header.Set("Accept", "text/plain")
+
+	assert.Equal(t, req.Config, detached.Config)
+	assert.Equal(t, req.Operation, detached.Operation)
+	assert.Equal(t, "application/json", detached.Request.Header.Get("Accept"))
+	assert.NoError(t, detached.Context().Err())
+	assert.Equal(t, "value", detached.Context().Value(key{}))
+	assert.Nil(t, httptrace.ContextClientTrace(detached.Context()))
+	assert.True(t, detached.Hooks.IsEmpty())
 }
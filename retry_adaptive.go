@@ -0,0 +1,322 @@
+package gorequest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AdaptiveRecovery is how an adaptive retryer recovers its send rate after a
+// throttle response.
+type AdaptiveRecovery uint
+
+const (
+	// RecoveryCubic grows the rate along a cubic curve centered on the rate
+	// at the last throttle, as in TCP CUBIC.
+	RecoveryCubic AdaptiveRecovery = iota
+	// RecoveryAdditive grows the rate by a fixed amount on each success.
+	RecoveryAdditive
+)
+
+// AdaptiveConfig configures the client side rate limiting of an AdaptiveRetryer.
+type AdaptiveConfig struct {
+	Recovery AdaptiveRecovery
+	// Beta is the multiplicative decrease applied to the send rate on
+	// a throttle response. Defaults to 0.7.
+	Beta float64
+	// ScaleConstant is the cubic recovery scale. Defaults to 0.4.
+	ScaleConstant float64
+	// AdditiveIncrease is the rate, in requests per second, added on each
+	// success with additive recovery. Defaults to 1.
+	AdditiveIncrease float64
+	// MinRate is the lowest send rate in requests per second. Defaults to 0.5.
+	MinRate float64
+	// ThrottleStatusCodes are the response status codes treated as throttling.
+	// Defaults to 429 and 503.
+	ThrottleStatusCodes []int
+	// ThrottleErrorCodes are the API error codes treated as throttling. The
+	// RetryConfig.RetryErrorCodes are not, unless they are listed here too.
+	ThrottleErrorCodes []string
+}
+
+func (c AdaptiveConfig) withDefaults() AdaptiveConfig {
+	if c.Beta <= 0 || c.Beta >= 1 {
+		c.Beta = 0.7
+	}
+	if c.ScaleConstant <= 0 {
+		c.ScaleConstant = 0.4
+	}
+	if c.AdditiveIncrease <= 0 {
+		c.AdditiveIncrease = 1
+	}
+	if c.MinRate <= 0 {
+		c.MinRate = 0.5
+	}
+	if c.ThrottleStatusCodes == nil {
+		c.ThrottleStatusCodes = []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}
+	}
+	return c
+}
+
+// NewAdaptiveRetryer returns an AdaptiveRetryer that retries the requests
+// retryer allows and adapts the send rate to throttle responses.
+func NewAdaptiveRetryer(retryer Retryer, cfg AdaptiveConfig) *AdaptiveRetryer {
+	if retryer == nil {
+		retryer = DefaultRetryer
+	}
+	return &AdaptiveRetryer{
+		retryer:  retryer,
+		cfg:      cfg.withDefaults(),
+		limiters: make(map[string]*adaptiveLimiter),
+		now:      time.Now,
+	}
+}
+
+// AdaptiveRetryer is a Retryer with client side rate limiting. The send rate is
+// cut when a service throttles requests and recovers as requests succeed. The
+// rate is only limited after the first throttle response.
+//
+// The rate limiting state is shared by all requests with the same
+// Config.ServiceName. Register the hooks with Apply.
+type AdaptiveRetryer struct {
+	retryer Retryer
+	cfg     AdaptiveConfig
+
+	mu       sync.Mutex
+	limiters map[string]*adaptiveLimiter
+	now      func() time.Time
+}
+
+// Delay returns the delay of the wrapped retryer
+func (a *AdaptiveRetryer) Delay(r *Request) time.Duration {
+	return a.retryer.Delay(r)
+}
+
+// Retryable returns the decision of the wrapped retryer
+func (a *AdaptiveRetryer) Retryable(r *Request) bool {
+	return a.retryer.Retryable(r)
+}
+
+// Apply registers the rate limiting hooks. The gate runs before all other send
+// hooks. The response is observed after the unmarshal hooks, so that the error
+// codes they decode are seen, or after the send hooks when sending fails and
+// the unmarshal hooks are skipped. Call Apply after registering the unmarshal
+// hooks.
+func (a *AdaptiveRetryer) Apply(hooks *Hooks) {
+	hooks.Send.PushFrontHook(a.Gate())
+	hooks.Send.PushBackHook(a.observeSendError())
+	hooks.Unmarshal.PushBackHook(a.Observe())
+}
+
+// Gate returns a send hook that waits for a send token of the service before
+// each attempt.
+func (a *AdaptiveRetryer) Gate() Hook {
+	return Hook{Name: "gorequest.AdaptiveGate", Fn: func(r *Request) {
+		if err := a.limiter(r.Config.ServiceName).acquire(r.Context(), a.now); err != nil {
+			r.Error = err
+		}
+	}}
+}
+
+// Observe returns an unmarshal hook that updates the send rate of the service
+// from the attempt's response and error.
+func (a *AdaptiveRetryer) Observe() Hook {
+	return Hook{Name: "gorequest.AdaptiveObserve", Fn: a.observe}
+}
+
+// observeSendError returns a send hook observing the attempts that failed to
+// send, which skip the unmarshal hooks.
+func (a *AdaptiveRetryer) observeSendError() Hook {
+	return Hook{Name: "gorequest.AdaptiveObserveSendError", Fn: func(r *Request) {
+		if r.Error != nil {
+			a.observe(r)
+		}
+	}}
+}
+
+func (a *AdaptiveRetryer) observe(r *Request) {
+	// attempts that never reached the service say nothing about its rate
+	if errors.Is(r.Error, context.Canceled) || errors.Is(r.Error, context.DeadlineExceeded) {
+		return
+	}
+
+	limiter := a.limiter(r.Config.ServiceName)
+	if a.throttled(r) {
+		limiter.throttle(a.now())
+		return
+	}
+	limiter.success(a.now())
+}
+
+// SendRate returns the current send rate, in requests per second, of the
+// service and false if the rate is not limited.
+func (a *AdaptiveRetryer) SendRate(service string) (float64, bool) {
+	l := a.limiter(service)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fillRate, l.enabled
+}
+
+func (a *AdaptiveRetryer) throttled(r *Request) bool {
+	if r.Response != nil && slices.Contains(a.cfg.ThrottleStatusCodes, r.Response.StatusCode) {
+		return true
+	}
+	// only the throttle error codes count, as the RetryErrorCodes of the
+	// request are not all throttling
+	var coder ErrorCoder
+	return r.Error != nil && errors.As(r.Error, &coder) && slices.Contains(a.cfg.ThrottleErrorCodes, coder.ErrorCode())
+}
+
+func (a *AdaptiveRetryer) limiter(service string) *adaptiveLimiter {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	l, ok := a.limiters[service]
+	if !ok {
+		l = &adaptiveLimiter{cfg: a.cfg}
+		a.limiters[service] = l
+	}
+	return l
+}
+
+// measureInterval is the width of the buckets the send rate is measured in
+const measureInterval = 500 * time.Millisecond
+
+// adaptiveLimiter is a token bucket whose fill rate follows the throttling
+// responses of a service.
+type adaptiveLimiter struct {
+	cfg AdaptiveConfig
+
+	mu      sync.Mutex
+	enabled bool
+
+	// token bucket
+	fillRate   float64
+	capacity   float64
+	tokens     float64
+	lastRefill time.Time
+
+	// cubic state
+	lastMaxRate  float64
+	lastThrottle time.Time
+	timeWindow   float64
+
+	// measured send rate
+	measuredRate float64
+	bucketStart  time.Time
+	bucketCount  float64
+}
+
+func (l *adaptiveLimiter) acquire(ctx context.Context, now func() time.Time) error {
+	for {
+		l.mu.Lock()
+		if !l.enabled {
+			l.mu.Unlock()
+			return nil
+		}
+
+		l.refill(now())
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.fillRate * float64(time.Second))
+		l.mu.Unlock()
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return context.Cause(ctx)
+		}
+	}
+}
+
+func (l *adaptiveLimiter) refill(now time.Time) {
+	if !l.lastRefill.IsZero() {
+		l.tokens = min(l.capacity, l.tokens+now.Sub(l.lastRefill).Seconds()*l.fillRate)
+	}
+	l.lastRefill = now
+}
+
+func (l *adaptiveLimiter) throttle(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.measure(now)
+
+	rate := l.measuredRate
+	if rate == 0 {
+		// no full measurement yet, estimate from the current bucket
+		rate = l.bucketCount / max(now.Sub(l.bucketStart), measureInterval).Seconds()
+	}
+	if l.enabled {
+		rate = min(rate, l.fillRate)
+	}
+
+	l.lastMaxRate = rate
+	l.lastThrottle = now
+	l.timeWindow = math.Cbrt(l.lastMaxRate * (1 - l.cfg.Beta) / l.cfg.ScaleConstant)
+
+	l.refill(now)
+	l.setRate(rate * l.cfg.Beta)
+	l.enabled = true
+}
+
+func (l *adaptiveLimiter) success(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.measure(now)
+	if !l.enabled {
+		return
+	}
+
+	var rate float64
+	switch l.cfg.Recovery {
+	case RecoveryAdditive:
+		rate = l.fillRate + l.cfg.AdditiveIncrease
+	default:
+		elapsed := now.Sub(l.lastThrottle).Seconds()
+		rate = l.cfg.ScaleConstant*math.Pow(elapsed-l.timeWindow, 3) + l.lastMaxRate
+	}
+
+	// never grow beyond twice the rate requests are actually sent at
+	if l.measuredRate > 0 {
+		rate = min(rate, 2*l.measuredRate)
+	}
+
+	l.refill(now)
+	l.setRate(rate)
+}
+
+func (l *adaptiveLimiter) setRate(rate float64) {
+	l.fillRate = max(rate, l.cfg.MinRate)
+	l.capacity = max(l.fillRate, 1)
+	l.tokens = min(l.tokens, l.capacity)
+}
+
+// measure updates the smoothed send rate with a request sent at now
+func (l *adaptiveLimiter) measure(now time.Time) {
+	if l.bucketStart.IsZero() {
+		l.bucketStart = now
+	}
+	l.bucketCount++
+
+	if elapsed := now.Sub(l.bucketStart); elapsed >= measureInterval {
+		current := l.bucketCount / elapsed.Seconds()
+		if l.measuredRate == 0 {
+			l.measuredRate = current
+		} else {
+			l.measuredRate = 0.8*current + 0.2*l.measuredRate
+		}
+		l.bucketStart = now
+		l.bucketCount = 0
+	}
+}
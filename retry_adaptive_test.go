@@ -0,0 +1,184 @@
+package gorequest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestAdaptiveLimiter(t *testing.T) {
+	// send requests at 10 per second for 2 seconds
+	warmUp := func(l *adaptiveLimiter, clock *fakeClock) {
+		for i := 0; i < 20; i++ {
+			clock.Advance(100 * time.Millisecond)
+			l.success(clock.Now())
+		}
+	}
+
+	t.Run("test that the rate is not limited before a throttle", func(t *testing.T) {
+		clock := &fakeClock{t: time.Now()}
+		l := &adaptiveLimiter{cfg: AdaptiveConfig{}.withDefaults()}
+		warmUp(l, clock)
+
+		assert.False(t, l.enabled)
+		assert.NoError(t, l.acquire(context.Background(), clock.Now))
+	})
+
+	t.Run("test that a throttle cuts the measured rate", func(t *testing.T) {
+		clock := &fakeClock{t: time.Now()}
+		l := &adaptiveLimiter{cfg: AdaptiveConfig{}.withDefaults()}
+		warmUp(l, clock)
+
+		clock.Advance(100 * time.Millisecond)
+		l.throttle(clock.Now())
+		assert.True(t, l.enabled)
+		assert.InDelta(t, 7, l.fillRate, 0.5)
+
+		// a second throttle cuts the rate again
+		clock.Advance(100 * time.Millisecond)
+		l.throttle(clock.Now())
+		assert.InDelta(t, 4.9, l.fillRate, 0.5)
+	})
+
+	t.Run("test that the rate recovers along a cubic curve", func(t *testing.T) {
+		clock := &fakeClock{t: time.Now()}
+		l := &adaptiveLimiter{cfg: AdaptiveConfig{}.withDefaults()}
+		warmUp(l, clock)
+
+		clock.Advance(100 * time.Millisecond)
+		l.throttle(clock.Now())
+		lastMax, cut := l.lastMaxRate, l.fillRate
+
+		// shortly after the throttle the rate is still close to the cut
+		clock.Advance(100 * time.Millisecond)
+		l.success(clock.Now())
+		assert.Less(t, l.fillRate, lastMax)
+		assert.Greater(t, l.fillRate, cut)
+
+		// after the time window the rate is back at the last maximum
+		window := time.Duration(l.timeWindow * float64(time.Second))
+		for clock.Now().Sub(l.lastThrottle) < window {
+			clock.Advance(100 * time.Millisecond)
+			l.success(clock.Now())
+		}
+		assert.InDelta(t, lastMax, l.fillRate, 0.5)
+	})
+
+	t.Run("test that the rate recovers additively", func(t *testing.T) {
+		clock := &fakeClock{t: time.Now()}
+		l := &adaptiveLimiter{cfg: AdaptiveConfig{Recovery: RecoveryAdditive, AdditiveIncrease: 0.5}.withDefaults()}
+		warmUp(l, clock)
+
+		clock.Advance(100 * time.Millisecond)
+		l.throttle(clock.Now())
+		cut := l.fillRate
+
+		clock.Advance(100 * time.Millisecond)
+		l.success(clock.Now())
+		assert.InDelta(t, cut+0.5, l.fillRate, 0.001)
+	})
+
+	t.Run("test that acquire waits for a token and honours the context", func(t *testing.T) {
+		l := &adaptiveLimiter{cfg: AdaptiveConfig{MinRate: 0.1}.withDefaults()}
+		l.enabled = true
+		l.setRate(0.1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := l.acquire(ctx, time.Now)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestAdaptiveRetryer(t *testing.T) {
+	adaptive := NewAdaptiveRetryer(retryer{}, AdaptiveConfig{})
+
+	sent := 0
+	hooks := Hooks{}
+	hooks.Send.PushBack(func(r *Request) {
+		sent++
+		status := http.StatusOK
+		if sent == 1 {
+			status = http.StatusTooManyRequests
+			r.Error = errors.New("throttled")
+		}
+		r.Response = &http.Response{StatusCode: status, Header: http.Header{}}
+	})
+	hooks.Retry.PushBack(func(r *Request) {
+		r.RetryConfig.RetryCount++
+	})
+	adaptive.Apply(&hooks)
+
+	req := New(Config{ServiceName: "orders"}, Operation{Method: http.MethodGet}, hooks, adaptive, nil, nil)
+	req.WithRetryConfig(RetryConfig{MaxRetries: 3, InitialDelay: time.Millisecond})
+
+	assert.NoError(t, req.Send())
+	assert.Equal(t, 2, sent)
+
+	rate, limited := adaptive.SendRate("orders")
+	assert.True(t, limited)
+	assert.Greater(t, rate, float64(0))
+
+	// the state is kept per service
+	_, limited = adaptive.SendRate("payments")
+	assert.False(t, limited)
+}
+
+func TestAdaptiveRetryer_ThrottleErrorCodes(t *testing.T) {
+	adaptive := NewAdaptiveRetryer(retryer{}, AdaptiveConfig{ThrottleErrorCodes: []string{"Throttling"}})
+
+	sent := 0
+	hooks := Hooks{}
+	hooks.Send.PushBack(func(r *Request) {
+		sent++
+		r.Response = &http.Response{StatusCode: http.StatusBadRequest, Header: http.Header{}}
+	})
+	// the error code is decoded from the response by an unmarshal hook
+	hooks.Unmarshal.PushBack(func(r *Request) {
+		r.Error = codedError{code: "Throttling"}
+	})
+	hooks.Retry.PushBack(func(r *Request) {
+		r.RetryConfig.RetryCount++
+	})
+	adaptive.Apply(&hooks)
+
+	req := New(Config{ServiceName: "orders"}, Operation{Method: http.MethodGet}, hooks, adaptive, nil, nil)
+	assert.Error(t, req.Send())
+	assert.Equal(t, 1, sent)
+
+	_, limited := adaptive.SendRate("orders")
+	assert.True(t, limited)
+}
+
+func TestAdaptiveRetryer_Throttled(t *testing.T) {
+	adaptive := NewAdaptiveRetryer(retryer{}, AdaptiveConfig{ThrottleErrorCodes: []string{"Throttling"}})
+
+	tcs := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"throttle error code": {err: codedError{code: "Throttling"}, expected: true},
+		"retry error code":    {err: codedError{code: "Conflict"}, expected: false},
+		"no error code":       {err: errors.New("conflict"), expected: false},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			req := New(Config{}, Operation{}, Hooks{}, adaptive, nil, nil)
+			req.RetryConfig.RetryErrorCodes = []string{"Conflict"}
+			req.Response = &http.Response{StatusCode: http.StatusConflict}
+			req.Error = tc.err
+
+			assert.Equal(t, tc.expected, adaptive.throttled(req))
+		})
+	}
+}
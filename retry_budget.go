@@ -0,0 +1,105 @@
+package gorequest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRetryBudgetExhausted is wrapped in the request error when a retry was
+// denied because the retry budget was empty.
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// NewRetryBudget returns a full RetryBudget holding at most maxTokens. Every
+// successful request deposits depositRatio of a token.
+//
+// Share a single budget between all the requests to a service to bound the
+// extra load retries add when the service is failing.
+func NewRetryBudget(maxTokens, depositRatio float64) *RetryBudget {
+	return &RetryBudget{tokens: maxTokens, max: maxTokens, ratio: depositRatio}
+}
+
+// RetryBudget is a token bucket shared by requests. Each retry withdraws a token
+// and each successful request deposits a fraction of one. When the bucket is
+// empty retries are denied until enough requests succeed.
+type RetryBudget struct {
+	mu     sync.Mutex
+	tokens float64
+	max    float64
+	ratio  float64
+}
+
+// Available returns the number of tokens in the budget
+func (b *RetryBudget) Available() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens
+}
+
+// Deposit adds the deposit ratio of a token to the budget, up to the maximum.
+func (b *RetryBudget) Deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, b.max)
+}
+
+// Withdraw takes a token for a retry. It returns false if the budget does not
+// hold a whole token.
+func (b *RetryBudget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Retryer returns a Retryer that retries the requests retryer allows while the
+// budget has tokens.
+func (b *RetryBudget) Retryer(retryer Retryer) Retryer {
+	if retryer == nil {
+		retryer = noOpRetryer{}
+	}
+	return budgetRetryer{budget: b, retryer: retryer}
+}
+
+// Complete returns a complete hook that deposits into the budget when a request
+// succeeds. Register it on every request using the budget's Retryer.
+func (b *RetryBudget) Complete() Hook {
+	return Hook{Name: "gorequest.RetryBudget", Fn: func(r *Request) {
+		if r.Error == nil {
+			b.Deposit()
+		}
+	}}
+}
+
+type budgetRetryer struct {
+	budget  *RetryBudget
+	retryer Retryer
+}
+
+// Delay returns the delay of the wrapped retryer
+func (r budgetRetryer) Delay(req *Request) time.Duration {
+	return r.retryer.Delay(req)
+}
+
+// Retryable returns true if the wrapped retryer allows the retry and a token
+// could be withdrawn from the budget. When the budget is empty the request
+// error is wrapped with ErrRetryBudgetExhausted.
+func (r budgetRetryer) Retryable(req *Request) bool {
+	if !r.retryer.Retryable(req) {
+		return false
+	}
+	if r.budget.Withdraw() {
+		return true
+	}
+
+	req.Error = fmt.Errorf("%w: %w", ErrRetryBudgetExhausted, req.Error)
+	if req.Config.LogLevel.AtLeast(LogError) && req.Config.Logger != nil {
+		req.Config.Logger.Log(fmt.Sprintf("DEBUG: %s retry denied, %v",
+			req.Operation.Name, ErrRetryBudgetExhausted))
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package gorequest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBudget(t *testing.T) {
+
+	t.Run("test that withdrawals are denied when the budget is empty", func(t *testing.T) {
+		budget := NewRetryBudget(2, 0.5)
+
+		assert.True(t, budget.Withdraw())
+		assert.True(t, budget.Withdraw())
+		assert.False(t, budget.Withdraw())
+
+		// two successes deposit a whole token
+		budget.Deposit()
+		assert.False(t, budget.Withdraw())
+		budget.Deposit()
+		assert.True(t, budget.Withdraw())
+	})
+
+	t.Run("test that deposits do not exceed the maximum", func(t *testing.T) {
+		budget := NewRetryBudget(2, 1)
+		budget.Deposit()
+		assert.Equal(t, float64(2), budget.Available())
+	})
+
+	t.Run("test that requests share the budget", func(t *testing.T) {
+		budget := NewRetryBudget(2, 0.1)
+
+		var logs []string
+		logger := LoggerFunc(func(args ...any) {
+			if msg := fmt.Sprint(args...); strings.Contains(msg, "retry denied") {
+				logs = append(logs, msg)
+			}
+		})
+
+		sent := 0
+		hooks := Hooks{}
+		hooks.Send.PushBack(func(r *Request) {
+			sent++
+			r.Error = FakeTemporaryError{error: errors.New("fake error"), temporary: true}
+		})
+		hooks.Retry.PushBack(func(r *Request) {
+			r.RetryConfig.RetryCount++
+		})
+		hooks.Complete.PushBackHook(budget.Complete())
+
+		cfg := Config{Logger: logger, LogLevel: LogError}
+		retryCfg := RetryConfig{MaxRetries: 5, InitialDelay: time.Millisecond}
+
+		// the first request spends the whole budget
+		req := New(cfg, Operation{Name: "Foo"}, hooks, budget.Retryer(retryer{}), nil, nil)
+		req.WithRetryConfig(retryCfg)
+		err := req.Send()
+		assert.ErrorIs(t, err, ErrRetryBudgetExhausted)
+		assert.Equal(t, 3, sent)
+
+		// the second request is not retried
+		req = New(cfg, Operation{Name: "Foo"}, hooks, budget.Retryer(retryer{}), nil, nil)
+		req.WithRetryConfig(retryCfg)
+		err = req.Send()
+		assert.ErrorIs(t, err, ErrRetryBudgetExhausted)
+		assert.Equal(t, 4, sent)
+
+		assert.Len(t, logs, 2)
+		assert.Contains(t, logs[0], ErrRetryBudgetExhausted.Error())
+	})
+
+	t.Run("test that requests the retryer rejects do not spend tokens", func(t *testing.T) {
+		budget := NewRetryBudget(1, 0.1)
+
+		req := New(Config{}, Operation{}, Hooks{}, budget.Retryer(nil), nil, nil)
+		req.Error = errors.New("fake error")
+
+		assert.False(t, req.Retryable(req))
+		assert.Equal(t, float64(1), budget.Available())
+		assert.NotErrorIs(t, req.Error, ErrRetryBudgetExhausted)
+	})
+}
@@ -0,0 +1,168 @@
+package gorequest
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RetryCondition reports whether a failed request should be retried.
+type RetryCondition func(*Request) bool
+
+// RetryOnStatus retries responses with any of the status codes.
+func RetryOnStatus(codes ...int) RetryCondition {
+	return func(r *Request) bool {
+		return r.Response != nil && slices.Contains(codes, r.Response.StatusCode)
+	}
+}
+
+// RetryOnErrorKinds retries errors classified as any of the kinds.
+func RetryOnErrorKinds(kinds ...ErrorKind) RetryCondition {
+	return func(r *Request) bool {
+		return r.Error != nil && slices.Contains(kinds, Classify(r))
+	}
+}
+
+// ErrorCoder is implemented by errors carrying an API error code.
+type ErrorCoder interface {
+	ErrorCode() string
+}
+
+// RetryOnErrorCodes retries errors whose API error code is one of codes or one
+// of the request's RetryConfig.RetryErrorCodes.
+func RetryOnErrorCodes(codes ...string) RetryCondition {
+	return func(r *Request) bool {
+		var coder ErrorCoder
+		if r.Error == nil || !errors.As(r.Error, &coder) {
+			return false
+		}
+		code := coder.ErrorCode()
+		return slices.Contains(codes, code) || slices.Contains(r.RetryConfig.RetryErrorCodes, code)
+	}
+}
+
+// RetryOnMethods matches requests sent with any of the http methods. Use it
+// with And to limit retries to idempotent methods.
+func RetryOnMethods(methods ...string) RetryCondition {
+	return func(r *Request) bool {
+		return r.Request != nil && slices.ContainsFunc(methods, func(m string) bool {
+			return strings.EqualFold(m, r.Request.Method)
+		})
+	}
+}
+
+// And returns a condition that is true when all conditions are true.
+func And(conds ...RetryCondition) RetryCondition {
+	return func(r *Request) bool {
+		for _, cond := range conds {
+			if !cond(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a condition that is true when any of the conditions is true.
+func Or(conds ...RetryCondition) RetryCondition {
+	return func(r *Request) bool {
+		for _, cond := range conds {
+			if cond(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a condition that negates cond.
+func Not(cond RetryCondition) RetryCondition {
+	return func(r *Request) bool {
+		return !cond(r)
+	}
+}
+
+// NewRetryPolicy returns an empty RetryPolicy. Without conditions, the policy
+// retries using DefaultRetryCondition.
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{}
+}
+
+// RetryPolicy is a Retryer composed of retry conditions. A request is retried
+// when the retry limits of its RetryConfig allow it, its method is allowed and
+// any of the policy conditions is true.
+//
+// A policy should be fully configured before it is used by requests.
+type RetryPolicy struct {
+	conditions []RetryCondition
+	methods    []string
+	delay      func(*Request) time.Duration
+	overrides  map[string]*RetryPolicy
+}
+
+// RetryOn adds conditions to the policy. The request is retried if any of the
+// conditions added to the policy is true.
+func (p *RetryPolicy) RetryOn(conds ...RetryCondition) *RetryPolicy {
+	p.conditions = append(p.conditions, conds...)
+	return p
+}
+
+// AllowMethods limits retries to requests sent with the http methods.
+func (p *RetryPolicy) AllowMethods(methods ...string) *RetryPolicy {
+	p.methods = append(p.methods, methods...)
+	return p
+}
+
+// WithDelay sets the function that computes the delay before the next attempt.
+// The default retryer's delay is used if not set.
+func (p *RetryPolicy) WithDelay(fn func(*Request) time.Duration) *RetryPolicy {
+	p.delay = fn
+	return p
+}
+
+// ForOperation sets the policy used instead of p for requests of the named
+// operation.
+func (p *RetryPolicy) ForOperation(name string, policy *RetryPolicy) *RetryPolicy {
+	if p.overrides == nil {
+		p.overrides = make(map[string]*RetryPolicy)
+	}
+	p.overrides[name] = policy
+	return p
+}
+
+// policyFor returns the policy for the request's operation
+func (p *RetryPolicy) policyFor(r *Request) *RetryPolicy {
+	if override, ok := p.overrides[r.Operation.Name]; ok {
+		return override
+	}
+	return p
+}
+
+// Delay returns the duration to wait before the next attempt.
+func (p *RetryPolicy) Delay(r *Request) time.Duration {
+	policy := p.policyFor(r)
+	if policy.delay != nil {
+		return policy.delay(r)
+	}
+	return DefaultRetryer.Delay(r)
+}
+
+// Retryable returns true if the request should be retried.
+func (p *RetryPolicy) Retryable(r *Request) bool {
+	policy := p.policyFor(r)
+
+	if r.Error == nil {
+		return false
+	}
+	if !retryLimitsAllow(r, policy.Delay(r)) {
+		return false
+	}
+	if len(policy.methods) > 0 && !RetryOnMethods(policy.methods...)(r) {
+		return false
+	}
+	if len(policy.conditions) == 0 {
+		return DefaultRetryCondition(r)
+	}
+	return Or(policy.conditions...)(r)
+}
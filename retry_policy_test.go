@@ -0,0 +1,121 @@
+package gorequest
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type codedError struct{ code string }
+
+func (e codedError) Error() string     { return "api error " + e.code }
+func (e codedError) ErrorCode() string { return e.code }
+
+func newPolicyRequest(op Operation, status int, err error) *Request {
+	req := New(Config{}, op, Hooks{}, nil, nil, nil)
+	req.WithRetryConfig(RetryConfig{MaxRetries: 3, InitialDelay: time.Millisecond})
+	req.Response = &http.Response{StatusCode: status}
+	req.Error = err
+	return req
+}
+
+func TestRetryConditions(t *testing.T) {
+	get := Operation{Method: http.MethodGet}
+	post := Operation{Method: http.MethodPost}
+	fakeErr := errors.New("fake error")
+
+	tcs := map[string]struct {
+		Condition RetryCondition
+		Request   *Request
+		Expected  bool
+	}{
+		"status matches":        {Condition: RetryOnStatus(502, 503), Request: newPolicyRequest(get, 503, fakeErr), Expected: true},
+		"status does not match": {Condition: RetryOnStatus(502, 503), Request: newPolicyRequest(get, 500, fakeErr), Expected: false},
+		"error kind matches":    {Condition: RetryOnErrorKinds(ErrorKindThrottled), Request: newPolicyRequest(get, 429, fakeErr), Expected: true},
+		"error kind no error":   {Condition: RetryOnErrorKinds(ErrorKindThrottled), Request: newPolicyRequest(get, 429, nil), Expected: false},
+		"error code matches":    {Condition: RetryOnErrorCodes("Busy"), Request: newPolicyRequest(get, 400, codedError{"Busy"}), Expected: true},
+		"error code mismatch":   {Condition: RetryOnErrorCodes("Busy"), Request: newPolicyRequest(get, 400, codedError{"Invalid"}), Expected: false},
+		"method matches":        {Condition: RetryOnMethods(http.MethodGet), Request: newPolicyRequest(get, 500, fakeErr), Expected: true},
+		"and":                   {Condition: And(RetryOnStatus(500), RetryOnMethods(http.MethodGet)), Request: newPolicyRequest(post, 500, fakeErr), Expected: false},
+		"or":                    {Condition: Or(RetryOnStatus(502), RetryOnMethods(http.MethodPost)), Request: newPolicyRequest(post, 500, fakeErr), Expected: true},
+		"not":                   {Condition: Not(RetryOnMethods(http.MethodPost)), Request: newPolicyRequest(post, 500, fakeErr), Expected: false},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, tc.Condition(tc.Request))
+		})
+	}
+
+	t.Run("test that error codes in the retry config are retried", func(t *testing.T) {
+		req := newPolicyRequest(get, 400, codedError{"Throttling"})
+		req.RetryConfig.RetryErrorCodes = []string{"Throttling"}
+		assert.True(t, RetryOnErrorCodes()(req))
+	})
+}
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+
+	t.Run("test that a policy without conditions uses the default condition", func(t *testing.T) {
+		policy := NewRetryPolicy()
+		get := Operation{Method: http.MethodGet}
+		assert.True(t, policy.Retryable(newPolicyRequest(get, 503, errors.New("unavailable"))))
+		assert.False(t, policy.Retryable(newPolicyRequest(get, 400, errors.New("bad request"))))
+		assert.False(t, policy.Retryable(newPolicyRequest(Operation{Method: http.MethodPost}, 503, errors.New("unavailable"))))
+	})
+
+	t.Run("test that the retry limits are enforced", func(t *testing.T) {
+		policy := NewRetryPolicy().RetryOn(RetryOnStatus(400))
+
+		req := newPolicyRequest(Operation{}, 400, errors.New("bad request"))
+		assert.True(t, policy.Retryable(req))
+
+		req.RetryConfig.RetryCount = req.RetryConfig.MaxRetries
+		assert.False(t, policy.Retryable(req))
+	})
+
+	t.Run("test that methods outside the allow list are not retried", func(t *testing.T) {
+		policy := NewRetryPolicy().
+			RetryOn(RetryOnStatus(503)).
+			AllowMethods(http.MethodGet, http.MethodHead)
+
+		assert.True(t, policy.Retryable(newPolicyRequest(Operation{Method: http.MethodGet}, 503, errors.New("unavailable"))))
+		assert.False(t, policy.Retryable(newPolicyRequest(Operation{Method: http.MethodPost}, 503, errors.New("unavailable"))))
+	})
+
+	t.Run("test that operation overrides replace the policy", func(t *testing.T) {
+		writes := NewRetryPolicy().RetryOn(RetryOnErrorKinds(ErrorKindConnectionRefused))
+		policy := NewRetryPolicy().
+			RetryOn(RetryOnStatus(500, 502, 503)).
+			ForOperation("CreateOrder", writes)
+
+		read := Operation{Name: "GetOrder", Method: http.MethodGet}
+		write := Operation{Name: "CreateOrder", Method: http.MethodPost}
+
+		assert.True(t, policy.Retryable(newPolicyRequest(read, 503, errors.New("unavailable"))))
+		assert.False(t, policy.Retryable(newPolicyRequest(write, 503, errors.New("unavailable"))))
+	})
+
+	t.Run("test that the policy retries requests sent with it", func(t *testing.T) {
+		hooks := Hooks{}
+		sent := 0
+		hooks.Send.PushBack(func(r *Request) {
+			sent++
+			r.Response = &http.Response{StatusCode: http.StatusConflict}
+			r.Error = errors.New("conflict")
+		})
+		hooks.Retry.PushBack(func(r *Request) {
+			r.RetryConfig.RetryCount++
+		})
+
+		policy := NewRetryPolicy().RetryOn(RetryOnStatus(http.StatusConflict))
+		req := New(Config{}, Operation{}, hooks, policy, nil, nil)
+		req.WithRetryConfig(RetryConfig{MaxRetries: 2, InitialDelay: time.Millisecond})
+
+		assert.Error(t, req.Send())
+		assert.Equal(t, 3, sent)
+	})
+}
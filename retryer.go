@@ -3,6 +3,7 @@ package gorequest
 import (
 	"errors"
 	"math/rand/v2"
+	"net/http"
 	"time"
 )
 
@@ -19,6 +20,11 @@ var (
 	DefaultRetryer = retryer{}
 )
 
+// DefaultMaxReauth is the number of re-authentications allowed when
+// RetryConfig.MaxReauth is not set. It allows for a challenge and a renewed
+// challenge, such as a stale nonce.
+const DefaultMaxReauth = 2
+
 type RetryConfig struct {
 	// InitialDelay before the first retry.
 	InitialDelay time.Duration
@@ -43,8 +49,17 @@ type RetryConfig struct {
 	// Maximum allowed time for retries
 	MaxElapsedTime time.Duration
 
-	// Additional API error codes that should be retried. IsErrorRetryable
-	// will consider these codes in addition to its built-in cases.
+	// Number of times the request was sent again after re-authentication.
+	// Re-authentications are not counted as retries.
+	ReauthCount int
+	// Number of maximum allowed re-authentications, guarding against a server
+	// rejecting every credential. Defaults to DefaultMaxReauth when 0, and a
+	// negative value disables re-authentication.
+	MaxReauth int
+
+	// Additional API error codes that should be retried. DefaultRetryCondition
+	// retries errors whose ErrorCoder code is one of these, in addition to its
+	// built-in cases and whatever the request method.
 	RetryErrorCodes []string
 
 	//retryable bool
@@ -92,6 +107,20 @@ func (r retryer) Delay(req *Request) time.Duration {
 // Retryable performs validation checks on the retryer config to confirm if
 // an operation is retry-able.
 func (r retryer) Retryable(req *Request) bool {
+	if !retryLimitsAllow(req, r.Delay(req)) {
+		return false
+	}
+
+	return DefaultRetryCondition(req)
+}
+
+// retryLimitsAllow checks the retry count and elapsed time limits of the
+// request's RetryConfig, given the delay before the next attempt. Requests
+// rejected by the client are never allowed a retry.
+func retryLimitsAllow(req *Request, next time.Duration) bool {
+	if ClassifyError(req.Error) == ErrorKindRejected {
+		return false
+	}
 
 	// check the number of max retries allowed
 	if req.RetryConfig.MaxRetries == 0 {
@@ -105,19 +134,49 @@ func (r retryer) Retryable(req *Request) bool {
 	}
 
 	// total elapsed time plus the next delay duration should never be > than MaxElapsedTime
-	next := r.Delay(req)
 	if req.RetryConfig.MaxElapsedTime > 0 && time.Since(req.AttemptTime)+next > req.RetryConfig.MaxElapsedTime {
 		return false
 	}
 
-	// check if the error is not temporary
-	var te interface{ Temporary() bool }
-	if e := req.Error; e == nil || !errors.As(e, &te) || !te.Temporary() {
+	return true
+}
+
+// IdempotentMethods are the http methods defined as idempotent by RFC 9110.
+// Sending their requests again has the same effect on the server as sending
+// them once.
+var IdempotentMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete,
+}
+
+// DefaultRetryCondition retries errors classified as transient when the request
+// method is one of IdempotentMethods, and errors that report themselves as
+// temporary. A request whose connection was refused was not sent, and is
+// retried whatever its method, as are errors with one of the
+// RetryConfig.RetryErrorCodes. Use a RetryPolicy with RetryOnErrorKinds to
+// retry the transient errors of other methods.
+func DefaultRetryCondition(req *Request) bool {
+	if req.Error == nil {
 		return false
 	}
 
-	return true
+	// retry the error codes the request was configured with
+	if RetryOnErrorCodes()(req) {
+		return true
+	}
 
+	// retry errors classified as transient, when sending the request again is
+	// safe
+	kind := Classify(req)
+	if kind == ErrorKindConnectionRefused {
+		return true
+	}
+	if kind.Retryable() && RetryOnMethods(IdempotentMethods...)(req) {
+		return true
+	}
+
+	// fall back to errors that report themselves as temporary
+	var te interface{ Temporary() bool }
+	return errors.As(req.Error, &te) && te.Temporary()
 }
 
 func calculateRandomInterval(currDelay time.Duration, jitter float64) time.Duration {
@@ -1,7 +1,11 @@
 package gorequest
 
 import (
+	"context"
 	"errors"
+	"net"
+	"net/http"
+	"syscall"
 	"testing"
 	"time"
 
@@ -255,3 +259,46 @@ func TestDefaultRetryer_Retryable(t *testing.T) {
 	})
 
 }
+
+type rejectedError struct{ FakeTemporaryError }
+
+func (rejectedError) ErrorKind() ErrorKind { return ErrorKindRejected }
+
+func TestDefaultRetryer_RetryableKinds(t *testing.T) {
+	cfg := RetryConfig{MaxRetries: 1, InitialDelay: 100 * time.Millisecond, RetryErrorCodes: []string{"Throttling"}}
+
+	tcs := map[string]struct {
+		Method     string
+		Err        error
+		StatusCode int
+		Expected   bool
+	}{
+		"server error":                 {Err: errors.New("status 503"), StatusCode: http.StatusServiceUnavailable, Expected: true},
+		"throttled":                    {Err: errors.New("status 429"), StatusCode: http.StatusTooManyRequests, Expected: true},
+		"client error":                 {Err: errors.New("status 400"), StatusCode: http.StatusBadRequest, Expected: false},
+		"connection refused":           {Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, Expected: true},
+		"canceled":                     {Err: context.Canceled, Expected: false},
+		"rejected":                     {Err: rejectedError{FakeTemporaryError{error: errors.New("rejected"), temporary: true}}, Expected: false},
+		"server error of a post":       {Method: http.MethodPost, Err: errors.New("status 503"), StatusCode: http.StatusServiceUnavailable, Expected: false},
+		"connection reset of a patch":  {Method: http.MethodPatch, Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}, Expected: false},
+		"connection refused of a post": {Method: http.MethodPost, Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, Expected: true},
+		"temporary error of a post":    {Method: http.MethodPost, Err: FakeTemporaryError{error: errors.New("temporary"), temporary: true}, Expected: true},
+		"configured error code":        {Method: http.MethodPost, Err: codedError{code: "Throttling"}, StatusCode: http.StatusBadRequest, Expected: true},
+		"other error code":             {Err: codedError{code: "ValidationError"}, StatusCode: http.StatusBadRequest, Expected: false},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			method := tc.Method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := New(Config{}, Operation{Method: method}, Hooks{}, retryer{}, nil, nil)
+			req.WithRetryConfig(cfg)
+			req.Error = tc.Err
+			req.Response = &http.Response{StatusCode: tc.StatusCode}
+
+			assert.Equal(t, tc.Expected, retryer{}.Retryable(req))
+		})
+	}
+}
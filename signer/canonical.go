@@ -0,0 +1,102 @@
+package signer
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// escape percent-encodes s as required by SigV4, leaving only the RFC 3986
+// unreserved characters, and slashes when keepSlash is set
+func escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if unreserved(c) || (keepSlash && c == '/') {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteString(strings.ToUpper(strconv.FormatUint(uint64(c)>>4, 16)))
+		b.WriteString(strings.ToUpper(strconv.FormatUint(uint64(c)&0xf, 16)))
+	}
+	return b.String()
+}
+
+func unreserved(c byte) bool {
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '_' || c == '.' || c == '~'
+}
+
+// canonicalPath returns the escaped path of u. When escapePath is set the
+// escaped path is escaped once more, as SigV4 does for services other than S3.
+func canonicalPath(u *url.URL, escapePath bool) string {
+	path := u.EscapedPath()
+	if u.Opaque != "" {
+		path = u.Opaque
+	}
+	if path == "" {
+		return "/"
+	}
+	if escapePath {
+		return escape(path, true)
+	}
+	return path
+}
+
+// canonicalQuery returns the query of u with keys and values escaped and
+// sorted by key, then value
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, escape(k, false)+"="+escape(v, false))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// headerValue returns the values of a header trimmed, with sequential spaces
+// collapsed, and joined by commas
+func headerValue(req *http.Request, name string) string {
+	switch name {
+	case "host":
+		if req.Host != "" {
+			return req.Host
+		}
+		return req.URL.Host
+	case "content-length":
+		return strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	values := req.Header.Values(name)
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.Join(strings.Fields(v), " ")
+	}
+	return strings.Join(trimmed, ",")
+}
+
+// canonicalHeaders returns the canonical headers block for the lower case
+// header names, which must be sorted
+func canonicalHeaders(req *http.Request, names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(headerValue(req, name))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
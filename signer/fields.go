@@ -0,0 +1,362 @@
+package signer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// the subset of structured fields (RFC 8941) used by message signatures
+
+// sfToken is a structured field token, serialized without quotes
+type sfToken string
+
+type sfParam struct {
+	key   string
+	value any
+}
+
+type sfParams []sfParam
+
+func (ps sfParams) get(key string) (any, bool) {
+	for _, p := range ps {
+		if p.key == key {
+			return p.value, true
+		}
+	}
+	return nil, false
+}
+
+// sfItem is a bare item with parameters
+type sfItem struct {
+	value  any
+	params sfParams
+}
+
+// sfMember is a dictionary member, an item or an inner list. raw is the text
+// of the member as it was parsed.
+type sfMember struct {
+	key    string
+	raw    string
+	item   sfItem
+	inner  []sfItem
+	isList bool
+}
+
+type sfParser struct {
+	s string
+	i int
+}
+
+func (p *sfParser) eof() bool { return p.i >= len(p.s) }
+
+func (p *sfParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+func (p *sfParser) skipSP() {
+	for !p.eof() && p.s[p.i] == ' ' {
+		p.i++
+	}
+}
+
+func (p *sfParser) skipOWS() {
+	for !p.eof() && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+func (p *sfParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid structured field at %d: %s", p.i, fmt.Sprintf(format, args...))
+}
+
+func (p *sfParser) key() (string, error) {
+	start := p.i
+	if c := p.peek(); !(c >= 'a' && c <= 'z' || c == '*') {
+		return "", p.errorf("expected key")
+	}
+	for !p.eof() {
+		c := p.s[p.i]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.' || c == '*') {
+			break
+		}
+		p.i++
+	}
+	return p.s[start:p.i], nil
+}
+
+func (p *sfParser) bareItem() (any, error) {
+	c := p.peek()
+	switch {
+	case c == '"':
+		return p.string()
+	case c == ':':
+		return p.byteSequence()
+	case c == '?':
+		p.i++
+		switch p.peek() {
+		case '1':
+			p.i++
+			return true, nil
+		case '0':
+			p.i++
+			return false, nil
+		}
+		return nil, p.errorf("expected boolean")
+	case c == '-' || c >= '0' && c <= '9':
+		return p.integer()
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '*':
+		start := p.i
+		for !p.eof() && strings.IndexByte(" ,;()=\"", p.s[p.i]) < 0 {
+			p.i++
+		}
+		return sfToken(p.s[start:p.i]), nil
+	}
+	return nil, p.errorf("unexpected %q", c)
+}
+
+func (p *sfParser) string() (string, error) {
+	var b strings.Builder
+	p.i++
+	for !p.eof() {
+		c := p.s[p.i]
+		p.i++
+		switch {
+		case c == '\\':
+			if p.eof() || (p.s[p.i] != '"' && p.s[p.i] != '\\') {
+				return "", p.errorf("invalid escape")
+			}
+			b.WriteByte(p.s[p.i])
+			p.i++
+		case c == '"':
+			return b.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return "", p.errorf("invalid string character")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *sfParser) byteSequence() ([]byte, error) {
+	p.i++
+	end := strings.IndexByte(p.s[p.i:], ':')
+	if end < 0 {
+		return nil, p.errorf("unterminated byte sequence")
+	}
+	data, err := base64.StdEncoding.DecodeString(p.s[p.i : p.i+end])
+	if err != nil {
+		return nil, p.errorf("invalid byte sequence: %v", err)
+	}
+	p.i += end + 1
+	return data, nil
+}
+
+func (p *sfParser) integer() (int64, error) {
+	start := p.i
+	if p.peek() == '-' {
+		p.i++
+	}
+	for !p.eof() && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+		p.i++
+	}
+	n, err := strconv.ParseInt(p.s[start:p.i], 10, 64)
+	if err != nil || p.peek() == '.' {
+		return 0, p.errorf("invalid integer")
+	}
+	return n, nil
+}
+
+func (p *sfParser) params() (sfParams, error) {
+	var params sfParams
+	for p.peek() == ';' {
+		p.i++
+		p.skipSP()
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		var value any = true
+		if p.peek() == '=' {
+			p.i++
+			if value, err = p.bareItem(); err != nil {
+				return nil, err
+			}
+		}
+		params = append(params, sfParam{key: key, value: value})
+	}
+	return params, nil
+}
+
+func (p *sfParser) item() (sfItem, error) {
+	value, err := p.bareItem()
+	if err != nil {
+		return sfItem{}, err
+	}
+	params, err := p.params()
+	return sfItem{value: value, params: params}, err
+}
+
+func (p *sfParser) innerList() ([]sfItem, sfParams, error) {
+	p.i++
+	var items []sfItem
+	for {
+		p.skipSP()
+		if p.peek() == ')' {
+			p.i++
+			params, err := p.params()
+			return items, params, err
+		}
+		if p.eof() {
+			return nil, nil, p.errorf("unterminated inner list")
+		}
+		item, err := p.item()
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, item)
+		if c := p.peek(); c != ' ' && c != ')' {
+			return nil, nil, p.errorf("expected space or end of inner list")
+		}
+	}
+}
+
+// parseDictionary parses a dictionary field, keeping the members in order
+func parseDictionary(s string) ([]sfMember, error) {
+	p := &sfParser{s: s}
+	var members []sfMember
+	p.skipSP()
+	for !p.eof() {
+		start := p.i
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		member := sfMember{key: key}
+		if p.peek() == '=' {
+			p.i++
+			if p.peek() == '(' {
+				member.isList = true
+				member.inner, member.item.params, err = p.innerList()
+			} else {
+				member.item, err = p.item()
+			}
+		} else {
+			member.item.value = true
+			member.item.params, err = p.params()
+		}
+		if err != nil {
+			return nil, err
+		}
+		member.raw = s[start:p.i]
+		members = append(members, member)
+
+		p.skipOWS()
+		if p.eof() {
+			break
+		}
+		if p.peek() != ',' {
+			return nil, p.errorf("expected comma")
+		}
+		p.i++
+		p.skipOWS()
+		if p.eof() {
+			return nil, p.errorf("trailing comma")
+		}
+	}
+	return members, nil
+}
+
+// headerDictionary parses the dictionary of all the lines of a header
+func headerDictionary(header http.Header, name string) ([]sfMember, error) {
+	values := header.Values(name)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return parseDictionary(strings.Join(values, ", "))
+}
+
+// setDictionaryMember sets the member key of a dictionary header to value,
+// keeping the other members
+func setDictionaryMember(header http.Header, name, key, value string) error {
+	members, err := headerDictionary(header, name)
+	if err != nil {
+		return err
+	}
+
+	var fields []string
+	for _, member := range members {
+		if member.key != key {
+			fields = append(fields, member.raw)
+		}
+	}
+	header.Set(name, strings.Join(append(fields, key+"="+value), ", "))
+	return nil
+}
+
+func serializeBareItem(b *strings.Builder, value any) {
+	switch v := value.(type) {
+	case string:
+		b.WriteByte('"')
+		for i := 0; i < len(v); i++ {
+			if v[i] == '"' || v[i] == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(v[i])
+		}
+		b.WriteByte('"')
+	case sfToken:
+		b.WriteString(string(v))
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case bool:
+		if v {
+			b.WriteString("?1")
+		} else {
+			b.WriteString("?0")
+		}
+	case []byte:
+		b.WriteByte(':')
+		b.WriteString(base64.StdEncoding.EncodeToString(v))
+		b.WriteByte(':')
+	}
+}
+
+func serializeParams(b *strings.Builder, params sfParams) {
+	for _, p := range params {
+		b.WriteByte(';')
+		b.WriteString(p.key)
+		if v, ok := p.value.(bool); ok && v {
+			continue
+		}
+		b.WriteByte('=')
+		serializeBareItem(b, p.value)
+	}
+}
+
+func serializeItem(item sfItem) string {
+	var b strings.Builder
+	serializeBareItem(&b, item.value)
+	serializeParams(&b, item.params)
+	return b.String()
+}
+
+func serializeInnerList(items []sfItem, params sfParams) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, item := range items {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(serializeItem(item))
+	}
+	b.WriteByte(')')
+	serializeParams(&b, params)
+	return b.String()
+}
@@ -0,0 +1,151 @@
+package signer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SirWaithaka/gorequest/credentials"
+)
+
+// DefaultDateHeader is the header the HMAC signer puts the signing time in.
+const DefaultDateHeader = "X-Date"
+
+// CanonicalizeFunc returns the string signed for a request. signedHeaders are
+// the sorted lower case names of the headers to include, and payloadHash the
+// hex SHA-256 of the body, or UNSIGNED-PAYLOAD.
+type CanonicalizeFunc func(req *http.Request, signedHeaders []string, payloadHash string) (string, error)
+
+// AuthorizationFunc returns the Authorization header of a signed request.
+type AuthorizationFunc func(keyID string, signedHeaders []string, signature string) string
+
+// HMACConfig configures an HMAC signer.
+type HMACConfig struct {
+	KeyID  string
+	Secret []byte
+	// Provider provides the key id and secret instead of KeyID and Secret,
+	// retrieved every time a request is signed so that a rotated secret is
+	// used by retries.
+	Provider credentials.Provider
+	// DateHeader is the header set to the signing time, formatted with
+	// TimeFormat. Defaults to DefaultDateHeader and time.RFC3339.
+	DateHeader string
+	TimeFormat string
+	// SignedHeaders are the headers included in the signature. The host and
+	// the date header are always included.
+	SignedHeaders []string
+	// PayloadHashHeader is set to the payload hash when it is not empty.
+	PayloadHashHeader string
+	// Payload is how the body is signed. PayloadStreaming is not supported.
+	Payload PayloadMode
+	// Canonicalize returns the string to sign. Defaults to
+	// CanonicalRequest.
+	Canonicalize CanonicalizeFunc
+	// Authorization returns the Authorization header. Defaults to
+	// "HMAC-SHA256 Credential=<KeyID>, SignedHeaders=<names>, Signature=<hex>".
+	Authorization AuthorizationFunc
+}
+
+// NewHMAC returns a Signer computing an HMAC-SHA256 signature over the
+// canonical form of requests.
+func NewHMAC(cfg HMACConfig) *HMAC {
+	if cfg.DateHeader == "" {
+		cfg.DateHeader = DefaultDateHeader
+	}
+	if cfg.TimeFormat == "" {
+		cfg.TimeFormat = time.RFC3339
+	}
+	if cfg.Canonicalize == nil {
+		cfg.Canonicalize = CanonicalRequest
+	}
+	if cfg.Authorization == nil {
+		cfg.Authorization = func(keyID string, signedHeaders []string, signature string) string {
+			return fmt.Sprintf("HMAC-SHA256 Credential=%s, SignedHeaders=%s, Signature=%s",
+				keyID, strings.Join(signedHeaders, ";"), signature)
+		}
+	}
+
+	seen := map[string]bool{}
+	var names []string
+	for _, name := range append([]string{"host", cfg.DateHeader}, cfg.SignedHeaders...) {
+		name = strings.ToLower(name)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	cfg.SignedHeaders = names
+
+	return &HMAC{cfg: cfg}
+}
+
+// HMAC signs requests with an HMAC-SHA256 of their canonical form, set in
+// the Authorization header.
+type HMAC struct {
+	cfg HMACConfig
+}
+
+func (s *HMAC) Sign(req *http.Request, t time.Time) error {
+	keyID, secret := s.cfg.KeyID, s.cfg.Secret
+	if s.cfg.Provider != nil {
+		creds, err := s.cfg.Provider.Retrieve(req.Context())
+		if err != nil {
+			return err
+		}
+		keyID, secret = creds.ID, []byte(creds.Secret)
+	}
+	if len(secret) == 0 {
+		return errors.New("hmac: missing secret")
+	}
+
+	var hash string
+	switch s.cfg.Payload {
+	case PayloadSigned:
+		var err error
+		if hash, err = payloadHash(req); err != nil {
+			return err
+		}
+	case PayloadUnsigned:
+		hash = unsignedPayload
+	default:
+		return errors.New("hmac: streaming payloads are not supported")
+	}
+
+	req.Header.Del("Authorization")
+	req.Header.Set(s.cfg.DateHeader, t.UTC().Format(s.cfg.TimeFormat))
+	if s.cfg.PayloadHashHeader != "" {
+		req.Header.Set(s.cfg.PayloadHashHeader, hash)
+	}
+
+	canonical, err := s.cfg.Canonicalize(req, s.cfg.SignedHeaders, hash)
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(canonical))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	req.Header.Set("Authorization", s.cfg.Authorization(keyID, s.cfg.SignedHeaders, signature))
+	return nil
+}
+
+// CanonicalRequest is the default canonical form of a request: the method,
+// escaped path, sorted query, signed headers, their names and the payload
+// hash, separated by new lines.
+func CanonicalRequest(req *http.Request, signedHeaders []string, payloadHash string) (string, error) {
+	return strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL, false),
+		canonicalQuery(req.URL),
+		canonicalHeaders(req, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n"), nil
+}
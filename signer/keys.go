@@ -0,0 +1,184 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"math/big"
+)
+
+// Algorithm names of the HTTP signature algorithms registry of RFC 9421.
+const (
+	AlgorithmEd25519      = "ed25519"
+	AlgorithmECDSAP256    = "ecdsa-p256-sha256"
+	AlgorithmRSAPSSSHA512 = "rsa-pss-sha512"
+	AlgorithmHMACSHA256   = "hmac-sha256"
+)
+
+const (
+	rsaPSSSaltLength      = 64
+	ecdsaP256ScalarLength = 32
+)
+
+var errVerification = errors.New("signature does not match")
+
+// SigningKey signs the signature base of HTTP message signatures.
+type SigningKey interface {
+	// Algorithm returns the name of the algorithm of the key.
+	Algorithm() string
+	Sign(base []byte) ([]byte, error)
+}
+
+// VerifyingKey verifies HTTP message signatures.
+type VerifyingKey interface {
+	// Algorithm returns the name of the algorithm of the key.
+	Algorithm() string
+	// Verify returns an error when signature is not a signature of base.
+	Verify(base, signature []byte) error
+}
+
+// SharedKey is a symmetric key, both signing and verifying.
+type SharedKey interface {
+	SigningKey
+	VerifyingKey
+}
+
+// NewEd25519SigningKey returns a key signing with EdDSA over Curve25519.
+func NewEd25519SigningKey(key ed25519.PrivateKey) SigningKey {
+	return ed25519Key{private: key, public: key.Public().(ed25519.PublicKey)}
+}
+
+// NewEd25519VerifyingKey returns a key verifying EdDSA signatures over
+// Curve25519.
+func NewEd25519VerifyingKey(key ed25519.PublicKey) VerifyingKey {
+	return ed25519Key{public: key}
+}
+
+type ed25519Key struct {
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+func (k ed25519Key) Algorithm() string { return AlgorithmEd25519 }
+
+func (k ed25519Key) Sign(base []byte) ([]byte, error) {
+	return ed25519.Sign(k.private, base), nil
+}
+
+func (k ed25519Key) Verify(base, signature []byte) error {
+	if !ed25519.Verify(k.public, base, signature) {
+		return errVerification
+	}
+	return nil
+}
+
+// NewECDSAP256SigningKey returns a key signing with ECDSA over the P-256
+// curve and SHA-256.
+func NewECDSAP256SigningKey(key *ecdsa.PrivateKey) SigningKey {
+	return ecdsaKey{private: key, public: &key.PublicKey}
+}
+
+// NewECDSAP256VerifyingKey returns a key verifying ECDSA signatures over the
+// P-256 curve and SHA-256.
+func NewECDSAP256VerifyingKey(key *ecdsa.PublicKey) VerifyingKey {
+	return ecdsaKey{public: key}
+}
+
+type ecdsaKey struct {
+	private *ecdsa.PrivateKey
+	public  *ecdsa.PublicKey
+}
+
+func (k ecdsaKey) Algorithm() string { return AlgorithmECDSAP256 }
+
+// Sign returns the signature as the concatenation of r and s, as RFC 9421
+// requires, instead of ASN.1
+func (k ecdsaKey) Sign(base []byte) ([]byte, error) {
+	if k.public.Curve != elliptic.P256() {
+		return nil, errors.New("ecdsa key is not on the P-256 curve")
+	}
+	digest := sha256.Sum256(base)
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	signature := make([]byte, 2*ecdsaP256ScalarLength)
+	r.FillBytes(signature[:ecdsaP256ScalarLength])
+	s.FillBytes(signature[ecdsaP256ScalarLength:])
+	return signature, nil
+}
+
+func (k ecdsaKey) Verify(base, signature []byte) error {
+	if len(signature) != 2*ecdsaP256ScalarLength {
+		return errVerification
+	}
+	digest := sha256.Sum256(base)
+	r := new(big.Int).SetBytes(signature[:ecdsaP256ScalarLength])
+	s := new(big.Int).SetBytes(signature[ecdsaP256ScalarLength:])
+	if !ecdsa.Verify(k.public, digest[:], r, s) {
+		return errVerification
+	}
+	return nil
+}
+
+// NewRSAPSSSigningKey returns a key signing with RSASSA-PSS and SHA-512.
+func NewRSAPSSSigningKey(key *rsa.PrivateKey) SigningKey {
+	return rsaPSSKey{private: key, public: &key.PublicKey}
+}
+
+// NewRSAPSSVerifyingKey returns a key verifying RSASSA-PSS signatures with
+// SHA-512.
+func NewRSAPSSVerifyingKey(key *rsa.PublicKey) VerifyingKey {
+	return rsaPSSKey{public: key}
+}
+
+type rsaPSSKey struct {
+	private *rsa.PrivateKey
+	public  *rsa.PublicKey
+}
+
+var rsaPSSOptions = &rsa.PSSOptions{SaltLength: rsaPSSSaltLength, Hash: crypto.SHA512}
+
+func (k rsaPSSKey) Algorithm() string { return AlgorithmRSAPSSSHA512 }
+
+func (k rsaPSSKey) Sign(base []byte) ([]byte, error) {
+	digest := sha512.Sum512(base)
+	return rsa.SignPSS(rand.Reader, k.private, crypto.SHA512, digest[:], rsaPSSOptions)
+}
+
+func (k rsaPSSKey) Verify(base, signature []byte) error {
+	digest := sha512.Sum512(base)
+	if err := rsa.VerifyPSS(k.public, crypto.SHA512, digest[:], signature, rsaPSSOptions); err != nil {
+		return errVerification
+	}
+	return nil
+}
+
+// NewHMACSHA256Key returns a shared key signing and verifying with
+// HMAC-SHA256.
+func NewHMACSHA256Key(secret []byte) SharedKey {
+	return hmacKey(secret)
+}
+
+type hmacKey []byte
+
+func (k hmacKey) Algorithm() string { return AlgorithmHMACSHA256 }
+
+func (k hmacKey) Sign(base []byte) ([]byte, error) {
+	return hmacSHA256(k, base), nil
+}
+
+func (k hmacKey) Verify(base, signature []byte) error {
+	if !hmac.Equal(hmacSHA256(k, base), signature) {
+		return errVerification
+	}
+	return nil
+}
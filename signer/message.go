@@ -0,0 +1,318 @@
+package signer
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLabel is the name of the signatures created by MessageSigner.
+	DefaultLabel = "sig1"
+
+	signatureInputHeader = "Signature-Input"
+	signatureHeader      = "Signature"
+	signatureParams      = "@signature-params"
+)
+
+// MessageConfig configures a signer of HTTP message signatures.
+type MessageConfig struct {
+	// Label is the name of the signature in the Signature-Input and Signature
+	// headers. Defaults to DefaultLabel.
+	Label string
+	// KeyID is set as the keyid parameter when it is not empty.
+	KeyID string
+	Key   SigningKey
+	// Components are the covered components in order, derived components
+	// such as "@method", "@target-uri" or `@query-param;name="id"`, and lower
+	// case header names such as "content-digest". Defaults to "@method" and
+	// "@target-uri".
+	Components []string
+	// Expires sets the expires parameter this long after the creation time,
+	// when it is not zero.
+	Expires time.Duration
+	// Nonce returns the nonce parameter of each signature, when it is set.
+	Nonce func() (string, error)
+	// Tag is set as the tag parameter when it is not empty.
+	Tag string
+	// Alg sets the alg parameter to the algorithm of the key.
+	Alg bool
+}
+
+// NewMessageSigner returns a Signer creating HTTP message signatures as
+// defined in RFC 9421.
+func NewMessageSigner(cfg MessageConfig) *MessageSigner {
+	if cfg.Label == "" {
+		cfg.Label = DefaultLabel
+	}
+	if len(cfg.Components) == 0 {
+		cfg.Components = []string{"@method", "@target-uri"}
+	}
+	return &MessageSigner{cfg: cfg}
+}
+
+// MessageSigner signs requests with HTTP message signatures, set in the
+// Signature-Input and Signature headers. Signatures of other labels already
+// in the headers are kept.
+type MessageSigner struct {
+	cfg MessageConfig
+}
+
+func (s *MessageSigner) Sign(req *http.Request, t time.Time) error {
+	if s.cfg.Key == nil {
+		return errors.New("message signature: missing key")
+	}
+	components, err := parseComponents(s.cfg.Components)
+	if err != nil {
+		return err
+	}
+
+	params := sfParams{{key: "created", value: t.Unix()}}
+	if s.cfg.Expires != 0 {
+		params = append(params, sfParam{key: "expires", value: t.Add(s.cfg.Expires).Unix()})
+	}
+	if s.cfg.Nonce != nil {
+		nonce, err := s.cfg.Nonce()
+		if err != nil {
+			return err
+		}
+		params = append(params, sfParam{key: "nonce", value: nonce})
+	}
+	if s.cfg.Alg {
+		params = append(params, sfParam{key: "alg", value: s.cfg.Key.Algorithm()})
+	}
+	if s.cfg.KeyID != "" {
+		params = append(params, sfParam{key: "keyid", value: s.cfg.KeyID})
+	}
+	if s.cfg.Tag != "" {
+		params = append(params, sfParam{key: "tag", value: s.cfg.Tag})
+	}
+
+	input := serializeInnerList(components, params)
+	base, err := signatureBase(message{request: req}, components, input)
+	if err != nil {
+		return err
+	}
+	signature, err := s.cfg.Key.Sign([]byte(base))
+	if err != nil {
+		return err
+	}
+
+	if err = setDictionaryMember(req.Header, signatureInputHeader, s.cfg.Label, input); err != nil {
+		return err
+	}
+	return setDictionaryMember(req.Header, signatureHeader, s.cfg.Label, ":"+base64.StdEncoding.EncodeToString(signature)+":")
+}
+
+// message is the request, or the response and the request it answers,
+// that components are taken from
+type message struct {
+	request  *http.Request
+	response *http.Response
+}
+
+// parseComponents parses component identifiers. The name may be given
+// without quotes.
+func parseComponents(identifiers []string) ([]sfItem, error) {
+	components := make([]sfItem, 0, len(identifiers))
+	seen := map[string]bool{}
+	for _, identifier := range identifiers {
+		if !strings.HasPrefix(identifier, `"`) {
+			name, params, _ := strings.Cut(identifier, ";")
+			identifier = strconv.Quote(strings.ToLower(name))
+			if params != "" {
+				identifier += ";" + params
+			}
+		}
+
+		p := &sfParser{s: identifier}
+		item, err := p.item()
+		if err == nil && !p.eof() {
+			err = p.errorf("unexpected %q", p.peek())
+		}
+		if _, ok := item.value.(string); err == nil && !ok {
+			err = errors.New("component name is not a string")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("component %s: %w", identifier, err)
+		}
+
+		key := serializeItem(item)
+		if seen[key] {
+			return nil, fmt.Errorf("component %s is covered twice", key)
+		}
+		seen[key] = true
+		components = append(components, item)
+	}
+	return components, nil
+}
+
+// signatureBase returns the signature base of the components of msg, ending
+// with the signature params line of input
+func signatureBase(msg message, components []sfItem, input string) (string, error) {
+	var b strings.Builder
+	for _, component := range components {
+		values, err := componentValues(msg, component)
+		if err != nil {
+			return "", err
+		}
+		for _, value := range values {
+			b.WriteString(serializeItem(component))
+			b.WriteString(": ")
+			b.WriteString(value)
+			b.WriteByte('\n')
+		}
+	}
+	b.WriteString(strconv.Quote(signatureParams))
+	b.WriteString(": ")
+	b.WriteString(input)
+	return b.String(), nil
+}
+
+// componentValues returns the values of a component in msg. Only the
+// @query-param component may have more than one value.
+func componentValues(msg message, component sfItem) ([]string, error) {
+	name := component.value.(string)
+	fromRequest := msg.response == nil
+	var param string
+	for _, p := range component.params {
+		switch p.key {
+		case "req":
+			fromRequest = true
+		case "name":
+			param, _ = p.value.(string)
+		default:
+			return nil, fmt.Errorf("component %s: unsupported parameter %s", name, p.key)
+		}
+	}
+
+	req := msg.request
+	if fromRequest && req == nil {
+		return nil, fmt.Errorf("component %s: no request", name)
+	}
+
+	if !strings.HasPrefix(name, "@") {
+		var value string
+		var ok bool
+		if fromRequest {
+			value, ok = requestField(req, name)
+		} else {
+			value, ok = responseField(msg.response, name)
+		}
+		if !ok {
+			return nil, fmt.Errorf("component %s is not present", name)
+		}
+		return []string{value}, nil
+	}
+
+	if name == "@status" {
+		if fromRequest {
+			return nil, fmt.Errorf("component %s: not a response", name)
+		}
+		return []string{strconv.Itoa(msg.response.StatusCode)}, nil
+	}
+
+	switch name {
+	case "@method":
+		return []string{req.Method}, nil
+	case "@target-uri":
+		return []string{requestScheme(req) + "://" + requestAuthority(req) + req.URL.RequestURI()}, nil
+	case "@authority":
+		return []string{requestAuthority(req)}, nil
+	case "@scheme":
+		return []string{requestScheme(req)}, nil
+	case "@request-target":
+		return []string{req.URL.RequestURI()}, nil
+	case "@path":
+		path := req.URL.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		return []string{path}, nil
+	case "@query":
+		return []string{"?" + req.URL.RawQuery}, nil
+	case "@query-param":
+		query, err := url.ParseQuery(req.URL.RawQuery)
+		if err != nil {
+			return nil, fmt.Errorf("component %s: %w", name, err)
+		}
+		values, ok := query[param]
+		if !ok {
+			return nil, fmt.Errorf("component %s: query parameter %q is not present", name, param)
+		}
+		encoded := make([]string, len(values))
+		for i, v := range values {
+			encoded[i] = strings.ReplaceAll(url.QueryEscape(v), "+", "%20")
+		}
+		return encoded, nil
+	}
+	return nil, fmt.Errorf("unknown component %s", name)
+}
+
+// requestField returns the values of a request header, joined by commas.
+// Host and Content-Length are not in the header of http requests.
+func requestField(req *http.Request, name string) (string, bool) {
+	switch name {
+	case "host":
+		return requestAuthority(req), true
+	case "content-length":
+		if _, ok := req.Header["Content-Length"]; !ok {
+			return strconv.FormatInt(req.ContentLength, 10), req.ContentLength > 0
+		}
+	}
+	return fieldValue(req.Header, name)
+}
+
+func responseField(resp *http.Response, name string) (string, bool) {
+	if _, ok := resp.Header["Content-Length"]; name == "content-length" && !ok {
+		return strconv.FormatInt(resp.ContentLength, 10), resp.ContentLength >= 0
+	}
+	return fieldValue(resp.Header, name)
+}
+
+func fieldValue(header http.Header, name string) (string, bool) {
+	values := header.Values(name)
+	if len(values) == 0 {
+		return "", false
+	}
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(trimmed, ", "), true
+}
+
+// requestScheme returns the scheme of a client request, or of a request
+// received by a server
+func requestScheme(req *http.Request) string {
+	if req.URL.Scheme != "" {
+		return strings.ToLower(req.URL.Scheme)
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// requestAuthority returns the host of the request in lower case, without
+// the default port of the scheme
+func requestAuthority(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	host = strings.ToLower(host)
+
+	switch scheme := requestScheme(req); {
+	case scheme == "https" && strings.HasSuffix(host, ":443"):
+		host = strings.TrimSuffix(host, ":443")
+	case scheme == "http" && strings.HasSuffix(host, ":80"):
+		host = strings.TrimSuffix(host, ":80")
+	}
+	return host
+}
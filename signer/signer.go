@@ -0,0 +1,167 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/SirWaithaka/gorequest"
+)
+
+// Signer signs http requests.
+type Signer interface {
+	// Sign signs req as of t. The body of req is replayable, Sign reads it
+	// from GetBody and may replace it, for example with a signed stream.
+	Sign(req *http.Request, t time.Time) error
+}
+
+// PayloadMode is how the request body is covered by the signature.
+type PayloadMode uint
+
+const (
+	// PayloadSigned includes the SHA-256 hash of the body in the signature.
+	PayloadSigned PayloadMode = iota
+	// PayloadUnsigned leaves the body out of the signature, so that it does
+	// not have to be hashed before sending.
+	PayloadUnsigned
+	// PayloadStreaming signs the body in chunks as it is sent, each chunk
+	// signature chained to the previous one.
+	PayloadStreaming
+)
+
+// New returns a Signing whose hooks sign requests with signer.
+func New(signer Signer) Signing {
+	return Signing{signer: signer}
+}
+
+// Signing provides the hooks that sign requests. A request is signed as the
+// last build hook and signed again before every retry, since the timestamp of
+// the previous signature may be stale by then, and after re-authentication,
+// since the reauth hooks replace the credentials the signature covers.
+// Headers set by send hooks, such as the Authorization header of auth.Digest,
+// are not covered by the signature.
+type Signing struct {
+	signer Signer
+}
+
+type stateKey struct{}
+
+// state holds the body of the request as it was before it was signed, since
+// signing may replace it
+type state struct {
+	getBody         func() (io.ReadCloser, error)
+	contentLength   int64
+	contentEncoding []string
+}
+
+// Apply registers the signing hooks on hooks. Apply it after the other hooks,
+// so that the sign hook is the last build hook and the resign hook the last
+// retry and reauth hook.
+func (s Signing) Apply(hooks *gorequest.Hooks) {
+	hooks.Build.PushBackHook(s.Sign())
+	hooks.Retry.PushBackHook(s.Resign())
+	hooks.Reauth.PushBackHook(s.Resign())
+}
+
+// Sign returns a build hook that signs the request. It must be the last build
+// hook, since changes to the request after signing invalidate the signature.
+func (s Signing) Sign() gorequest.Hook {
+	return gorequest.Hook{Name: "signer.Sign", Fn: func(r *gorequest.Request) {
+		st, err := replayable(r.Request)
+		if err != nil {
+			r.Error = err
+			return
+		}
+		r.WithContext(context.WithValue(r.Context(), stateKey{}, st))
+
+		if err = s.signer.Sign(r.Request, time.Now()); err != nil {
+			r.Error = fmt.Errorf("signer: %w", err)
+		}
+	}}
+}
+
+// Resign returns a retry or reauth hook that signs the request again with the
+// current time. Register it as the last retry hook, after any hook waiting
+// before the retry, and as the last reauth hook, after the hooks renewing the
+// credentials.
+func (s Signing) Resign() gorequest.Hook {
+	return gorequest.Hook{Name: "signer.Resign", Fn: func(r *gorequest.Request) {
+		st, ok := r.Context().Value(stateKey{}).(*state)
+		if !ok {
+			return
+		}
+
+		// restore the body and headers the signer may have replaced. The body
+		// itself is replaced from GetBody when the retry is prepared.
+		r.Request.GetBody = st.getBody
+		r.Request.ContentLength = st.contentLength
+		if st.contentEncoding == nil {
+			r.Request.Header.Del("Content-Encoding")
+		} else {
+			r.Request.Header["Content-Encoding"] = st.contentEncoding
+		}
+
+		if err := s.signer.Sign(r.Request, time.Now()); err != nil {
+			r.StopRetry(fmt.Errorf("signer: %w", err))
+		}
+	}}
+}
+
+// replayable makes the request body replayable, reading it into memory when
+// it has no GetBody, and returns the state to restore it from.
+func replayable(req *http.Request) (*state, error) {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		data, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = int64(len(data))
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+
+	return &state{
+		getBody:         req.GetBody,
+		contentLength:   req.ContentLength,
+		contentEncoding: req.Header.Values("Content-Encoding"),
+	}, nil
+}
+
+// emptyHash is the hex SHA-256 of an empty payload
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// payloadHash returns the hex SHA-256 of the request body
+func payloadHash(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return emptyHash, nil
+	}
+
+	body, err := bodyReader(req)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// bodyReader returns a new reader of the request body, leaving the body of
+// the request unread
+func bodyReader(req *http.Request) (io.ReadCloser, error) {
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("request body of %s %s is not replayable", req.Method, req.URL)
+	}
+	return req.GetBody()
+}
@@ -0,0 +1,303 @@
+package signer
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SirWaithaka/gorequest/credentials"
+)
+
+const (
+	sigV4Algorithm        = "AWS4-HMAC-SHA256"
+	sigV4ChunkAlgorithm   = "AWS4-HMAC-SHA256-PAYLOAD"
+	sigV4TimeFormat       = "20060102T150405Z"
+	sigV4DateFormat       = "20060102"
+	unsignedPayload       = "UNSIGNED-PAYLOAD"
+	streamingPayload      = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	defaultChunkSize      = 64 << 10
+	chunkSignatureLiteral = ";chunk-signature="
+)
+
+// sigV4IgnoredHeaders are not signed, since proxies and transports may
+// change them
+var sigV4IgnoredHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+}
+
+// Credentials are the keys a request is signed with.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is the token of temporary credentials. It is sent in the
+	// X-Amz-Security-Token header.
+	SessionToken string
+}
+
+// SigV4Config configures a SigV4 signer.
+type SigV4Config struct {
+	Credentials Credentials
+	// Provider provides the credentials instead of Credentials, retrieved
+	// every time a request is signed so that rotated keys are used by
+	// retries. The ID and Secret are the access key id and secret access key.
+	Provider credentials.Provider
+	Region   string
+	Service  string
+	// Payload is how the body is signed. Defaults to PayloadSigned.
+	Payload PayloadMode
+	// ContentSHA256Header sets the X-Amz-Content-Sha256 header to the payload
+	// hash, as S3 requires. The header is always set for unsigned and
+	// streaming payloads.
+	ContentSHA256Header bool
+	// DisableURIPathEscaping signs the path as it is escaped in the url,
+	// instead of escaping it a second time. S3 requires it.
+	DisableURIPathEscaping bool
+	// ChunkSize is the size of the chunks of a streaming payload. Defaults to
+	// 64KiB.
+	ChunkSize int
+}
+
+// NewSigV4 returns a Signer implementing AWS Signature Version 4.
+func NewSigV4(cfg SigV4Config) *SigV4 {
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = defaultChunkSize
+	}
+	return &SigV4{cfg: cfg}
+}
+
+// SigV4 signs requests with AWS Signature Version 4. The signature is in the
+// Authorization header and the signing time in the X-Amz-Date header.
+type SigV4 struct {
+	cfg SigV4Config
+}
+
+func (s *SigV4) Sign(req *http.Request, t time.Time) error {
+	creds := s.cfg.Credentials
+	if s.cfg.Provider != nil {
+		provided, err := s.cfg.Provider.Retrieve(req.Context())
+		if err != nil {
+			return err
+		}
+		creds = Credentials{AccessKeyID: provided.ID, SecretAccessKey: provided.Secret, SessionToken: provided.Token}
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return errors.New("sigv4: missing credentials")
+	}
+
+	t = t.UTC()
+	amzDate := t.Format(sigV4TimeFormat)
+	scope := strings.Join([]string{t.Format(sigV4DateFormat), s.cfg.Region, s.cfg.Service, "aws4_request"}, "/")
+
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	var hash string
+	var decodedLength int64
+	switch s.cfg.Payload {
+	case PayloadUnsigned:
+		hash = unsignedPayload
+	case PayloadStreaming:
+		hash = streamingPayload
+		var err error
+		if decodedLength, err = s.prepareStreaming(req); err != nil {
+			return err
+		}
+	default:
+		var err error
+		if hash, err = payloadHash(req); err != nil {
+			return err
+		}
+	}
+	if s.cfg.ContentSHA256Header || s.cfg.Payload != PayloadSigned {
+		req.Header.Set("X-Amz-Content-Sha256", hash)
+	}
+
+	signedHeaders := sigV4SignedHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL, !s.cfg.DisableURIPathEscaping),
+		canonicalQuery(req.URL),
+		canonicalHeaders(req, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		hash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+	key := s.signingKey(creds.SecretAccessKey, t)
+	signature := hex.EncodeToString(hmacSHA256(key, []byte(stringToSign)))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.AccessKeyID, scope, strings.Join(signedHeaders, ";"), signature))
+
+	if s.cfg.Payload == PayloadStreaming {
+		getBody := req.GetBody
+		newBody := func() (io.ReadCloser, error) {
+			src, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return &chunkReader{
+				src: src, chunk: make([]byte, s.cfg.ChunkSize),
+				key: key, amzDate: amzDate, scope: scope, prevSignature: signature,
+			}, nil
+		}
+		body, err := newBody()
+		if err != nil {
+			return err
+		}
+		req.Body = body
+		req.GetBody = newBody
+		req.ContentLength = chunkedLength(decodedLength, int64(s.cfg.ChunkSize))
+	}
+	return nil
+}
+
+// prepareStreaming sets the headers of a streaming payload and returns the
+// length of the body
+func (s *SigV4) prepareStreaming(req *http.Request) (int64, error) {
+	length := req.ContentLength
+	if length < 0 || (length == 0 && req.Body != nil && req.Body != http.NoBody) {
+		body, err := bodyReader(req)
+		if err != nil {
+			return 0, err
+		}
+		length, err = io.Copy(io.Discard, body)
+		_ = body.Close()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if req.GetBody == nil {
+		req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+	}
+
+	encoding := "aws-chunked"
+	if existing := req.Header.Values("Content-Encoding"); len(existing) > 0 {
+		encoding += "," + strings.Join(existing, ",")
+	}
+	req.Header.Set("Content-Encoding", encoding)
+	req.Header.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(length, 10))
+	req.ContentLength = chunkedLength(length, int64(s.cfg.ChunkSize))
+	return length, nil
+}
+
+func (s *SigV4) signingKey(secret string, t time.Time) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), []byte(t.Format(sigV4DateFormat)))
+	key = hmacSHA256(key, []byte(s.cfg.Region))
+	key = hmacSHA256(key, []byte(s.cfg.Service))
+	return hmacSHA256(key, []byte("aws4_request"))
+}
+
+// sigV4SignedHeaders returns the sorted lower case names of the headers to
+// sign: the host, the content length of a request with a body, and every
+// header except the ignored ones
+func sigV4SignedHeaders(req *http.Request) []string {
+	names := []string{"host"}
+	if req.ContentLength > 0 {
+		names = append(names, "content-length")
+	}
+	for name := range req.Header {
+		name = strings.ToLower(name)
+		if sigV4IgnoredHeaders[name] || name == "host" || name == "content-length" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// chunkedLength returns the length of a streaming payload encoding length
+// bytes of data in chunks of chunkSize
+func chunkedLength(length, chunkSize int64) int64 {
+	chunkLength := func(n int64) int64 {
+		return int64(len(strconv.FormatInt(n, 16))) + int64(len(chunkSignatureLiteral)) + 64 + 2 + n + 2
+	}
+
+	full := length / chunkSize
+	total := full * chunkLength(chunkSize)
+	if rest := length % chunkSize; rest > 0 {
+		total += chunkLength(rest)
+	}
+	// the final empty chunk
+	return total + chunkLength(0)
+}
+
+// chunkReader encodes a body as a streaming payload, signing each chunk with
+// the signature of the previous one
+type chunkReader struct {
+	src   io.ReadCloser
+	chunk []byte
+	buf   bytes.Buffer
+	done  bool
+
+	key           []byte
+	amzDate       string
+	scope         string
+	prevSignature string
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for c.buf.Len() == 0 && !c.done {
+		n, err := io.ReadFull(c.src, c.chunk)
+		if n > 0 {
+			c.writeChunk(c.chunk[:n])
+		}
+		switch {
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			c.writeChunk(nil)
+			c.done = true
+		case err != nil:
+			return 0, err
+		}
+	}
+	if c.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.buf.Read(p)
+}
+
+func (c *chunkReader) writeChunk(data []byte) {
+	stringToSign := strings.Join([]string{
+		sigV4ChunkAlgorithm, c.amzDate, c.scope, c.prevSignature, emptyHash, hashHex(data),
+	}, "\n")
+	signature := hex.EncodeToString(hmacSHA256(c.key, []byte(stringToSign)))
+	c.prevSignature = signature
+
+	c.buf.WriteString(strconv.FormatInt(int64(len(data)), 16))
+	c.buf.WriteString(chunkSignatureLiteral)
+	c.buf.WriteString(signature)
+	c.buf.WriteString("\r\n")
+	c.buf.Write(data)
+	c.buf.WriteString("\r\n")
+}
+
+func (c *chunkReader) Close() error {
+	return c.src.Close()
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
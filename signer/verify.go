@@ -0,0 +1,196 @@
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SirWaithaka/gorequest"
+)
+
+var (
+	// ErrMissingSignature is the error of a message without the signature to
+	// verify.
+	ErrMissingSignature = errors.New("signer: missing signature")
+	// ErrInvalidSignature is the error of a message whose signature does not
+	// verify or does not meet the requirements of the verifier.
+	ErrInvalidSignature = errors.New("signer: invalid signature")
+)
+
+// VerifierConfig configures a verifier of HTTP message signatures.
+type VerifierConfig struct {
+	// Label is the name of the signature to verify. Defaults to the first
+	// signature of the message.
+	Label string
+	// Key returns the key of a signature from its keyid and alg parameters,
+	// which are empty when the signature does not have them.
+	Key func(keyID, alg string) (VerifyingKey, error)
+	// Required are the components the signature must cover, in the form of
+	// MessageConfig.Components.
+	Required []string
+	// MaxAge rejects signatures created longer ago, when it is not zero.
+	MaxAge time.Duration
+	// Tag, when it is not empty, must be the tag parameter of the signature.
+	Tag string
+}
+
+// NewVerifier returns a verifier of HTTP message signatures as defined in
+// RFC 9421.
+func NewVerifier(cfg VerifierConfig) *Verifier {
+	return &Verifier{cfg: cfg}
+}
+
+// Verifier verifies the HTTP message signatures of responses and requests.
+type Verifier struct {
+	cfg VerifierConfig
+}
+
+// Apply registers the verify hook on hooks.
+func (v *Verifier) Apply(hooks *gorequest.Hooks) {
+	hooks.Unmarshal.PushFrontHook(v.Verify())
+}
+
+// Verify returns an unmarshal hook that verifies the signature of the
+// response before it is unmarshalled. Responses that fail verification set
+// an error wrapping ErrMissingSignature or ErrInvalidSignature.
+func (v *Verifier) Verify() gorequest.Hook {
+	return gorequest.Hook{Name: "signer.Verify", Fn: func(r *gorequest.Request) {
+		if r.Error != nil || r.Response == nil {
+			return
+		}
+
+		resp := r.Response
+		if resp.Request == nil {
+			// components of the request are taken from the request of the
+			// response
+			copied := *resp
+			copied.Request = r.Request
+			resp = &copied
+		}
+		if err := v.VerifyResponse(resp); err != nil {
+			r.Error = err
+		}
+	}}
+}
+
+// VerifyResponse verifies the signature of resp. Components with the req
+// parameter are taken from resp.Request.
+func (v *Verifier) VerifyResponse(resp *http.Response) error {
+	return v.verify(resp.Header, message{request: resp.Request, response: resp})
+}
+
+// VerifyRequest verifies the signature of req.
+func (v *Verifier) VerifyRequest(req *http.Request) error {
+	return v.verify(req.Header, message{request: req})
+}
+
+func (v *Verifier) verify(header http.Header, msg message) error {
+	inputs, err := headerDictionary(header, signatureInputHeader)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidSignature, signatureInputHeader, err)
+	}
+	signatures, err := headerDictionary(header, signatureHeader)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidSignature, signatureHeader, err)
+	}
+
+	label := v.cfg.Label
+	if label == "" && len(inputs) > 0 {
+		label = inputs[0].key
+	}
+	input, ok := findMember(inputs, label)
+	if !ok {
+		return ErrMissingSignature
+	}
+	signatureMember, ok := findMember(signatures, label)
+	if !ok {
+		return ErrMissingSignature
+	}
+	signature, ok := signatureMember.item.value.([]byte)
+	if !input.isList || !ok {
+		return fmt.Errorf("%w: malformed signature %s", ErrInvalidSignature, label)
+	}
+
+	if err = v.checkParams(input); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	keyID, _ := stringParam(input.item.params, "keyid")
+	alg, _ := stringParam(input.item.params, "alg")
+	if v.cfg.Key == nil {
+		return fmt.Errorf("%w: no key for keyid %q", ErrInvalidSignature, keyID)
+	}
+	key, err := v.cfg.Key(keyID, alg)
+	if err != nil {
+		return fmt.Errorf("%w: key: %v", ErrInvalidSignature, err)
+	}
+	if alg != "" && alg != key.Algorithm() {
+		return fmt.Errorf("%w: algorithm %s does not match the key", ErrInvalidSignature, alg)
+	}
+
+	base, err := signatureBase(msg, input.inner, serializeInnerList(input.inner, input.item.params))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if err = key.Verify([]byte(base), signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return nil
+}
+
+// checkParams checks the covered components and the parameters of a
+// signature against the requirements of the verifier
+func (v *Verifier) checkParams(input sfMember) error {
+	required, err := parseComponents(v.cfg.Required)
+	if err != nil {
+		return err
+	}
+	covered := map[string]bool{}
+	for _, component := range input.inner {
+		if _, ok := component.value.(string); !ok {
+			return errors.New("component name is not a string")
+		}
+		covered[serializeItem(component)] = true
+	}
+	for _, component := range required {
+		if !covered[serializeItem(component)] {
+			return fmt.Errorf("component %s is not covered", serializeItem(component))
+		}
+	}
+
+	now := time.Now()
+	if expires, ok := input.item.params.get("expires"); ok {
+		if n, ok := expires.(int64); !ok || !now.Before(time.Unix(n, 0)) {
+			return errors.New("signature expired")
+		}
+	}
+	if v.cfg.MaxAge != 0 {
+		created, ok := input.item.params.get("created")
+		n, isInt := created.(int64)
+		if !ok || !isInt || now.Sub(time.Unix(n, 0)) > v.cfg.MaxAge {
+			return errors.New("signature is too old")
+		}
+	}
+	if v.cfg.Tag != "" {
+		if tag, _ := stringParam(input.item.params, "tag"); tag != v.cfg.Tag {
+			return fmt.Errorf("tag %q does not match", tag)
+		}
+	}
+	return nil
+}
+
+func findMember(members []sfMember, key string) (sfMember, bool) {
+	for _, member := range members {
+		if member.key == key {
+			return member, true
+		}
+	}
+	return sfMember{}, false
+}
+
+func stringParam(params sfParams, key string) (string, bool) {
+	value, ok := params.get(key)
+	s, isString := value.(string)
+	return s, ok && isString
+}
@@ -0,0 +1,161 @@
+package gorequest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AttemptTiming is the connection timing breakdown of a single attempt to send
+// a request. It is recorded by a hook tracing the http request into the
+// TimingRecorder of the request, which is reset at the start of each attempt.
+type AttemptTiming struct {
+	// Start is the time the attempt asked the transport for a connection
+	Start time.Time
+	// DNS is the time spent resolving the host
+	DNS time.Duration
+	// Connect is the time spent establishing the TCP connection
+	Connect time.Duration
+	// TLSHandshake is the time spent on the TLS handshake
+	TLSHandshake time.Duration
+	// TLSHandshakeErr is the error the TLS handshake failed with
+	TLSHandshakeErr error
+	// GotConn is the time from Start until a connection was obtained
+	GotConn time.Duration
+	// ServerProcessing is the time from writing the request until the first
+	// response byte was received
+	ServerProcessing time.Duration
+	// TimeToFirstByte is the time from Start until the first response byte
+	// was received
+	TimeToFirstByte time.Duration
+	// ConnReused is true if the connection was reused from a previous request
+	ConnReused bool
+	// ConnWasIdle is true if the reused connection was idle in the pool
+	ConnWasIdle bool
+	// ConnIdleTime is how long the reused connection was idle
+	ConnIdleTime time.Duration
+
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+}
+
+// IsZero returns true if no timing was recorded
+func (t AttemptTiming) IsZero() bool {
+	return t.Start.IsZero()
+}
+
+func (t AttemptTiming) String() string {
+	if t.IsZero() {
+		return "no timing"
+	}
+	s := fmt.Sprintf("dns=%s connect=%s tls=%s server=%s ttfb=%s reused=%t",
+		t.DNS, t.Connect, t.TLSHandshake, t.ServerProcessing, t.TimeToFirstByte, t.ConnReused)
+	if t.TLSHandshakeErr != nil {
+		s += fmt.Sprintf(" tls_error=%q", t.TLSHandshakeErr.Error())
+	}
+	return s
+}
+
+// TimingRecorder records the AttemptTiming of the current attempt of a
+// request. The transport calls the trace hooks from its own goroutines, so
+// the methods of a recorder are safe for concurrent use.
+type TimingRecorder struct {
+	mu     sync.Mutex
+	timing AttemptTiming
+}
+
+// Timing returns the timing recorded for the current attempt.
+func (t *TimingRecorder) Timing() AttemptTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timing
+}
+
+// reset clears the timing at the start of an attempt
+func (t *TimingRecorder) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timing = AttemptTiming{}
+}
+
+func (t *TimingRecorder) record(fn func(timing *AttemptTiming)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fn(&t.timing)
+}
+
+// GetConn marks the time the attempt asked the transport for a connection
+func (t *TimingRecorder) GetConn(now time.Time) {
+	t.record(func(timing *AttemptTiming) { timing.Start = now })
+}
+
+// DNSStart marks the start of the DNS lookup
+func (t *TimingRecorder) DNSStart(now time.Time) {
+	t.record(func(timing *AttemptTiming) { timing.dnsStart = now })
+}
+
+// DNSDone marks the end of the DNS lookup
+func (t *TimingRecorder) DNSDone(now time.Time) {
+	t.record(func(timing *AttemptTiming) { timing.DNS = since(timing.dnsStart, now) })
+}
+
+// ConnectStart marks the start of dialing a connection. With multiple dial
+// attempts the first start is kept.
+func (t *TimingRecorder) ConnectStart(now time.Time) {
+	t.record(func(timing *AttemptTiming) {
+		if timing.connectStart.IsZero() {
+			timing.connectStart = now
+		}
+	})
+}
+
+// ConnectDone marks the end of dialing a connection
+func (t *TimingRecorder) ConnectDone(now time.Time) {
+	t.record(func(timing *AttemptTiming) { timing.Connect = since(timing.connectStart, now) })
+}
+
+// TLSHandshakeStart marks the start of the TLS handshake
+func (t *TimingRecorder) TLSHandshakeStart(now time.Time) {
+	t.record(func(timing *AttemptTiming) { timing.tlsStart = now })
+}
+
+// TLSHandshakeDone marks the end of the TLS handshake, and the error it
+// failed with if any
+func (t *TimingRecorder) TLSHandshakeDone(now time.Time, err error) {
+	t.record(func(timing *AttemptTiming) {
+		timing.TLSHandshake = since(timing.tlsStart, now)
+		timing.TLSHandshakeErr = err
+	})
+}
+
+// GotConnection records that a connection was obtained and if it was reused
+func (t *TimingRecorder) GotConnection(now time.Time, reused, wasIdle bool, idleTime time.Duration) {
+	t.record(func(timing *AttemptTiming) {
+		timing.GotConn = since(timing.Start, now)
+		timing.ConnReused = reused
+		timing.ConnWasIdle = wasIdle
+		timing.ConnIdleTime = idleTime
+	})
+}
+
+// WroteRequest marks the time the request was fully written
+func (t *TimingRecorder) WroteRequest(now time.Time) {
+	t.record(func(timing *AttemptTiming) { timing.wroteRequest = now })
+}
+
+// GotFirstResponseByte marks the time the first response byte was received
+func (t *TimingRecorder) GotFirstResponseByte(now time.Time) {
+	t.record(func(timing *AttemptTiming) {
+		timing.TimeToFirstByte = since(timing.Start, now)
+		timing.ServerProcessing = since(timing.wroteRequest, now)
+	})
+}
+
+func since(start, now time.Time) time.Duration {
+	if start.IsZero() {
+		return 0
+	}
+	return now.Sub(start)
+}
@@ -0,0 +1,311 @@
+package gorequest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModernCipherSuites are the TLS 1.2 cipher suites with forward secrecy and
+// authenticated encryption. TLS 1.3 suites are not configurable.
+var ModernCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// TLSConfig configures the TLS connections of a Config. The library builds
+// an http.Transport from it, shared by all the requests with the same
+// configuration.
+type TLSConfig struct {
+	// CertFile and KeyFile are the PEM files of the client certificate and
+	// key for mutual TLS. The files are loaded again when they change, so
+	// rotated certificates are used for new connections.
+	CertFile string
+	KeyFile  string
+	// CertPEM and KeyPEM are the PEM client certificate and key, when they
+	// are not loaded from files.
+	CertPEM []byte
+	KeyPEM  []byte
+
+	// RootCAFiles and RootCAPEM are the PEM certificates of the authorities
+	// trusted to verify servers, instead of the system roots.
+	RootCAFiles []string
+	RootCAPEM   []byte
+
+	// MinVersion is the minimum TLS version, such as tls.VersionTLS13.
+	// Defaults to tls.VersionTLS12.
+	MinVersion uint16
+	// CipherSuites are the TLS 1.2 cipher suites allowed, for example
+	// ModernCipherSuites. Defaults to the suites of crypto/tls.
+	CipherSuites []uint16
+
+	// ServerName overrides the name sent for SNI and verified against the
+	// server certificate, which defaults to the host of the request.
+	ServerName string
+
+	// PinnedSPKI are the base64 SHA-256 hashes of the SubjectPublicKeyInfo
+	// of certificates. When set, the certificate chain of the server must
+	// contain one of them.
+	PinnedSPKI []string
+}
+
+// CertificatePinError is the error of a connection to a server whose
+// certificate chain has no pinned public key. It is classified as
+// ErrorKindTLS.
+type CertificatePinError struct {
+	ServerName string
+}
+
+func (e *CertificatePinError) Error() string {
+	return fmt.Sprintf("tls: no certificate of %s matches a pinned public key", e.ServerName)
+}
+
+// ErrorKind classifies the error as a TLS failure
+func (e *CertificatePinError) ErrorKind() ErrorKind {
+	return ErrorKindTLS
+}
+
+// ClientConfig returns the tls.Config of c, for use with a custom transport.
+func (c *TLSConfig) ClientConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:   c.MinVersion,
+		CipherSuites: c.CipherSuites,
+		ServerName:   c.ServerName,
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+
+	switch {
+	case c.CertFile != "" || c.KeyFile != "":
+		reloader := &certReloader{certFile: c.CertFile, keyFile: c.KeyFile}
+		if _, err := reloader.certificate(); err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return reloader.certificate()
+		}
+	case len(c.CertPEM) > 0 || len(c.KeyPEM) > 0:
+		cert, err := tls.X509KeyPair(c.CertPEM, c.KeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("tls: loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(c.RootCAFiles) > 0 || len(c.RootCAPEM) > 0 {
+		pool := x509.NewCertPool()
+		for _, file := range c.RootCAFiles {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("tls: loading root CAs: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("tls: no certificates in %s", file)
+			}
+		}
+		if len(c.RootCAPEM) > 0 && !pool.AppendCertsFromPEM(c.RootCAPEM) {
+			return nil, errors.New("tls: no certificates in RootCAPEM")
+		}
+		cfg.RootCAs = pool
+	}
+
+	if len(c.PinnedSPKI) > 0 {
+		pins := make(map[[sha256.Size]byte]bool, len(c.PinnedSPKI))
+		for _, pin := range c.PinnedSPKI {
+			hash, err := base64.StdEncoding.DecodeString(pin)
+			if err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("tls: invalid SPKI pin %q", pin)
+			}
+			pins[[sha256.Size]byte(hash)] = true
+		}
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPins(cs, pins)
+		}
+	}
+
+	return cfg, nil
+}
+
+// verifyPins returns an error unless a certificate of the verified chains
+// has a pinned public key
+func verifyPins(cs tls.ConnectionState, pins map[[sha256.Size]byte]bool) error {
+	for _, chain := range cs.VerifiedChains {
+		for _, cert := range chain {
+			if pins[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+				return nil
+			}
+		}
+	}
+	return &CertificatePinError{ServerName: cs.ServerName}
+}
+
+// certReloader loads a client certificate from files, again whenever the
+// files change
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	version string
+}
+
+func (l *certReloader) certificate() (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	version, err := fileVersion(l.certFile, l.keyFile)
+	if err == nil && version == l.version {
+		return l.cert, nil
+	}
+	if err == nil {
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(l.certFile, l.keyFile); err == nil {
+			l.cert, l.version = &cert, version
+			return l.cert, nil
+		}
+	}
+
+	// a certificate being rotated may be unreadable for a moment, the
+	// previous one is used until the new one loads
+	if l.cert != nil {
+		return l.cert, nil
+	}
+	return nil, fmt.Errorf("tls: loading client certificate: %w", err)
+}
+
+// fileVersion returns a string that changes when one of the files changes
+func fileVersion(files ...string) (string, error) {
+	var b strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(info.ModTime().Format(time.RFC3339Nano))
+		b.WriteByte('/')
+		b.WriteString(strconv.FormatInt(info.Size(), 10))
+		b.WriteByte(';')
+	}
+	return b.String(), nil
+}
+
+// maxTransports is the number of transports built for TLS configurations
+// that are kept for reuse
+const maxTransports = 32
+
+// transports caches the transports built for TLS configurations, keyed by
+// their base transport and configuration. The least recently used transport
+// is dropped once the cache is full, such as when certificates are rotated.
+var transports = struct {
+	sync.Mutex
+	m     map[transportKey]*http.Transport
+	order []transportKey
+}{m: map[transportKey]*http.Transport{}}
+
+// transportKey identifies a transport built from base for a configuration.
+// The key keeps base alive, so that its address is not reused by another
+// transport while it is cached.
+type transportKey struct {
+	base        *http.Transport
+	fingerprint string
+}
+
+// tlsClient returns a copy of client sending requests with a transport
+// configured by c. The transport is a clone of the transport of client, or
+// of http.DefaultTransport.
+func tlsClient(client *http.Client, c *TLSConfig) (*http.Client, error) {
+	var transport http.RoundTripper = http.DefaultTransport
+	copied := &http.Client{}
+	if client != nil {
+		*copied = *client
+		if client.Transport != nil {
+			transport = client.Transport
+		}
+	}
+	base, ok := transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("tls: the transport %T of the http client is not an *http.Transport", transport)
+	}
+
+	key := transportKey{base: base, fingerprint: c.fingerprint()}
+	transports.Lock()
+	defer transports.Unlock()
+
+	tlsTransport, ok := transports.m[key]
+	if !ok {
+		cfg, err := c.ClientConfig()
+		if err != nil {
+			return nil, err
+		}
+		tlsTransport = base.Clone()
+		tlsTransport.TLSClientConfig = cfg
+		cacheTransport(key, tlsTransport)
+	} else {
+		useTransport(key)
+	}
+	copied.Transport = tlsTransport
+	return copied, nil
+}
+
+// cacheTransport adds transport to the cache, dropping the least recently
+// used one when the cache is full. transports must be locked.
+func cacheTransport(key transportKey, transport *http.Transport) {
+	if len(transports.order) >= maxTransports {
+		oldest := transports.order[0]
+		transports.order = transports.order[1:]
+		// clients still using the dropped transport keep working, its idle
+		// connections are closed
+		transports.m[oldest].CloseIdleConnections()
+		delete(transports.m, oldest)
+	}
+	transports.m[key] = transport
+	transports.order = append(transports.order, key)
+}
+
+// useTransport marks the cached transport as the most recently used.
+// transports must be locked.
+func useTransport(key transportKey) {
+	i := slices.Index(transports.order, key)
+	transports.order = append(slices.Delete(transports.order, i, i+1), key)
+}
+
+// fingerprint identifies the configuration, so that equal configurations
+// share a transport
+func (c *TLSConfig) fingerprint() string {
+	hash := func(data []byte) string {
+		if len(data) == 0 {
+			return ""
+		}
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+
+	var b bytes.Buffer
+	for _, field := range []string{
+		c.CertFile, c.KeyFile, hash(c.CertPEM), hash(c.KeyPEM),
+		strings.Join(c.RootCAFiles, ","), hash(c.RootCAPEM),
+		strconv.Itoa(int(c.MinVersion)), fmt.Sprint(c.CipherSuites),
+		c.ServerName, strings.Join(c.PinnedSPKI, ","),
+	} {
+		b.WriteString(field)
+		b.WriteByte(0)
+	}
+	return hash(b.Bytes())
+}
@@ -0,0 +1,289 @@
+package gorequest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCA issues certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM certificate and key of a client certificate
+func (ca *testCA) issue(t *testing.T, commonName string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// newTLSServer returns a server responding with the common name of the client
+// certificate and the server name of the connection
+func newTLSServer(t *testing.T, configure func(cfg *tls.Config)) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Header().Set("X-Client", r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+		w.Header().Set("X-Server-Name", r.TLS.ServerName)
+	}))
+	// failed handshakes are expected
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.TLS = &tls.Config{}
+	if configure != nil {
+		configure(server.TLS)
+	}
+	server.StartTLS()
+	return server
+}
+
+func serverCAPEM(server *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+}
+
+func sendTLS(endpoint string, cfg *TLSConfig) (*Request, error) {
+	var hooks Hooks
+	hooks.Send.PushBack(func(r *Request) {
+		r.Response, r.Error = r.Config.HTTPClient.Do(r.Request)
+	})
+	req := New(Config{Endpoint: endpoint, TLS: cfg}, Operation{Method: http.MethodGet}, hooks, nil, nil, nil)
+	return req, req.Send()
+}
+
+func TestTLSConfig(t *testing.T) {
+
+	t.Run("test that servers are verified with the root CAs", func(t *testing.T) {
+		server := newTLSServer(t, nil)
+		defer server.Close()
+
+		_, err := sendTLS(server.URL, &TLSConfig{})
+		assert.Equal(t, ErrorKindTLS, ClassifyError(err))
+
+		_, err = sendTLS(server.URL, &TLSConfig{RootCAPEM: serverCAPEM(server)})
+		assert.NoError(t, err)
+
+		file := filepath.Join(t.TempDir(), "ca.pem")
+		assert.NoError(t, os.WriteFile(file, serverCAPEM(server), 0o600))
+		_, err = sendTLS(server.URL, &TLSConfig{RootCAFiles: []string{file}})
+		assert.NoError(t, err)
+	})
+
+	t.Run("test that client certificates are sent", func(t *testing.T) {
+		ca := newTestCA(t)
+		pool := x509.NewCertPool()
+		pool.AddCert(ca.cert)
+		server := newTLSServer(t, func(cfg *tls.Config) {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			cfg.ClientCAs = pool
+		})
+		defer server.Close()
+
+		certPEM, keyPEM := ca.issue(t, "client")
+		req, err := sendTLS(server.URL, &TLSConfig{RootCAPEM: serverCAPEM(server), CertPEM: certPEM, KeyPEM: keyPEM})
+		assert.NoError(t, err)
+		assert.Equal(t, "client", req.Response.Header.Get("X-Client"))
+	})
+
+	t.Run("test that client certificate files are reloaded when they change", func(t *testing.T) {
+		ca := newTestCA(t)
+		pool := x509.NewCertPool()
+		pool.AddCert(ca.cert)
+		server := newTLSServer(t, func(cfg *tls.Config) {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			cfg.ClientCAs = pool
+		})
+		defer server.Close()
+
+		dir := t.TempDir()
+		cfg := &TLSConfig{RootCAPEM: serverCAPEM(server), CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")}
+		write := func(commonName string, modTime time.Time) {
+			certPEM, keyPEM := ca.issue(t, commonName)
+			assert.NoError(t, os.WriteFile(cfg.CertFile, certPEM, 0o600))
+			assert.NoError(t, os.WriteFile(cfg.KeyFile, keyPEM, 0o600))
+			assert.NoError(t, os.Chtimes(cfg.CertFile, modTime, modTime))
+			assert.NoError(t, os.Chtimes(cfg.KeyFile, modTime, modTime))
+		}
+
+		write("client-1", time.Now().Add(-time.Minute))
+		req, err := sendTLS(server.URL, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, "client-1", req.Response.Header.Get("X-Client"))
+
+		// the rotated certificate is used for new connections
+		write("client-2", time.Now())
+		server.CloseClientConnections()
+		req, err = sendTLS(server.URL, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, "client-2", req.Response.Header.Get("X-Client"))
+	})
+
+	t.Run("test that the minimum version is enforced", func(t *testing.T) {
+		server := newTLSServer(t, func(cfg *tls.Config) {
+			cfg.MaxVersion = tls.VersionTLS12
+		})
+		defer server.Close()
+
+		_, err := sendTLS(server.URL, &TLSConfig{RootCAPEM: serverCAPEM(server)})
+		assert.NoError(t, err)
+		_, err = sendTLS(server.URL, &TLSConfig{RootCAPEM: serverCAPEM(server), MinVersion: tls.VersionTLS13})
+		assert.Equal(t, ErrorKindTLS, ClassifyError(err))
+	})
+
+	t.Run("test that the cipher suites are enforced", func(t *testing.T) {
+		server := newTLSServer(t, func(cfg *tls.Config) {
+			cfg.MaxVersion = tls.VersionTLS12
+			cfg.CipherSuites = []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA}
+		})
+		defer server.Close()
+
+		_, err := sendTLS(server.URL, &TLSConfig{RootCAPEM: serverCAPEM(server), CipherSuites: ModernCipherSuites})
+		assert.Equal(t, ErrorKindTLS, ClassifyError(err))
+	})
+
+	t.Run("test that the server name is overridden", func(t *testing.T) {
+		server := newTLSServer(t, nil)
+		defer server.Close()
+
+		// the certificate of the test server is valid for example.com
+		req, err := sendTLS(server.URL, &TLSConfig{RootCAPEM: serverCAPEM(server), ServerName: "example.com"})
+		assert.NoError(t, err)
+		assert.Equal(t, "example.com", req.Response.Header.Get("X-Server-Name"))
+
+		_, err = sendTLS(server.URL, &TLSConfig{RootCAPEM: serverCAPEM(server), ServerName: "example.org"})
+		assert.Equal(t, ErrorKindTLS, ClassifyError(err))
+	})
+
+	t.Run("test that server public keys are pinned", func(t *testing.T) {
+		server := newTLSServer(t, nil)
+		defer server.Close()
+
+		sum := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+		pin := base64.StdEncoding.EncodeToString(sum[:])
+		_, err := sendTLS(server.URL, &TLSConfig{RootCAPEM: serverCAPEM(server), PinnedSPKI: []string{pin}})
+		assert.NoError(t, err)
+
+		other := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+		_, err = sendTLS(server.URL, &TLSConfig{RootCAPEM: serverCAPEM(server), PinnedSPKI: []string{other}})
+		var pinErr *CertificatePinError
+		assert.ErrorAs(t, err, &pinErr)
+		assert.Equal(t, ErrorKindTLS, ClassifyError(err))
+
+		_, err = sendTLS(server.URL, &TLSConfig{PinnedSPKI: []string{"not a pin"}})
+		assert.EqualError(t, err, `tls: invalid SPKI pin "not a pin"`)
+	})
+
+	t.Run("test that equal configurations share a transport", func(t *testing.T) {
+		ca := newTestCA(t)
+		newConfig := func() Config {
+			return Config{TLS: &TLSConfig{RootCAPEM: ca.pem, MinVersion: tls.VersionTLS13}}
+		}
+
+		first := New(newConfig(), Operation{}, Hooks{}, nil, nil, nil)
+		second := New(newConfig(), Operation{}, Hooks{}, nil, nil, nil)
+		assert.NoError(t, first.Error)
+		assert.Same(t, first.Config.HTTPClient.Transport, second.Config.HTTPClient.Transport)
+
+		transport := first.Config.HTTPClient.Transport.(*http.Transport)
+		assert.Equal(t, uint16(tls.VersionTLS13), transport.TLSClientConfig.MinVersion)
+
+		other := New(Config{TLS: &TLSConfig{RootCAPEM: ca.pem}}, Operation{}, Hooks{}, nil, nil, nil)
+		assert.NotSame(t, first.Config.HTTPClient.Transport, other.Config.HTTPClient.Transport)
+	})
+
+	t.Run("test that the transport of the http client is cloned", func(t *testing.T) {
+		base := &http.Transport{MaxIdleConns: 7}
+		client := &http.Client{Transport: base, Timeout: time.Second}
+		req := New(Config{HTTPClient: client, TLS: &TLSConfig{}}, Operation{}, Hooks{}, nil, nil, nil)
+
+		assert.NoError(t, req.Error)
+		assert.Equal(t, time.Second, req.Config.HTTPClient.Timeout)
+		transport := req.Config.HTTPClient.Transport.(*http.Transport)
+		assert.Equal(t, 7, transport.MaxIdleConns)
+		assert.Equal(t, uint16(tls.VersionTLS12), transport.TLSClientConfig.MinVersion)
+
+		req = New(Config{HTTPClient: &http.Client{Transport: http.NewFileTransport(http.Dir("."))}, TLS: &TLSConfig{}}, Operation{}, Hooks{}, nil, nil, nil)
+		assert.ErrorContains(t, req.Error, "is not an *http.Transport")
+	})
+
+	t.Run("test that a wrapped default transport is an error", func(t *testing.T) {
+		defaultTransport := http.DefaultTransport
+		t.Cleanup(func() { http.DefaultTransport = defaultTransport })
+		http.DefaultTransport = http.NewFileTransport(http.Dir("."))
+
+		req := New(Config{TLS: &TLSConfig{}}, Operation{}, Hooks{}, nil, nil, nil)
+		assert.ErrorContains(t, req.Error, "is not an *http.Transport")
+	})
+
+	t.Run("test that the least recently used transports are dropped", func(t *testing.T) {
+		newConfig := func(i int) Config {
+			return Config{TLS: &TLSConfig{ServerName: fmt.Sprintf("rotated-%d.example.com", i)}}
+		}
+
+		first := New(newConfig(0), Operation{}, Hooks{}, nil, nil, nil)
+		second := New(newConfig(1), Operation{}, Hooks{}, nil, nil, nil)
+		for i := 2; i <= maxTransports; i++ {
+			// the first transport is used again, so the second one is dropped
+			assert.Same(t, first.Config.HTTPClient.Transport, New(newConfig(0), Operation{}, Hooks{}, nil, nil, nil).Config.HTTPClient.Transport)
+			New(newConfig(i), Operation{}, Hooks{}, nil, nil, nil)
+		}
+
+		transports.Lock()
+		assert.Len(t, transports.m, maxTransports)
+		transports.Unlock()
+		assert.Same(t, first.Config.HTTPClient.Transport, New(newConfig(0), Operation{}, Hooks{}, nil, nil, nil).Config.HTTPClient.Transport)
+		assert.NotSame(t, second.Config.HTTPClient.Transport, New(newConfig(1), Operation{}, Hooks{}, nil, nil, nil).Config.HTTPClient.Transport)
+	})
+}
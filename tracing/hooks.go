@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/SirWaithaka/gorequest"
+)
+
+// Semantic convention attribute keys set on request spans
+const (
+	AttrHTTPMethod      = "http.request.method"
+	AttrHTTPStatusCode  = "http.response.status_code"
+	AttrHTTPResendCount = "http.request.resend_count"
+	AttrURLFull         = "url.full"
+	AttrServerAddress   = "server.address"
+	AttrServerPort      = "server.port"
+	AttrPeerService     = "peer.service"
+	AttrErrorType       = "error.type"
+	AttrExceptionMsg    = "exception.message"
+	AttrOperationName   = "gorequest.operation"
+)
+
+// New returns a Tracing instance whose hooks trace requests with tracer.
+func New(tracer Tracer) Tracing {
+	return Tracing{tracer: tracer}
+}
+
+// Tracing provides the hooks that start, propagate and end a client span for
+// each call to gorequest.Request.Send.
+type Tracing struct {
+	tracer Tracer
+}
+
+// Apply registers all tracing hooks on hooks. The span is started before any
+// other validate hook and trace headers are injected after all build hooks.
+func (t Tracing) Apply(hooks *gorequest.Hooks) {
+	hooks.Validate.PushFrontHook(t.Start())
+	hooks.Build.PushBackHook(t.Inject())
+	hooks.Retry.PushBackHook(t.Retry())
+	hooks.Complete.PushFrontHook(t.End())
+}
+
+// Start starts a client span and stores it in the request context. Register it
+// as the first validate hook so that the whole request is covered.
+func (t Tracing) Start() gorequest.Hook {
+	return gorequest.Hook{Name: "tracing.Start", Fn: func(r *gorequest.Request) {
+		name := r.Operation.Name
+		if name == "" {
+			name = r.Request.Method
+		}
+
+		attrs := []Attribute{String(AttrHTTPMethod, r.Request.Method)}
+		if r.Config.ServiceName != "" {
+			attrs = append(attrs, String(AttrPeerService, r.Config.ServiceName))
+		}
+		if r.Operation.Name != "" {
+			attrs = append(attrs, String(AttrOperationName, r.Operation.Name))
+		}
+
+		ctx, _ := t.tracer.Start(r.Context(), name, attrs...)
+		r.WithContext(ctx)
+	}}
+}
+
+// Inject sets the url attributes on the span and writes the traceparent and
+// tracestate headers to the http request.
+func (t Tracing) Inject() gorequest.Hook {
+	return gorequest.Hook{Name: "tracing.Inject", Fn: func(r *gorequest.Request) {
+		span := SpanFromContext(r.Context())
+		span.SetAttributes(urlAttributes(r)...)
+
+		Inject(r.Context(), r.Request.Header)
+	}}
+}
+
+// Retry records an event for the failed attempt that is about to be retried.
+func (t Tracing) Retry() gorequest.Hook {
+	return gorequest.Hook{Name: "tracing.Retry", Fn: func(r *gorequest.Request) {
+		span := SpanFromContext(r.Context())
+
+		attrs := []Attribute{Int(AttrHTTPResendCount, r.RetryConfig.RetryCount)}
+		if r.Response != nil && r.Response.StatusCode != 0 {
+			attrs = append(attrs, Int(AttrHTTPStatusCode, r.Response.StatusCode))
+		}
+		if r.Error != nil {
+			attrs = append(attrs, String(AttrExceptionMsg, r.Error.Error()))
+		}
+		span.AddEvent("retry", attrs...)
+		span.SetAttributes(Int(AttrHTTPResendCount, r.RetryConfig.RetryCount))
+	}}
+}
+
+// End records the response status and request error on the span and ends it.
+func (t Tracing) End() gorequest.Hook {
+	return gorequest.Hook{Name: "tracing.End", Fn: func(r *gorequest.Request) {
+		span := SpanFromContext(r.Context())
+
+		status := 0
+		if r.Response != nil {
+			status = r.Response.StatusCode
+		}
+		if status != 0 {
+			span.SetAttributes(Int(AttrHTTPStatusCode, status))
+		}
+
+		switch {
+		case r.Error != nil:
+			span.SetAttributes(String(AttrErrorType, errorType(r)))
+			span.SetStatus(StatusError, r.Error.Error())
+		case status >= http.StatusBadRequest:
+			span.SetAttributes(String(AttrErrorType, strconv.Itoa(status)))
+			span.SetStatus(StatusError, http.StatusText(status))
+		}
+
+		span.End()
+	}}
+}
+
+func urlAttributes(r *gorequest.Request) []Attribute {
+	u := r.Request.URL
+	if u == nil {
+		return nil
+	}
+
+	attrs := []Attribute{String(AttrURLFull, redactedURL(r))}
+	if host := u.Hostname(); host != "" {
+		attrs = append(attrs, String(AttrServerAddress, host))
+	}
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, Int(AttrServerPort, p))
+	}
+	return attrs
+}
+
+// redactedURL returns the request url without user info credentials.
+func redactedURL(r *gorequest.Request) string {
+	u := *r.Request.URL
+	u.User = nil
+	return u.String()
+}
+
+func errorType(r *gorequest.Request) string {
+	if r.Response != nil && r.Response.StatusCode >= http.StatusBadRequest {
+		return strconv.Itoa(r.Response.StatusCode)
+	}
+	if kind := gorequest.Classify(r); kind != gorequest.ErrorKindUnknown {
+		return kind.String()
+	}
+	return "_OTHER"
+}
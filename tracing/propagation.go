@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// TraceParentHeader is the W3C Trace Context header carrying the trace id,
+	// parent span id and trace flags.
+	TraceParentHeader = "traceparent"
+	// TraceStateHeader is the W3C Trace Context header carrying vendor
+	// specific trace state.
+	TraceStateHeader = "tracestate"
+)
+
+// FormatTraceParent encodes sc using the version 00 traceparent format.
+func FormatTraceParent(sc SpanContext) string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceParent decodes a traceparent header value.
+func ParseTraceParent(value string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return sc, errInvalidTraceParent
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	// version ff is forbidden and version 00 has exactly four fields
+	if len(version) != 2 || version == "ff" || (version == "00" && len(parts) != 4) {
+		return sc, errInvalidTraceParent
+	}
+	if len(traceID) != 32 || len(spanID) != 16 || len(flags) != 2 {
+		return sc, errInvalidTraceParent
+	}
+	if !isLowerHex(version + traceID + spanID + flags) {
+		return sc, errInvalidTraceParent
+	}
+
+	if _, err := hex.Decode(sc.TraceID[:], []byte(traceID)); err != nil {
+		return sc, errInvalidTraceParent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(spanID)); err != nil {
+		return sc, errInvalidTraceParent
+	}
+	var f [1]byte
+	if _, err := hex.Decode(f[:], []byte(flags)); err != nil {
+		return sc, errInvalidTraceParent
+	}
+	sc.Flags = f[0]
+
+	if !sc.IsValid() {
+		return SpanContext{}, errInvalidTraceParent
+	}
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Inject writes the traceparent and tracestate headers for the span held in ctx.
+// Nothing is written when ctx holds no valid span.
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanFromContext(ctx).SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+
+	header.Set(TraceParentHeader, FormatTraceParent(sc))
+	if sc.TraceState != "" {
+		header.Set(TraceStateHeader, sc.TraceState)
+	} else {
+		header.Del(TraceStateHeader)
+	}
+}
+
+// Extract reads the traceparent and tracestate headers and returns a copy of ctx
+// with the remote span context. If the headers are missing or invalid, ctx is
+// returned unchanged.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceParent(header.Get(TraceParentHeader))
+	if err != nil {
+		return ctx
+	}
+	sc.TraceState = strings.Join(header.Values(TraceStateHeader), ",")
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
@@ -0,0 +1,296 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+type (
+	// TraceID is a unique identifier of a trace as defined by W3C Trace Context.
+	TraceID [16]byte
+
+	// SpanID is a unique identifier of a span within a trace.
+	SpanID [8]byte
+)
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// FlagsSampled is the trace-flags bit that marks a trace as sampled.
+const FlagsSampled byte = 0x01
+
+// SpanContext holds the identifying and propagated parts of a span.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+	// Remote is true when the span context was extracted from an incoming carrier
+	Remote bool
+}
+
+// IsValid returns true if both the trace id and span id are non-zero.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// IsSampled returns true if the sampled flag is set.
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&FlagsSampled == FlagsSampled
+}
+
+// Attribute is a key value pair describing a span or an event.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String returns a string valued Attribute
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an int valued Attribute
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// StatusCode is the status of a finished span.
+type StatusCode uint
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+// Span is a single traced operation. Implementations must be safe to use
+// from the hooks of a single request.
+type Span interface {
+	// SpanContext returns the identity of the span used for propagation.
+	SpanContext() SpanContext
+	// SetAttributes sets or overwrites attributes on the span.
+	SetAttributes(attrs ...Attribute)
+	// AddEvent records a named event with the current time.
+	AddEvent(name string, attrs ...Attribute)
+	// SetStatus sets the final status of the span.
+	SetStatus(code StatusCode, description string)
+	// End completes the span. Calls after the first have no effect.
+	End()
+}
+
+// Tracer starts spans. It is deliberately small, so an OpenTelemetry tracer or
+// any other tracing SDK can be adapted to it with a thin wrapper.
+type Tracer interface {
+	// Start creates a span as a child of any span found in ctx and returns a
+	// context holding the new span.
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx holding span.
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span held in ctx. If ctx has no span, a no-op
+// span is returned.
+func SpanFromContext(ctx context.Context) Span {
+	if span, ok := ctx.Value(spanKey{}).(Span); ok {
+		return span
+	}
+	return noopSpan{}
+}
+
+// ContextWithRemoteSpanContext returns a copy of ctx holding a non-recording
+// span with sc. New spans started from the context become children of sc.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return ContextWithSpan(ctx, noopSpan{sc: sc})
+}
+
+type noopSpan struct {
+	sc SpanContext
+}
+
+func (s noopSpan) SpanContext() SpanContext    { return s.sc }
+func (noopSpan) SetAttributes(...Attribute)    {}
+func (noopSpan) AddEvent(string, ...Attribute) {}
+func (noopSpan) SetStatus(StatusCode, string)  {}
+func (noopSpan) End()                          {}
+
+// Event is a timestamped annotation recorded on a span.
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes []Attribute
+}
+
+// SpanData is the read-only snapshot of an ended span handed to an Exporter.
+type SpanData struct {
+	Name              string
+	SpanContext       SpanContext
+	Parent            SpanContext
+	StartTime         time.Time
+	EndTime           time.Time
+	Attributes        []Attribute
+	Events            []Event
+	Status            StatusCode
+	StatusDescription string
+}
+
+// Attribute returns the value of the attribute with the given key, and false
+// if the span has no such attribute.
+func (d SpanData) Attribute(key string) (any, bool) {
+	for _, a := range d.Attributes {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Exporter receives spans once they end.
+type Exporter interface {
+	Export(SpanData)
+}
+
+// NewTracer returns a Tracer that generates W3C compatible ids and hands every
+// ended span to exp.
+func NewTracer(exp Exporter) Tracer {
+	return &tracer{exporter: exp}
+}
+
+type tracer struct {
+	exporter Exporter
+}
+
+func (t *tracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	parent := SpanFromContext(ctx).SpanContext()
+
+	sc := SpanContext{Flags: FlagsSampled}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Flags = parent.Flags
+		sc.TraceState = parent.TraceState
+	} else {
+		binaryPutUint64(sc.TraceID[:8], rand.Uint64())
+		binaryPutUint64(sc.TraceID[8:], rand.Uint64())
+	}
+	binaryPutUint64(sc.SpanID[:], rand.Uint64()|1)
+
+	s := &span{tracer: t}
+	s.data = SpanData{
+		Name:        name,
+		SpanContext: sc,
+		Parent:      parent,
+		StartTime:   time.Now(),
+	}
+	s.SetAttributes(attrs...)
+
+	return ContextWithSpan(ctx, s), s
+}
+
+func binaryPutUint64(b []byte, v uint64) {
+	for i := 7; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+}
+
+type span struct {
+	tracer *tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *span) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+func (s *span) SetAttributes(attrs ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, attr := range attrs {
+		replaced := false
+		for i := range s.data.Attributes {
+			if s.data.Attributes[i].Key == attr.Key {
+				s.data.Attributes[i] = attr
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			s.data.Attributes = append(s.data.Attributes, attr)
+		}
+	}
+}
+
+func (s *span) AddEvent(name string, attrs ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Events = append(s.data.Events, Event{Name: name, Time: time.Now(), Attributes: attrs})
+}
+
+func (s *span) SetStatus(code StatusCode, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Status = code
+	s.data.StatusDescription = description
+}
+
+func (s *span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if s.tracer.exporter != nil {
+		s.tracer.exporter.Export(data)
+	}
+}
+
+// InMemoryExporter keeps ended spans in memory. It is intended for tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// Export stores the span
+func (e *InMemoryExporter) Export(data SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, data)
+}
+
+// Spans returns a copy of the spans exported so far
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset removes all stored spans
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
+
+var errInvalidTraceParent = errors.New("invalid traceparent")
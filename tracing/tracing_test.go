@@ -0,0 +1,169 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/SirWaithaka/gorequest"
+	"github.com/SirWaithaka/gorequest/corehooks"
+	"github.com/SirWaithaka/gorequest/tracing"
+)
+
+type temporaryError struct{ error }
+
+func (temporaryError) Temporary() bool { return true }
+
+func TestTraceParent(t *testing.T) {
+
+	t.Run("test that a formatted traceparent can be parsed", func(t *testing.T) {
+		sc := tracing.SpanContext{
+			TraceID: tracing.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+			SpanID:  tracing.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+			Flags:   tracing.FlagsSampled,
+		}
+
+		value := tracing.FormatTraceParent(sc)
+		assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", value)
+
+		parsed, err := tracing.ParseTraceParent(value)
+		assert.NoError(t, err)
+		assert.Equal(t, sc, parsed)
+	})
+
+	t.Run("test that invalid values are rejected", func(t *testing.T) {
+		tcs := map[string]string{
+			"empty":             "",
+			"forbidden version": "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"zero trace id":     "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			"zero span id":      "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			"upper case hex":    "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			"short trace id":    "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+			"extra fields v00":  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-aa",
+		}
+
+		for name, value := range tcs {
+			t.Run(name, func(t *testing.T) {
+				_, err := tracing.ParseTraceParent(value)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestTracing(t *testing.T) {
+
+	t.Run("test that a span is exported and headers are injected", func(t *testing.T) {
+		var received http.Header
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r.Header.Clone()
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		exporter := &tracing.InMemoryExporter{}
+		hooks := corehooks.Default()
+		tracing.New(tracing.NewTracer(exporter)).Apply(&hooks)
+
+		cfg := gorequest.Config{Endpoint: server.URL, ServiceName: "posts"}
+		op := gorequest.Operation{Name: "GetPost", Method: http.MethodGet, Path: "/posts/1"}
+		req := gorequest.New(cfg, op, hooks, nil, nil, nil)
+
+		err := req.Send()
+		assert.NoError(t, err)
+
+		spans := exporter.Spans()
+		if !assert.Len(t, spans, 1) {
+			return
+		}
+		span := spans[0]
+
+		assert.Equal(t, "GetPost", span.Name)
+		assert.Equal(t, tracing.FormatTraceParent(span.SpanContext), received.Get(tracing.TraceParentHeader))
+		assert.False(t, span.EndTime.IsZero())
+		assert.Equal(t, tracing.StatusUnset, span.Status)
+
+		expected := map[string]any{
+			tracing.AttrHTTPMethod:     http.MethodGet,
+			tracing.AttrPeerService:    "posts",
+			tracing.AttrURLFull:        server.URL + "/posts/1",
+			tracing.AttrServerAddress:  "127.0.0.1",
+			tracing.AttrHTTPStatusCode: http.StatusOK,
+		}
+		for key, value := range expected {
+			actual, ok := span.Attribute(key)
+			assert.True(t, ok, key)
+			assert.Equal(t, value, actual, key)
+		}
+	})
+
+	t.Run("test that the span is a child of the span in the request context", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		parent, err := tracing.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		assert.NoError(t, err)
+		parent.TraceState = "vendor=value"
+
+		exporter := &tracing.InMemoryExporter{}
+		hooks := corehooks.Default()
+		tracing.New(tracing.NewTracer(exporter)).Apply(&hooks)
+
+		var received http.Header
+		hooks.Send.PushFront(func(r *gorequest.Request) {
+			received = r.Request.Header.Clone()
+		})
+
+		req := gorequest.New(gorequest.Config{Endpoint: server.URL}, gorequest.Operation{Method: http.MethodGet}, hooks, nil, nil, nil)
+		req.WithContext(tracing.ContextWithRemoteSpanContext(context.Background(), parent))
+		assert.NoError(t, req.Send())
+
+		spans := exporter.Spans()
+		if !assert.Len(t, spans, 1) {
+			return
+		}
+		assert.Equal(t, http.MethodGet, spans[0].Name)
+		assert.Equal(t, parent.TraceID, spans[0].SpanContext.TraceID)
+		assert.Equal(t, parent.SpanID, spans[0].Parent.SpanID)
+		assert.NotEqual(t, parent.SpanID, spans[0].SpanContext.SpanID)
+		assert.Equal(t, "vendor=value", received.Get(tracing.TraceStateHeader))
+	})
+
+	t.Run("test that retries are recorded as span events", func(t *testing.T) {
+		exporter := &tracing.InMemoryExporter{}
+		hooks := gorequest.Hooks{}
+		tracing.New(tracing.NewTracer(exporter)).Apply(&hooks)
+
+		hooks.Send.PushBack(func(r *gorequest.Request) {
+			r.Error = temporaryError{errors.New("connection reset")}
+		})
+		retryHook := corehooks.NewRetryer()
+		hooks.Retry.PushFrontHook(retryHook.Retry())
+		hooks.Complete.PushBackHook(retryHook.Close())
+
+		req := gorequest.New(gorequest.Config{}, gorequest.Operation{Name: "Flaky"}, hooks, gorequest.DefaultRetryer, nil, nil)
+		req.WithRetryConfig(gorequest.RetryConfig{MaxRetries: 2, InitialDelay: time.Millisecond, Multiplier: 1, MaxDelay: time.Millisecond})
+
+		err := req.Send()
+		assert.Error(t, err)
+
+		spans := exporter.Spans()
+		if !assert.Len(t, spans, 1) {
+			return
+		}
+		span := spans[0]
+
+		assert.Len(t, span.Events, 2)
+		for _, event := range span.Events {
+			assert.Equal(t, "retry", event.Name)
+		}
+		assert.Equal(t, tracing.StatusError, span.Status)
+		count, _ := span.Attribute(tracing.AttrHTTPResendCount)
+		assert.Equal(t, 2, count)
+	})
+}